@@ -26,7 +26,13 @@ import (
 	"log"
 	"net"
 	"os"
-	"strings"
+	"strconv"
+)
+
+// IP families a host can prefer when it chooses the address it advertises.
+const (
+	PreferIPv4 = "ipv4"
+	PreferIPv6 = "ipv6"
 )
 
 // HostingDevice type holds the attributes of the device on which an Arrowhead framework system is running on
@@ -37,9 +43,14 @@ type HostingDevice struct {
 	IPAddresses  []string            `json:"ipAddresses"`
 	MACAddresses []string            `json:"macAddresses"`
 	Details      map[string][]string `json:"deviceDetails"`
+
+	// IPPreference names the family advertised first, PreferIPv4 or
+	// PreferIPv6. Blank is IPv4, which is what every host was before IPv6
+	// addresses were collected at all.
+	IPPreference string `json:"ipPreference,omitempty"`
 }
 
-// NewDevice constructor gets the device or host name as well as the list of available IPv4 addresses the host has and associated MAC addresses.
+// NewDevice constructor gets the device or host name as well as the list of available IP addresses the host has and associated MAC addresses.
 func NewDevice() *HostingDevice {
 	name, err := Hostname()
 	if err != nil {
@@ -101,9 +112,11 @@ func IpAddresses() ([]string, error) {
 			if ip == nil || ip.IsLoopback() {
 				continue
 			}
-			ip = ip.To4()
-			if ip == nil {
-				continue // not an ipv4 address
+			// A link-local IPv6 address is only reachable together with the
+			// zone of the interface it was found on, and the zone of this
+			// host's interface means nothing to the host dialing it.
+			if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+				continue
 			}
 			ipAddresses = append(ipAddresses, ip.String())
 		}
@@ -124,10 +137,17 @@ func MacAddresses(ipAddresses []string) ([]string, error) {
 	for _, iface := range ifaces {
 		if addrs, err := iface.Addrs(); err == nil {
 			for _, addr := range addrs {
+				ifaceIP, _, err := net.ParseCIDR(addr.String())
+				if err != nil {
+					continue
+				}
 				for _, ipAdd := range ipAddresses {
-
-					if strings.Contains(addr.String(), ipAdd) && ipAdd != "127.0.0.1" {
+					// Compared as addresses rather than as text: 2001:db8::1 is
+					// a substring of 2001:db8::10, and an interface carries one of
+					// each family, so one interface is listed once.
+					if ifaceIP.Equal(net.ParseIP(ipAdd)) && !ifaceIP.IsLoopback() {
 						interfacesList = append(interfacesList, iface.HardwareAddr.String()) // only interested in the name with current IP address
+						break
 					}
 				}
 			}
@@ -136,3 +156,47 @@ func MacAddresses(ipAddresses []string) ([]string, error) {
 
 	return interfacesList, nil
 }
+
+// Advertised returns the host's addresses in the order a consumer should try
+// them: the preferred family first, the other after it, and loopback last.
+//
+// The order is the whole point. The registrar hands a consumer the first
+// address of a record, so on a segment that routes only IPv6 an IPv4 address in
+// front sends every consumer somewhere it cannot reach. Within a family the
+// configured order is kept, so an operator who listed the addresses in the
+// systemconfig.json file still decides.
+func (hd *HostingDevice) Advertised() []string {
+	preferV6 := hd.IPPreference == PreferIPv6
+	var preferred, other, loopback []string
+	for _, addr := range hd.IPAddresses {
+		ip := net.ParseIP(addr)
+		switch {
+		case ip != nil && ip.IsLoopback():
+			loopback = append(loopback, addr)
+		case ip != nil && (ip.To4() == nil) == preferV6:
+			preferred = append(preferred, addr)
+		default:
+			other = append(other, addr)
+		}
+	}
+	ordered := make([]string, 0, len(hd.IPAddresses))
+	ordered = append(ordered, preferred...)
+	ordered = append(ordered, other...)
+	return append(ordered, loopback...)
+}
+
+// AdvertisedIP returns the address this host gives out for itself, or an empty
+// string if it knows of none.
+func (hd *HostingDevice) AdvertisedIP() string {
+	if addrs := hd.Advertised(); len(addrs) > 0 {
+		return addrs[0]
+	}
+	return ""
+}
+
+// HostPort joins an address and a port for use in a URL, bracketing an IPv6
+// literal: "fd00::7" and 8443 make "[fd00::7]:8443", which a URL parser reads as
+// a host and a port rather than as a host with too many colons.
+func HostPort(ip string, port int) string {
+	return net.JoinHostPort(ip, strconv.Itoa(port))
+}
@@ -1,6 +1,7 @@
 package components
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -39,3 +40,51 @@ func TestNewDevice(t *testing.T) {
 		t.Errorf("Expected a new device, got: %v", res)
 	}
 }
+
+func TestAdvertisedPutsThePreferredFamilyFirst(t *testing.T) {
+	hd := &HostingDevice{IPAddresses: []string{"127.0.0.1", "192.168.1.10", "fd00::7", "10.0.0.2", "2001:db8::1"}}
+
+	table := []struct {
+		preference string
+		want       []string
+	}{
+		{"", []string{"192.168.1.10", "10.0.0.2", "fd00::7", "2001:db8::1", "127.0.0.1"}},
+		{PreferIPv4, []string{"192.168.1.10", "10.0.0.2", "fd00::7", "2001:db8::1", "127.0.0.1"}},
+		{PreferIPv6, []string{"fd00::7", "2001:db8::1", "192.168.1.10", "10.0.0.2", "127.0.0.1"}},
+	}
+	for _, tc := range table {
+		hd.IPPreference = tc.preference
+		got := hd.Advertised()
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("preference %q: got %v, want %v", tc.preference, got, tc.want)
+		}
+		if hd.AdvertisedIP() != tc.want[0] {
+			t.Errorf("preference %q: advertised %q, want %q", tc.preference, hd.AdvertisedIP(), tc.want[0])
+		}
+	}
+}
+
+// An IPv6-only host still has something to advertise when IPv4 is preferred,
+// and a host with no addresses at all advertises nothing rather than panicking.
+func TestAdvertisedFallsBackToTheOtherFamily(t *testing.T) {
+	hd := &HostingDevice{IPAddresses: []string{"fd00::7", "127.0.0.1"}, IPPreference: PreferIPv4}
+	if got := hd.AdvertisedIP(); got != "fd00::7" {
+		t.Errorf("got %q, want the IPv6 address ahead of loopback", got)
+	}
+	if got := (&HostingDevice{}).AdvertisedIP(); got != "" {
+		t.Errorf("a host with no addresses advertised %q", got)
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	table := map[string]string{
+		"192.168.1.10": "192.168.1.10:8443",
+		"fd00::7":      "[fd00::7]:8443",
+		"localhost":    "localhost:8443",
+	}
+	for ip, want := range table {
+		if got := HostPort(ip, 8443); got != want {
+			t.Errorf("HostPort(%q): got %q, want %q", ip, got, want)
+		}
+	}
+}
@@ -23,12 +23,15 @@
 package components
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/tls"
 	"crypto/x509/pkix"
 	"maps"
+	"net/http"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // An Arrowhead husk enwraps the "thing" and has specific properties
@@ -78,6 +81,72 @@ type Husk struct {
 	// AuthorizerReady is closed once AuthorizerKey holds a chain-validated key.
 	// Until then a provider refuses token-bearing requests rather than guessing.
 	AuthorizerReady chan struct{} `json:"-"`
+
+	// Mux is this system's own router, and the handler of both its servers.
+	//
+	// Its own rather than http.DefaultServeMux, which is one per process: a
+	// gateway hosting two systems registered both on the same router, and two
+	// systems of the same name could not be started side by side at all — the
+	// second registration of the path panics. Lazily initialized by
+	// usecases.EnsureServeMux, so a system that wants a path of its own beside
+	// the framework's can register it before SetoutServers runs.
+	Mux *http.ServeMux `json:"-"`
+
+	// ListenHost is the address the TCP servers bind on, and then the only one
+	// registered. Blank is every interface. "127.0.0.1" or "::1" keeps a system
+	// that only serves its neighbours on the same gateway off the network.
+	ListenHost string `json:"-"`
+
+	// UnixSockets names a Unix domain socket per protocol, served beside the
+	// TCP port or, with the port set to 0, instead of it. A consumer on the same
+	// host reaches the provider through it; see usecases.routeLocally.
+	UnixSockets map[string]string `json:"-"`
+
+	// SocketDir is the directory the sockets of this host's systems are in. A
+	// consumer dials a provider's socket only if it is under it, so that a
+	// service record cannot send requests, and the token with them, into any
+	// other socket on the host. Blank is the directory of the system's own
+	// sockets; a system with neither dials none. See usecases.routeLocally.
+	SocketDir string `json:"-"`
+
+	// Registered is how each service's last registration attempt went, which
+	// a readiness probe reports and nothing else remembers: the registration
+	// loop only logs a failure and tries again.
+	Registered Registrations `json:"-"`
+
+	// Metrics is what the framework counts about this system, served at
+	// /<system>/metrics.
+	Metrics Metrics `json:"-"`
+
+	// HTTP2 has the servers speak HTTP/2 beside HTTP/1.1 — negotiated over
+	// TLS, with prior knowledge (h2c) over plaintext — and the client offer it.
+	HTTP2 bool `json:"-"`
+
+	// MaxBody is the largest request body the system accepts where a service
+	// does not say otherwise, in bytes. Zero means usecases.DefaultMaxBody.
+	MaxBody int64 `json:"-"`
+
+	// RateLimit is how often one peer may call this system; the zero value
+	// sets no limit. Limiter holds each peer's allowance.
+	RateLimit RateLimit   `json:"-"`
+	Limiter   RateLimiter `json:"-"`
+
+	// ValidateForms has a payload checked against its form's schema before it
+	// is unpacked, and refused with every problem listed if it does not match.
+	ValidateForms bool `json:"-"`
+
+	// Servers are the HTTP servers SetoutServers started, which an ordered
+	// shutdown drains before the system's cleanups run.
+	Servers Servers `json:"-"`
+
+	// Stopping is begun by usecases.Shutdown and is what the work that must
+	// finish before the process exits — deregistration, above all — listens to.
+	Stopping Stopping `json:"-"`
+
+	// DrainTimeout is how long an ordered shutdown waits, for the registrar's
+	// answers and then for requests in flight, before it gives up on them. Zero
+	// means usecases.DefaultDrainTimeout.
+	DrainTimeout time.Duration `json:"-"`
 }
 
 // SProtocols returns a slice of supported protocols (i.e., those not configured with 0)
@@ -118,6 +187,8 @@ type BoundPorts struct {
 	// canceled context — because Release drops the entry and the check reads
 	// zero. A permission that can come back on its own is not a permission.
 	ever map[string]int
+	// sockets is the Unix domain socket each protocol is served on, if any.
+	sockets map[string]string
 }
 
 // Bind records that a protocol is being served on a port. Call it after the
@@ -174,9 +245,237 @@ func (b *BoundPorts) Port(protocol string) int {
 	return b.ports[protocol]
 }
 
-// Any reports whether anything is being served.
+// Any reports whether anything is being served, on a port or on a socket.
 func (b *BoundPorts) Any() bool {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return len(b.ports) > 0
+	return len(b.ports) > 0 || len(b.sockets) > 0
+}
+
+// BindSocket records that a protocol is being served on a Unix domain socket.
+// Like Bind, call it once the listener exists.
+func (b *BoundPorts) BindSocket(protocol, path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sockets == nil {
+		b.sockets = make(map[string]string, 2)
+	}
+	b.sockets[protocol] = path
+}
+
+// ReleaseSocket records that a protocol's socket is no longer being served.
+func (b *BoundPorts) ReleaseSocket(protocol string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sockets, protocol)
+}
+
+// Sockets returns a copy of the Unix domain sockets being served, by protocol.
+func (b *BoundPorts) Sockets() map[string]string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return maps.Clone(b.sockets)
+}
+
+//-------------------------------------How registration is going
+
+// Registration is the outcome of a service's last registration attempt.
+type Registration struct {
+	Registered bool      `json:"registered"`
+	At         time.Time `json:"at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Registrations records, per service, how the last attempt to register it with
+// the lead registrar went. Written by the registration loops and read by the
+// readiness probe, so it carries its own lock, as BoundPorts does.
+type Registrations struct {
+	mu      sync.RWMutex
+	outcome map[string]Registration
+}
+
+// Record notes the outcome of an attempt to register a service; a nil error
+// is a success.
+func (r *Registrations) Record(service string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.outcome == nil {
+		r.outcome = make(map[string]Registration)
+	}
+	outcome := Registration{Registered: err == nil, At: time.Now()}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+	r.outcome[service] = outcome
+}
+
+// Outcomes returns a copy of the last outcome for each service attempted.
+func (r *Registrations) Outcomes() map[string]Registration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return maps.Clone(r.outcome)
+}
+
+//-------------------------------------Stopping in order
+
+// Servers records the HTTP servers a system has started, so that shutting down
+// can drain them. Started from more than one goroutine, so it carries its own
+// lock, as BoundPorts does.
+type Servers struct {
+	mu   sync.Mutex
+	list []*http.Server
+}
+
+// Add records a server.
+func (s *Servers) Add(srv *http.Server) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = append(s.list, srv)
+}
+
+// All returns the servers recorded so far.
+func (s *Servers) All() []*http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*http.Server(nil), s.list...)
+}
+
+// Stopping says an ordered shutdown has begun, and lets it wait for the work it
+// must not cut short. The zero value is ready to use.
+type Stopping struct {
+	mu     sync.Mutex
+	begun  chan struct{}
+	closed bool
+	held   sync.WaitGroup
+}
+
+// Begin starts the shutdown, and reports whether this call was the one to.
+func (s *Stopping) Begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.ensure()
+	close(s.begun)
+	s.closed = true
+	return true
+}
+
+// Begun reports whether the shutdown has started.
+func (s *Stopping) Begun() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Signal is closed when the shutdown begins, for a select beside sys.Ctx.Done().
+func (s *Stopping) Signal() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensure()
+	return s.begun
+}
+
+// ensure makes the channel on first use. Callers hold the lock.
+func (s *Stopping) ensure() {
+	if s.begun == nil {
+		s.begun = make(chan struct{})
+	}
+}
+
+// Hold registers work the shutdown waits for, and returns the call that says it
+// is done. Take it before the shutdown can begin — when the work is started.
+func (s *Stopping) Hold() (release func()) {
+	s.held.Add(1)
+	var once sync.Once
+	return func() { once.Do(s.held.Done) }
+}
+
+// Wait waits for every holder to release, or for ctx to end first.
+func (s *Stopping) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.held.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//-------------------------------------Limiting callers
+
+// RateLimit is how often one peer may call: PerSecond on average, and up to
+// Burst at once after a quiet spell. A PerSecond of zero sets no limit; a Burst
+// of zero allows one request at a time.
+type RateLimit struct {
+	PerSecond float64 `json:"perSecond"`
+	Burst     int     `json:"burst,omitempty"`
+}
+
+// maxTrackedPeers bounds how many peers a RateLimiter keeps a bucket for. Past
+// it, a bucket that has refilled is forgotten — its peer has lost nothing — and
+// failing that, any one is.
+const maxTrackedPeers = 4096
+
+// RateLimiter keeps a token bucket for each peer. The zero value is ready to
+// use.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Take spends one of peer's tokens, and reports whether there was one and, if
+// not, how long until there will be.
+func (l *RateLimiter) Take(peer string, limit RateLimit, now time.Time) (ok bool, retryAfter time.Duration) {
+	if limit.PerSecond <= 0 {
+		return true, 0
+	}
+	capacity := float64(max(limit.Burst, 1))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	b, known := l.buckets[peer]
+	if !known {
+		l.makeRoom(limit, capacity, now)
+		b = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[peer] = b
+	}
+	b.tokens = min(capacity, b.tokens+now.Sub(b.last).Seconds()*limit.PerSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / limit.PerSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// makeRoom forgets buckets once there are too many. Callers hold the lock.
+func (l *RateLimiter) makeRoom(limit RateLimit, capacity float64, now time.Time) {
+	if len(l.buckets) < maxTrackedPeers {
+		return
+	}
+	for peer, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*limit.PerSecond >= capacity {
+			delete(l.buckets, peer)
+		}
+	}
+	for peer := range l.buckets {
+		if len(l.buckets) < maxTrackedPeers {
+			break
+		}
+		delete(l.buckets, peer)
+	}
 }
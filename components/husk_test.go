@@ -2,6 +2,7 @@ package components
 
 import (
 	"testing"
+	"time"
 )
 
 type sProtocolsTestStruct struct {
@@ -47,3 +48,29 @@ func TestSProtocols(t *testing.T) {
 		}
 	}
 }
+
+// A peer gets its burst, then a token per 1/PerSecond, and is told how long it
+// must wait; another peer's allowance is its own.
+func TestRateLimiterTake(t *testing.T) {
+	var l RateLimiter
+	limit := RateLimit{PerSecond: 2, Burst: 3}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Take("a", limit, now); !ok {
+			t.Fatalf("request %d of a burst of 3 was refused", i+1)
+		}
+	}
+	ok, wait := l.Take("a", limit, now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("past the burst: ok %v, wait %s; want refused for 500ms", ok, wait)
+	}
+	if ok, _ := l.Take("b", limit, now); !ok {
+		t.Error("a second peer was refused for the first one's calls")
+	}
+	if ok, _ := l.Take("a", limit, now.Add(500*time.Millisecond)); !ok {
+		t.Error("the peer was still refused once its wait was over")
+	}
+	if ok, _ := l.Take("a", RateLimit{}, now); !ok {
+		t.Error("a zero limit refused a request")
+	}
+}
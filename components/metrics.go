@@ -0,0 +1,207 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package components
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metrics holds the numbers a system keeps about itself, for a scraper to read
+// in the Prometheus text format.
+//
+// Written by hand rather than taken from the Prometheus client library: the
+// framework has no dependencies beyond the standard library, and what a system
+// needs — counters, gauges, histograms with fixed buckets — is a map and a lock.
+//
+// The zero value is ready to use, and so is a nil *Metrics, which records
+// nothing. A system assembled in a test without a husk still calls the code that
+// counts.
+type Metrics struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+// Labels distinguish the series of one metric. Values must come from a bounded
+// set — an asset name, a service definition, a status — never from a caller's
+// path or body: every distinct value is a series held for the life of the
+// process.
+type Labels map[string]string
+
+// DefaultBuckets are the histogram upper bounds, in seconds. From a request
+// answered on the same host to one that waits out the client's timeout.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+type metricFamily struct {
+	kind   string // "counter", "gauge" or "histogram"
+	help   string
+	series map[string]*metricSeries // by rendered label set
+}
+
+type metricSeries struct {
+	value   float64  // a counter's or a gauge's
+	buckets []uint64 // a histogram's, cumulative, one per DefaultBuckets entry
+	sum     float64
+	count   uint64
+}
+
+// Add increases a counter.
+func (m *Metrics) Add(name, help string, labels Labels, delta float64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seriesOf("counter", name, help, labels).value += delta
+}
+
+// Set sets a gauge.
+func (m *Metrics) Set(name, help string, labels Labels, value float64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seriesOf("gauge", name, help, labels).value = value
+}
+
+// Observe records one value in a histogram.
+func (m *Metrics) Observe(name, help string, labels Labels, value float64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.seriesOf("histogram", name, help, labels)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(DefaultBuckets))
+	}
+	for i, bound := range DefaultBuckets {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// seriesOf finds or makes a series. A name is one kind of metric for good: the
+// first use decides it, and the text format has no way to say otherwise.
+// Callers hold the lock.
+func (m *Metrics) seriesOf(kind, name, help string, labels Labels) *metricSeries {
+	if m.families == nil {
+		m.families = make(map[string]*metricFamily)
+	}
+	f, ok := m.families[name]
+	if !ok {
+		f = &metricFamily{kind: kind, help: help, series: make(map[string]*metricSeries)}
+		m.families[name] = f
+	}
+	key := renderLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WritePrometheus writes every metric in the Prometheus text exposition format,
+// version 0.0.4, sorted so that two scrapes of an idle system are identical.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if m != nil {
+		m.mu.Lock()
+		names := make([]string, 0, len(m.families))
+		for name := range m.families {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			m.families[name].write(bw, name)
+		}
+		m.mu.Unlock()
+	}
+	return bw.Flush()
+}
+
+func (f *metricFamily) write(w *bufio.Writer, name string) {
+	w.WriteString("# HELP " + name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help) + "\n")
+	w.WriteString("# TYPE " + name + " " + f.kind + "\n")
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			w.WriteString(name + braced(key) + " " + formatFloat(s.value) + "\n")
+			continue
+		}
+		for i, bound := range DefaultBuckets {
+			w.WriteString(name + "_bucket" + braced(joinLabels(key, `le="`+formatFloat(bound)+`"`)) +
+				" " + strconv.FormatUint(s.buckets[i], 10) + "\n")
+		}
+		w.WriteString(name + "_bucket" + braced(joinLabels(key, `le="+Inf"`)) + " " + strconv.FormatUint(s.count, 10) + "\n")
+		w.WriteString(name + "_sum" + braced(key) + " " + formatFloat(s.sum) + "\n")
+		w.WriteString(name + "_count" + braced(key) + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+// renderLabels writes a label set as it appears between the braces, sorted by
+// name so that the same set is always the same series.
+func renderLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escape.Replace(labels[name]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(key, extra string) string {
+	if key == "" {
+		return extra
+	}
+	return key + "," + extra
+}
+
+func braced(key string) string {
+	if key == "" {
+		return ""
+	}
+	return "{" + key + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,47 @@
+package components
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetricsRenderInTheTextFormat(t *testing.T) {
+	var m Metrics
+	m.Add("requests_total", "Requests.", Labels{"status": "200", "asset": "a"}, 1)
+	m.Add("requests_total", "Requests.", Labels{"asset": "a", "status": "200"}, 2)
+	m.Set("subscribers", "Followers.", nil, 4)
+	m.Observe("latency_seconds", "Latency.", Labels{"cervice": `say "hi"`}, 0.2)
+
+	var out strings.Builder
+	if err := m.WritePrometheus(&out); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		"# TYPE requests_total counter\n",
+		`requests_total{asset="a",status="200"} 3` + "\n", // one series, whatever the label order
+		"# TYPE subscribers gauge\nsubscribers 4\n",
+		`latency_seconds_bucket{cervice="say \"hi\"",le="0.1"} 0` + "\n",
+		`latency_seconds_bucket{cervice="say \"hi\"",le="0.25"} 1` + "\n",
+		`latency_seconds_bucket{cervice="say \"hi\"",le="+Inf"} 1` + "\n",
+		`latency_seconds_count{cervice="say \"hi\""} 1` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "latency_seconds") > strings.Index(text, "requests_total") {
+		t.Error("metrics are not sorted by name")
+	}
+}
+
+// A system built without a husk still runs the code that counts.
+func TestNilMetricsRecordNothing(t *testing.T) {
+	var m *Metrics
+	m.Add("x", "", nil, 1)
+	m.Observe("y", "", nil, 1)
+	var out strings.Builder
+	if err := m.WritePrometheus(&out); err != nil || out.Len() != 0 {
+		t.Errorf("nil metrics wrote %q, %v", out.String(), err)
+	}
+}
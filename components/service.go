@@ -24,6 +24,8 @@ package components
 
 import (
 	"net/http"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -104,6 +106,15 @@ type Service struct {
 	// to know about it is only whether it can be followed and how to answer
 	// somebody who wants to.
 	Stream ValueStream `json:"-"`
+	// History is how many of the latest readings the framework keeps for this
+	// service, to answer a GET that asks for a range of them — from, to, limit —
+	// with a TimeSeries_v1. Zero keeps none, and such a GET is the service's own
+	// to answer.
+	History int `json:"history,omitempty"`
+	// MaxBody is the largest request body this service accepts, in bytes. Zero
+	// means the husk's, or the framework's default; a service that is sent
+	// images or models, rather than forms, raises it here.
+	MaxBody int64 `json:"maxBody,omitempty"`
 
 	ACost      float64 `json:"-"`        // activity cost to execute the service
 	CUnit      string  `json:"costUnit"` // cost unit
@@ -221,6 +232,62 @@ func (c *Cervice) StartFollowing() bool {
 	return true
 }
 
+// Following reports whether a subscription to this cervice's provider is being
+// kept up. Whether it is delivering is Recall's to say.
+func (c *Cervice) Following() bool {
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+	return c.following
+}
+
+// Circuit returns what is kept for the provider at url, made by create the
+// first time it is asked for. What it is belongs to usecases, which keeps a
+// circuit breaker here: on the cervice, so that two cervices with their own
+// terms keep their own counts, and for as long as the cervice knows the
+// provider (see PruneCircuits).
+func (c *Cervice) Circuit(url string, create func() any) any {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	if kept, ok := c.circuits[url]; ok {
+		return kept
+	}
+	if c.circuits == nil {
+		c.circuits = make(map[string]any)
+	}
+	kept := create()
+	c.circuits[url] = kept
+	return kept
+}
+
+// PruneCircuits forgets what Circuit kept for providers no longer in Nodes.
+//
+// Discovery calls it once it has dropped the providers the registrar stopped
+// listing. Not when a failed call empties Nodes: those providers are still
+// registered and are found again on the next search, and a sensor that is down
+// must come back with its circuit still open rather than be asked afresh.
+func (c *Cervice) PruneCircuits() {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	for url := range c.circuits {
+		if !c.knows(url) {
+			delete(c.circuits, url)
+		}
+	}
+}
+
+// knows reports whether url is one of the cervice's providers. Callers hold
+// the lock.
+func (c *Cervice) knows(url string) bool {
+	for _, nodes := range c.Nodes {
+		for _, ni := range nodes {
+			if ni.URL == url {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // ValueStream is a service's value as something to follow rather than to ask
 // for.
 type ValueStream interface {
@@ -269,6 +336,7 @@ func (s Service) DeepCopy() *Service {
 
 		FastestHeartbeat: s.FastestHeartbeat,
 		FinestThreshold:  s.FinestThreshold,
+		History:          s.History,
 
 		ACost: s.ACost,
 		CUnit: s.CUnit,
@@ -356,6 +424,12 @@ type Cervice struct {
 	Nodes       map[string][]NodeInfo
 	Protos      []string
 	Mode        string // "get" for GetState, "set" for SetState, "" for unspecified
+	// FormVersion is the version of the form the consumer works in, such as
+	// "SignalA_v1.0"; empty takes whatever the provider sends. It is asked for
+	// in the Accept header, and a provider on another version is migrated to it
+	// on arrival (forms.Migrate), so a consumer written against one version
+	// keeps working while its providers move on.
+	FormVersion string
 
 	// followed is the value a subscription last delivered, kept as the bytes that
 	// arrived rather than as a parsed form.
@@ -396,7 +470,36 @@ type Cervice struct {
 	// the loop's own ticker remains the guarantee that it is acted upon.
 	WakeFloor time.Duration
 
-	// Mutex guards Nodes and the tokens inside it.
+	// Fanout is how many providers a GetStates round asks at once. Zero means
+	// the default.
+	Fanout int
+	// ProviderTimeout is how long a GetStates round waits for any one
+	// provider, and RoundTimeout how long it waits for all of them. Zero means
+	// the default. A sensor that does not answer costs the round its own
+	// timeout, not every other provider's answer.
+	ProviderTimeout time.Duration
+	RoundTimeout    time.Duration
+	// BreakerThreshold is how many calls running a provider may fail, unreached
+	// or busy, before it is left alone for BreakerCooldown. Zero means the
+	// default.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Retry is how a GetState, SetState or Invoke that fails is tried again.
+	// The zero policy tries once.
+	Retry RetryPolicy
+
+	// Selector picks which provider a GetState or SetState goes to, and which
+	// one is followed (by Peek), where more than one could answer. Nil takes
+	// the first, by node. See usecases for the strategies.
+	Selector ProviderSelector
+
+	// circuits is what usecases keeps per provider, by URL: its circuit
+	// breaker. See Circuit.
+	circuits map[string]any
+
+	// Mutex guards Nodes and the tokens inside it, and the circuits kept for
+	// them.
 	//
 	// Discovery replaces entries and now deletes them, consumption reads them to
 	// dispatch and writes back to forget a token, and a unit asset with more
@@ -412,23 +515,93 @@ type Cervice struct {
 	Mutex sync.RWMutex
 }
 
-// Providers returns the discovered providers as a snapshot.
+// RetryPolicy says how many times a call is attempted and how long to wait
+// between attempts. The wait doubles from FirstWait up to MaxWait, and is
+// jittered so that consumers failing together do not retry together.
+type RetryPolicy struct {
+	Attempts  int           // in all, the first included; zero or one is no retry
+	FirstWait time.Duration // before the second attempt; zero means the default
+	MaxWait   time.Duration // the longest wait, and the longest Retry-After honoured; zero means the default
+	// Statuses are the provider answers worth another attempt. Nil means 408,
+	// 429, 502, 503 and 504; a provider that could not be reached is always
+	// worth one.
+	Statuses []int
+	// Idempotent says an invocation (a POST) may be repeated. A read or a write
+	// of state may always be: asking twice, or setting the same value twice,
+	// leaves the provider as once would have.
+	Idempotent bool
+}
+
+// ProviderSelector chooses among the providers of a cervice that could answer a
+// call, and is told how each call went so it can choose better next time.
+// Its methods are called from every goroutine that uses the cervice.
+type ProviderSelector interface {
+	// Select returns the index in candidates, which is never empty, of the
+	// provider to call.
+	Select(candidates []NodeInfo) int
+	// Peek returns the index Select would, without taking the turn: a
+	// round-robin does not advance and a sticky choice is not made. A
+	// subscription asks it, because following a value is not a call, and
+	// spending a turn on every attempt to follow would move the calls
+	// somewhere else.
+	Peek(candidates []NodeInfo) int
+	// Observe reports one call to the provider at url: how long it took, and
+	// whether it failed.
+	Observe(url string, took time.Duration, err error)
+}
+
+// Providers returns the discovered providers as a snapshot, ordered by node and
+// then by URL.
 //
 // A copy, so the caller can make its requests without holding the lock. A
-// consuming round asks every provider in turn, each request bounded only by its
-// own timeout, and a discovery on another goroutine must not have to wait for
-// an unresponsive sensor before it can record what it found.
+// consuming round asks every provider, each request bounded only by its own
+// timeout, and a discovery on another goroutine must not have to wait for an
+// unresponsive sensor before it can record what it found. Ordered, so that the
+// same providers come back in the same order from one round to the next rather
+// than in the order a map happens to be ranged.
 func (c *Cervice) Providers() []NodeInfo {
 	c.Mutex.RLock()
 	defer c.Mutex.RUnlock()
 
 	var providers []NodeInfo
-	for _, nodes := range c.Nodes {
-		providers = append(providers, nodes...)
+	for _, node := range c.nodeNames() {
+		providers = append(providers, c.nodeProviders(node)...)
+	}
+	return providers
+}
+
+// ProvidersByNode is Providers with the node each was discovered under.
+func (c *Cervice) ProvidersByNode() (nodes []string, providers []NodeInfo) {
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+
+	for _, node := range c.nodeNames() {
+		for _, ni := range c.nodeProviders(node) {
+			nodes = append(nodes, node)
+			providers = append(providers, ni)
+		}
 	}
+	return nodes, providers
+}
+
+// nodeProviders is a copy of one node's providers ordered by URL, not in the
+// order the registrar happened to list them. The caller holds the lock.
+func (c *Cervice) nodeProviders(node string) []NodeInfo {
+	providers := slices.Clone(c.Nodes[node])
+	slices.SortStableFunc(providers, func(a, b NodeInfo) int { return strings.Compare(a.URL, b.URL) })
 	return providers
 }
 
+// nodeNames are the keys of Nodes in order. The caller holds the lock.
+func (c *Cervice) nodeNames() []string {
+	names := make([]string, 0, len(c.Nodes))
+	for node := range c.Nodes {
+		names = append(names, node)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ProviderCount reports how many providers have been discovered.
 func (c *Cervice) ProviderCount() int {
 	c.Mutex.RLock()
@@ -43,6 +43,57 @@ type System struct {
 	Ctx     context.Context       // create a context that can be canceled
 	Sigs    chan os.Signal        // channel to initiate a graceful shutdown when Ctrl+C is pressed
 	Mutex   *sync.Mutex           // used in service provision and consumption to avoid race conditions
+	// Middleware wraps the handling of every request to this system's unit
+	// assets, in the order it was added: the first is the outermost. See Use.
+	Middleware []Middleware
+	// Traces is where this system's finished trace spans are sent. Nil sends
+	// them nowhere; the trace context is propagated to providers regardless.
+	Traces SpanExporter
+	// Cleanups run last in an ordered shutdown, once nothing is being served.
+	// See AtShutdown.
+	Cleanups []func()
+}
+
+// AtShutdown adds what must be done before the process exits — a device
+// released, a file flushed, a connection to a broker closed. usecases.Shutdown
+// runs them after the services are deregistered and the requests in flight have
+// drained, so a unit asset is never torn down under a request still using it.
+// They run last added first, as deferred calls do: an asset added later may
+// depend on one added earlier.
+func (s *System) AtShutdown(cleanup ...func()) {
+	if s.Mutex != nil {
+		s.Mutex.Lock()
+		defer s.Mutex.Unlock()
+	}
+	s.Cleanups = append(s.Cleanups, cleanup...)
+}
+
+// Middleware wraps a system's request handling with something that applies to
+// every request — a request ID, panic recovery, an access log, a rate limit,
+// CORS headers, a metric.
+//
+// The standard library's own shape, so a handler wrapper written for any other
+// Go server can be added as it is.
+type Middleware func(http.Handler) http.Handler
+
+// Use adds middleware to the system's request handling. Call it before
+// usecases.SetoutServers: the list is read on every request and is not guarded.
+//
+// The order is defined and it matters. Middleware runs in the order it was
+// added, each around the next, and the framework's authorization stage always
+// comes after all of it, followed only by form validation and then the unit
+// asset being asked to serve. So a middleware sees every request, refused or
+// not — an access log records the refusals, a rate limit is applied before a
+// token is verified, and a CORS preflight can be answered without carrying one.
+// What none of them can do is serve a service the authorizer has not
+// sanctioned: that decision is taken after them.
+//
+// Validation may sit between the two because it serves nothing: it only reads
+// the payload of a request already authorized, and can refuse it but never let
+// it through. Placed after authorization, it also tells a caller without a
+// token nothing about what a payload should look like.
+func (s *System) Use(mw ...Middleware) {
+	s.Middleware = append(s.Middleware, mw...)
 }
 
 // CoreSystem struct holds details about the core system included in the configuration file
@@ -57,7 +108,9 @@ func NewSystem(name string, ctx context.Context) System {
 	newSystem := System{Name: name}
 	newSystem.Ctx = ctx
 	newSystem.Sigs = make(chan os.Signal, 1)
-	signal.Notify(newSystem.Sigs, syscall.SIGINT)
+	// SIGTERM is what a container runtime or a service manager sends, and
+	// ignoring it had the system killed outright once the grace period ran out.
+	signal.Notify(newSystem.Sigs, syscall.SIGINT, syscall.SIGTERM)
 	newSystem.UAssets = make(map[string]*UnitAsset) // initialize UAsset as an empty map
 	// Since the return System isn't a pointer (incorrectly), this map needs to
 	// be a pointer instead (usually not normal) and initialized (usually not needed)
@@ -0,0 +1,52 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package components
+
+import "time"
+
+// SpanData is one finished span of a distributed trace: a piece of work, where
+// it sits in the trace, how long it took and whether it failed.
+//
+// The identifiers are those of W3C Trace Context, in lower-case hex, so a span
+// recorded here joins the same trace as the ones its callers and providers
+// recorded, whatever collects them.
+type SpanData struct {
+	TraceID    string            `json:"traceId"`
+	SpanID     string            `json:"spanId"`
+	ParentID   string            `json:"parentSpanId,omitempty"`
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`   // SpanServer, SpanClient or SpanInternal
+	System     string            `json:"system"` // the system that recorded it
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// What a span stands for, in OpenTelemetry's terms.
+const (
+	SpanServer   = "server"   // answering a request
+	SpanClient   = "client"   // making one
+	SpanInternal = "internal" // work between the two
+)
+
+// SpanExporter takes finished spans somewhere they can be read. ExportSpan is
+// called on the request path, so it must not block on the network; an exporter
+// that sends spans away buffers them and sends from a goroutine of its own.
+type SpanExporter interface {
+	ExportSpan(SpanData)
+}
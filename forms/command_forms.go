@@ -0,0 +1,115 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package forms
+
+// Commands: an instruction with its arguments, and the provider's answer to it.
+
+import (
+	"encoding/xml"
+	"reflect"
+	"time"
+)
+
+// Command_v1 asks a provider to do something — start, stop, home, calibrate —
+// rather than to take a value. ID is the sender's, and comes back on the
+// acknowledgement, so a sender with several commands in flight can tell which
+// was answered.
+//
+// The arguments are a list of name and value, not a map: a map has no XML
+// encoding, and a command that cannot cross the wire in one of the encodings a
+// provider negotiates is one that fails only for the peers that ask for it.
+type Command_v1 struct {
+	XMLName   xml.Name          `json:"-" xml:"Command"`
+	ID        string            `json:"id" xml:"id"`
+	Command   string            `json:"command" xml:"command" jsonschema:"minLength=1"`
+	Arguments []CommandArgument `json:"arguments,omitempty" xml:"argument,omitempty"`
+	Timestamp time.Time         `json:"timestamp" xml:"timestamp"`
+	Version   string            `json:"version" xml:"version"`
+}
+
+// CommandArgument is one named argument of a command.
+type CommandArgument struct {
+	Name  string `json:"name" xml:"name,attr" jsonschema:"minLength=1"`
+	Value string `json:"value" xml:",chardata"`
+}
+
+// NewForm creates a new form of type Command
+func (c *Command_v1) NewForm() Form {
+	c.Version = "Command_v1"
+	return c
+}
+
+// FormVersion returns the version of the form
+func (c *Command_v1) FormVersion() string {
+	return c.Version
+}
+
+// Argument returns the value of the named argument, and whether it was given.
+func (c *Command_v1) Argument(name string) (string, bool) {
+	for _, a := range c.Arguments {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// The states a command can be acknowledged in. Accepted says the provider has
+// taken it on and will answer again when it is done; rejected that it never
+// will; completed and failed are how it ended.
+const (
+	CommandAccepted  = "accepted"
+	CommandRejected  = "rejected"
+	CommandCompleted = "completed"
+	CommandFailed    = "failed"
+)
+
+// CommandAck_v1 is a provider's answer to a command: which one, and what became
+// of it. Detail is the reason, for anything but success.
+type CommandAck_v1 struct {
+	XMLName   xml.Name  `json:"-" xml:"CommandAck"`
+	ID        string    `json:"id" xml:"id"`
+	Command   string    `json:"command" xml:"command"`
+	Status    string    `json:"status" xml:"status" jsonschema:"enum=accepted,enum=rejected,enum=completed,enum=failed"`
+	Detail    string    `json:"detail,omitempty" xml:"detail,omitempty"`
+	Timestamp time.Time `json:"timestamp" xml:"timestamp"`
+	Version   string    `json:"version" xml:"version"`
+}
+
+// NewForm creates a new form of type CommandAck
+func (a *CommandAck_v1) NewForm() Form {
+	a.Version = "CommandAck_v1"
+	return a
+}
+
+// FormVersion returns the version of the form
+func (a *CommandAck_v1) FormVersion() string {
+	return a.Version
+}
+
+// Acknowledge answers the command with a status, and the reason for it.
+func (c *Command_v1) Acknowledge(status, detail string) *CommandAck_v1 {
+	ack := &CommandAck_v1{ID: c.ID, Command: c.Command, Status: status, Detail: detail, Timestamp: time.Now()}
+	ack.NewForm()
+	return ack
+}
+
+// Register the command forms in the formTypeMap
+func init() {
+	FormTypeMap["Command_v1"] = reflect.TypeOf(Command_v1{})
+	FormTypeMap["CommandAck_v1"] = reflect.TypeOf(CommandAck_v1{})
+}
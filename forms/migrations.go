@@ -0,0 +1,113 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package forms
+
+// Migrations: how one version of a form becomes another.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Migration turns a form of one version into the same information in another.
+// It returns a new form and leaves the one it was given alone.
+type Migration func(Form) (Form, error)
+
+// ErrNoMigration is what Migrate returns when no chain of registered migrations
+// leads from one version to the other.
+var ErrNoMigration = errors.New("no migration between these form versions")
+
+// migrations holds the registered steps, by the version they start from and
+// the version they reach. Written by init functions, like FormTypeMap, and only
+// read afterwards.
+var migrations = make(map[string]map[string]Migration)
+
+// RegisterMigration records how a form of version from becomes version to. A
+// new version registers both directions beside its own registration, one step
+// each way to its predecessor:
+//
+//	func init() {
+//	    FormTypeMap["ServiceRecord_v2"] = reflect.TypeOf(ServiceRecord_v2{})
+//	    RegisterMigration("ServiceRecord_v1", "ServiceRecord_v2", recordV1toV2)
+//	    RegisterMigration("ServiceRecord_v2", "ServiceRecord_v1", recordV2toV1)
+//	}
+//
+// Steps chain, so v1 reaches v3 through v2 without a migration written for the
+// pair.
+func RegisterMigration(from, to string, m Migration) {
+	if migrations[from] == nil {
+		migrations[from] = make(map[string]Migration)
+	}
+	migrations[from][to] = m
+}
+
+// Migrate makes f into a form of version to, by the shortest chain of
+// registered migrations. A form already of that version is returned as it is.
+func Migrate(f Form, to string) (Form, error) {
+	from := f.FormVersion()
+	if from == to {
+		return f, nil
+	}
+	path := migrationPath(from, to)
+	if path == nil {
+		return nil, fmt.Errorf("%w: %s to %s", ErrNoMigration, from, to)
+	}
+	for i := 1; i < len(path); i++ {
+		next, err := migrations[path[i-1]][path[i]](f)
+		if err != nil {
+			return nil, fmt.Errorf("migrating %s to %s: %w", path[i-1], path[i], err)
+		}
+		if next.FormVersion() != path[i] {
+			// A migration that forgot to set the version would have every step
+			// after it start from the wrong place.
+			return nil, fmt.Errorf("migrating %s to %s produced %s", path[i-1], path[i], next.FormVersion())
+		}
+		f = next
+	}
+	return f, nil
+}
+
+// migrationPath is the versions from from to to, both included, along the
+// fewest registered steps; nil if there is none.
+func migrationPath(from, to string) []string {
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		version := queue[0]
+		queue = queue[1:]
+		if version == to {
+			path := []string{to}
+			for v := previous[to]; v != ""; v = previous[v] {
+				path = append([]string{v}, path...)
+			}
+			return path
+		}
+		steps := make([]string, 0, len(migrations[version]))
+		for next := range migrations[version] {
+			steps = append(steps, next)
+		}
+		sort.Strings(steps) // the same chain every time, where two are as short
+		for _, next := range steps {
+			if _, seen := previous[next]; !seen {
+				previous[next] = version
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
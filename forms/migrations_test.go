@@ -0,0 +1,81 @@
+package forms
+
+import (
+	"errors"
+	"testing"
+)
+
+// note is a form whose version is whatever it is told, so one type stands in for
+// every version of it.
+type note struct {
+	Text    string `json:"text"`
+	Version string `json:"version"`
+}
+
+func (n *note) NewForm() Form       { return n }
+func (n *note) FormVersion() string { return n.Version }
+
+// step is a migration to version to that marks the text with each step taken.
+func step(to string) Migration {
+	return func(f Form) (Form, error) {
+		return &note{Text: f.(*note).Text + ">" + to, Version: to}, nil
+	}
+}
+
+func withMigrations(t *testing.T, steps map[[2]string]Migration) {
+	t.Helper()
+	for pair, m := range steps {
+		RegisterMigration(pair[0], pair[1], m)
+	}
+	t.Cleanup(func() {
+		for pair := range steps {
+			delete(migrations[pair[0]], pair[1])
+		}
+	})
+}
+
+// Steps chain, along the shortest way, and leave the form they were given alone.
+func TestMigrateChainsSteps(t *testing.T) {
+	withMigrations(t, map[[2]string]Migration{
+		{"Note_v1", "Note_v2"}: step("Note_v2"),
+		{"Note_v2", "Note_v3"}: step("Note_v3"),
+		{"Note_v3", "Note_v2"}: step("Note_v2"),
+		{"Note_v2", "Note_v1"}: step("Note_v1"),
+		{"Note_v1", "Note_v4"}: step("Note_v4"),
+		{"Note_v4", "Note_v3"}: step("Note_v3"),
+	})
+	old := &note{Text: "v1", Version: "Note_v1"}
+	got, err := Migrate(old, "Note_v3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := got.(*note); n.Version != "Note_v3" || n.Text != "v1>Note_v2>Note_v3" {
+		t.Errorf("migrated to %+v", n)
+	}
+	if old.Text != "v1" || old.Version != "Note_v1" {
+		t.Errorf("the original became %+v", old)
+	}
+
+	back, err := Migrate(got, "Note_v1")
+	if err != nil || back.(*note).Text != "v1>Note_v2>Note_v3>Note_v2>Note_v1" {
+		t.Errorf("migrated back to %+v, %v", back, err)
+	}
+
+	if same, err := Migrate(old, "Note_v1"); same != old || err != nil {
+		t.Errorf("to its own version: %v, %v", same, err)
+	}
+}
+
+// No chain, or a step that does not deliver the version it was registered for,
+// is an error rather than a form of the wrong version.
+func TestMigrateRefusesWhatItCannotDo(t *testing.T) {
+	withMigrations(t, map[[2]string]Migration{
+		{"Note_v1", "Note_v2"}: step("Note_v5"),
+	})
+	if _, err := Migrate(&note{Version: "Note_v2"}, "Note_v1"); !errors.Is(err, ErrNoMigration) {
+		t.Errorf("no way back: %v", err)
+	}
+	if _, err := Migrate(&note{Version: "Note_v1"}, "Note_v2"); err == nil || errors.Is(err, ErrNoMigration) {
+		t.Errorf("a step that lands on the wrong version: %v", err)
+	}
+}
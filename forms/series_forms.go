@@ -0,0 +1,100 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package forms
+
+// Series: many readings of one value, for loggers and historians.
+
+import (
+	"encoding/xml"
+	"reflect"
+	"time"
+)
+
+// The quality of a reading, after OPC UA's three severities. Good is what a
+// reading that says nothing is taken to be.
+const (
+	QualityGood      = "good"
+	QualityUncertain = "uncertain"
+	QualityBad       = "bad"
+)
+
+// TimeSeries_v1 is a run of readings of one value, in one unit, oldest first.
+// What a logger or a historian asks for: a thousand samples are one form and
+// one request, not a thousand.
+//
+// Truncated says the service had more readings in the range asked for than it
+// was allowed to send, and sent the latest; a reader paging back asks again
+// with the range ending before the first one it was sent.
+type TimeSeries_v1 struct {
+	XMLName   xml.Name     `json:"-" xml:"TimeSeries"`
+	Unit      string       `json:"unit" xml:"unit"`
+	Samples   []TimedValue `json:"samples" xml:"sample"`
+	Truncated bool         `json:"truncated,omitempty" xml:"truncated,omitempty"`
+	Version   string       `json:"version" xml:"version"`
+}
+
+// TimedValue is one reading of a series: the value, when it was taken, and its
+// quality where it was anything but good.
+type TimedValue struct {
+	Value     float64   `json:"value" xml:"value"`
+	Timestamp time.Time `json:"timestamp" xml:"timestamp"`
+	Quality   string    `json:"quality,omitempty" xml:"quality,omitempty" jsonschema:"enum=good,enum=uncertain,enum=bad"`
+}
+
+// NewForm creates a new form of type TimeSeries
+func (s *TimeSeries_v1) NewForm() Form {
+	s.Version = "TimeSeries_v1"
+	return s
+}
+
+// FormVersion returns the version of the form
+func (s *TimeSeries_v1) FormVersion() string {
+	return s.Version
+}
+
+// A series is a VectorBearer: its values are in one unit and convert together,
+// all of them or none.
+
+// GetUnit returns the unit the values are expressed in.
+func (s *TimeSeries_v1) GetUnit() string { return s.Unit }
+
+// SetUnit records the unit the values are expressed in.
+func (s *TimeSeries_v1) SetUnit(u string) { s.Unit = u }
+
+// GetValues returns the values of the samples, in order.
+func (s *TimeSeries_v1) GetValues() []float64 {
+	values := make([]float64, len(s.Samples))
+	for i, sample := range s.Samples {
+		values[i] = sample.Value
+	}
+	return values
+}
+
+// SetValues replaces the values of the samples, in order, and leaves their
+// times and qualities alone.
+func (s *TimeSeries_v1) SetValues(values []float64) {
+	for i := range s.Samples {
+		if i < len(values) {
+			s.Samples[i].Value = values[i]
+		}
+	}
+}
+
+// Register TimeSeries_v1 in the formTypeMap
+func init() {
+	FormTypeMap["TimeSeries_v1"] = reflect.TypeOf(TimeSeries_v1{})
+}
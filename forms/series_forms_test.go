@@ -0,0 +1,35 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package forms
+
+import "testing"
+
+// The values of a series convert in place and leave the times where they were.
+func TestTimeSeriesIsAVector(t *testing.T) {
+	var series TimeSeries_v1
+	if series.NewForm().FormVersion() != "TimeSeries_v1" || FormTypeMap["TimeSeries_v1"] == nil {
+		t.Fatal("TimeSeries_v1 is not registered")
+	}
+	series.Samples = []TimedValue{{Value: 1, Quality: QualityGood}, {Value: 2, Quality: QualityBad}}
+	var bearer VectorBearer = &series
+	values := bearer.GetValues()
+	values[0] = 99 // a copy, not the samples
+	bearer.SetValues([]float64{10, 20})
+	if series.Samples[0].Value != 10 || series.Samples[1].Value != 20 || series.Samples[1].Quality != QualityBad {
+		t.Errorf("samples = %+v", series.Samples)
+	}
+}
@@ -22,7 +22,7 @@ import "reflect"
 
 type ServiceRecord_v1 struct {
 	Id                int                 `json:"registryID"`
-	ServiceDefinition string              `json:"definition"`
+	ServiceDefinition string              `json:"definition" jsonschema:"minLength=1"`
 	SystemName        string              `json:"systemName"`
 	ServiceNode       string              `json:"serviceNode"`
 	Mission           string              `json:"mission,omitempty"`
@@ -39,6 +39,9 @@ type ServiceRecord_v1 struct {
 	SubscribeAble     bool                `json:"subscribeAble"`
 	ACost             float64             `json:"activityCost"`
 	CUnit             string              `json:"costUnit"`
+	// LocalSockets are the Unix domain sockets the service is also served on,
+	// by protocol. Only a consumer on the same host can use them.
+	LocalSockets map[string]string `json:"localSockets,omitempty"`
 }
 
 func (f *ServiceRecord_v1) NewForm() Form {
@@ -115,12 +118,12 @@ const (
 // on demand turns any number of events into one piece of work.
 type RegistryEvent_v1 struct {
 	// Change is RegistryRegistered or RegistryDeregistered.
-	Change string `json:"change"`
+	Change string `json:"change" jsonschema:"enum=registered,enum=deregistered"`
 	// Record is the service registration this event concerns. On a
 	// deregistration it is the record as it last stood.
 	Record ServiceRecord_v1 `json:"record"`
 	// Timestamp is when the registry observed the change.
-	Timestamp string `json:"timestamp"`
+	Timestamp string `json:"timestamp" jsonschema:"format=date-time"`
 	Version   string `json:"version"`
 }
 
@@ -41,7 +41,7 @@ type ServiceQuest_v1 struct {
 	SysId             int                 `json:"systemId"`
 	RequesterName     string              `json:"requesterName"`
 	ProviderName      string              `json:"providerName,omitempty"`
-	ServiceDefinition string              `json:"serviceDefinition"`
+	ServiceDefinition string              `json:"serviceDefinition" jsonschema:"minLength=1"`
 	Action            string              `json:"action,omitempty"`
 	Protocol          string              `json:"protocol"`
 	Details           map[string][]string `json:"details"`
@@ -75,8 +75,11 @@ type ServicePoint_v1 struct {
 	// SubscribeAble says this provider will let a consumer follow the value
 	// rather than ask for it repeatedly. Carried here because it is the consumer
 	// that decides whether to follow, and this is what the consumer is handed.
-	SubscribeAble bool   `json:"subscribeAble,omitempty"`
-	Version       string `json:"version"`
+	SubscribeAble bool `json:"subscribeAble,omitempty"`
+	// LocalSocket is a Unix domain socket serving ServLocation's protocol, for
+	// a consumer that finds the provider's address is one of its own.
+	LocalSocket string `json:"localSocket,omitempty"`
+	Version     string `json:"version"`
 }
 
 func (f *ServicePoint_v1) NewForm() Form {
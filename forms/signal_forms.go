@@ -73,6 +73,104 @@ func init() {
 	FormTypeMap["SignalB_v1.0"] = reflect.TypeOf(SignalB_v1a{})
 }
 
+// SignalA_v2a is an analog signal that says how far it can be trusted and where
+// it came from.
+//
+// Quality is OPC UA's severity — good, uncertain or bad — and Condition the
+// reason for anything but good: the reading is stale, substituted for one that
+// could not be taken, from an instrument out of calibration, or simulated. A
+// control loop that cannot tell a simulated reading from a measured one acts on
+// both. An absent quality is good, which is what every SignalA_v1a is taken to
+// be when it is migrated up.
+//
+// Source names what produced the reading — the sensor, not the system serving
+// it — and Uncertainty is its standard uncertainty in the same unit as the
+// value; a pointer, because an uncertainty of zero is a claim and no
+// uncertainty is the absence of one.
+type SignalA_v2a struct {
+	XMLName     xml.Name  `json:"-" xml:"SignalA"`
+	Value       float64   `json:"value" xml:"value"`
+	Unit        string    `json:"unit" xml:"unit"`
+	Timestamp   time.Time `json:"timestamp" xml:"timestamp"`
+	Quality     string    `json:"quality,omitempty" xml:"quality,omitempty" jsonschema:"enum=good,enum=uncertain,enum=bad"`
+	Condition   string    `json:"condition,omitempty" xml:"condition,omitempty" jsonschema:"enum=stale,enum=substituted,enum=uncalibrated,enum=simulated"`
+	Source      string    `json:"source,omitempty" xml:"source,omitempty"`
+	Uncertainty *float64  `json:"uncertainty,omitempty" xml:"uncertainty,omitempty"`
+	Version     string    `json:"version" xml:"version"`
+}
+
+// The conditions that make a reading less than good.
+const (
+	ConditionStale        = "stale"
+	ConditionSubstituted  = "substituted"
+	ConditionUncalibrated = "uncalibrated"
+	ConditionSimulated    = "simulated"
+)
+
+// NewForm creates a new form of type SignalA, version 2
+func (sig *SignalA_v2a) NewForm() Form {
+	sig.Version = "SignalA_v2.0"
+	return sig
+}
+
+// FormVersion returns the version of the form
+func (sig *SignalA_v2a) FormVersion() string {
+	return sig.Version
+}
+
+// Register SignalA_v2a in the formTypeMap, and how a reading moves between the
+// two versions: up with nothing known of its quality, which is good; down
+// without it.
+func init() {
+	FormTypeMap["SignalA_v2.0"] = reflect.TypeOf(SignalA_v2a{})
+	RegisterMigration("SignalA_v1.0", "SignalA_v2.0", func(f Form) (Form, error) {
+		old := f.(*SignalA_v1a)
+		sig := &SignalA_v2a{Value: old.Value, Unit: old.Unit, Timestamp: old.Timestamp}
+		return sig.NewForm(), nil
+	})
+	RegisterMigration("SignalA_v2.0", "SignalA_v1.0", func(f Form) (Form, error) {
+		sig := f.(*SignalA_v2a)
+		old := &SignalA_v1a{Value: sig.Value, Unit: sig.Unit, Timestamp: sig.Timestamp}
+		return old.NewForm(), nil
+	})
+}
+
+// QualityBearer is a form that says how far its reading can be trusted. A
+// publisher reports a change of quality as it reports a change of value.
+type QualityBearer interface {
+	GetQuality() string
+	GetCondition() string
+}
+
+// GetQuality returns the reading's quality, good where it says nothing.
+func (sig *SignalA_v2a) GetQuality() string {
+	if sig.Quality == "" {
+		return QualityGood
+	}
+	return sig.Quality
+}
+
+// GetCondition returns why the reading is less than good, if it is.
+func (sig *SignalA_v2a) GetCondition() string { return sig.Condition }
+
+// UncertaintyBearer is a form whose value has an uncertainty in its unit, which
+// converts with it.
+type UncertaintyBearer interface {
+	GetUncertainty() (float64, bool)
+	SetUncertainty(float64)
+}
+
+// GetUncertainty returns the standard uncertainty, and whether there is one.
+func (sig *SignalA_v2a) GetUncertainty() (float64, bool) {
+	if sig.Uncertainty == nil {
+		return 0, false
+	}
+	return *sig.Uncertainty, true
+}
+
+// SetUncertainty replaces the standard uncertainty.
+func (sig *SignalA_v2a) SetUncertainty(u float64) { sig.Uncertainty = &u }
+
 // UnitBearer is a form carrying a single value whose unit travels with it.
 //
 // Unit normalization in the consumption path works through this interface rather
@@ -97,3 +195,29 @@ func (sig *SignalA_v1a) GetValue() float64 { return sig.Value }
 
 // SetValue replaces the digitised value.
 func (sig *SignalA_v1a) SetValue(v float64) { sig.Value = v }
+
+// Timestamped is a form that says when its reading was taken.
+type Timestamped interface {
+	GetTimestamp() time.Time
+}
+
+// GetTimestamp returns when the value was taken.
+func (sig *SignalA_v1a) GetTimestamp() time.Time { return sig.Timestamp }
+
+// GetTimestamp returns when the value was taken.
+func (sig *SignalB_v1a) GetTimestamp() time.Time { return sig.Timestamp }
+
+// GetUnit returns the unit the value is expressed in.
+func (sig *SignalA_v2a) GetUnit() string { return sig.Unit }
+
+// SetUnit records the unit the value is expressed in.
+func (sig *SignalA_v2a) SetUnit(u string) { sig.Unit = u }
+
+// GetValue returns the digitised value.
+func (sig *SignalA_v2a) GetValue() float64 { return sig.Value }
+
+// SetValue replaces the digitised value.
+func (sig *SignalA_v2a) SetValue(v float64) { sig.Value = v }
+
+// GetTimestamp returns when the value was taken.
+func (sig *SignalA_v2a) GetTimestamp() time.Time { return sig.Timestamp }
@@ -0,0 +1,44 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package forms
+
+import "testing"
+
+// A SignalA_v1.0 is a good reading when it moves up to version 2, and loses its
+// quality and provenance, not its value, when it moves back.
+func TestSignalAMigratesBetweenVersions(t *testing.T) {
+	old := &SignalA_v1a{Value: 21.5, Unit: "Cel"}
+	old.NewForm()
+	up, err := Migrate(old, "SignalA_v2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := up.(*SignalA_v2a)
+	if sig.Value != 21.5 || sig.Unit != "Cel" || sig.GetQuality() != QualityGood || sig.Uncertainty != nil {
+		t.Errorf("migrated up to %+v", sig)
+	}
+
+	sig.Quality, sig.Condition, sig.Source = QualityUncertain, ConditionSubstituted, "pt100-3"
+	sig.SetUncertainty(0.2)
+	down, err := Migrate(sig, "SignalA_v1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back := down.(*SignalA_v1a); back.Value != 21.5 || back.Unit != "Cel" {
+		t.Errorf("migrated down to %+v", back)
+	}
+}
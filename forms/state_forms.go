@@ -0,0 +1,151 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package forms
+
+// States: readings that are more than one number — a position, an operating
+// mode, a line of text.
+
+import (
+	"encoding/xml"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Vector_v1 is several values in one unit taken at the same moment: a position
+// (x, y, z), a velocity, the three phases of a current. One form rather than a
+// SignalA per component, because the components are only meaningful together —
+// three readings arriving separately are a position that was never measured.
+//
+// Labels names the components in order, and may be left out where the service
+// definition already says what they are.
+type Vector_v1 struct {
+	XMLName   xml.Name  `json:"-" xml:"Vector"`
+	Values    []float64 `json:"values" xml:"value"`
+	Labels    []string  `json:"labels,omitempty" xml:"label,omitempty"`
+	Unit      string    `json:"unit" xml:"unit"`
+	Timestamp time.Time `json:"timestamp" xml:"timestamp"`
+	Version   string    `json:"version" xml:"version"`
+}
+
+// NewForm creates a new form of type Vector
+func (v *Vector_v1) NewForm() Form {
+	v.Version = "Vector_v1"
+	return v
+}
+
+// FormVersion returns the version of the form
+func (v *Vector_v1) FormVersion() string {
+	return v.Version
+}
+
+// VectorBearer is a form carrying several values in one unit, which the
+// consumption path converts as it converts a UnitBearer's one.
+type VectorBearer interface {
+	GetUnit() string
+	SetUnit(string)
+	GetValues() []float64
+	SetValues([]float64)
+}
+
+// GetUnit returns the unit the values are expressed in.
+func (v *Vector_v1) GetUnit() string { return v.Unit }
+
+// SetUnit records the unit the values are expressed in.
+func (v *Vector_v1) SetUnit(u string) { v.Unit = u }
+
+// GetValues returns the components, in order.
+func (v *Vector_v1) GetValues() []float64 { return v.Values }
+
+// SetValues replaces the components.
+func (v *Vector_v1) SetValues(values []float64) { v.Values = values }
+
+// EnumState_v1 is a state that is one of a fixed set — an operating mode, a
+// valve's position, a door's. The set travels with the value, so a consumer
+// learns what the asset can be in from any reading of it rather than from
+// documentation; a value outside its own set is a fault of the sender.
+type EnumState_v1 struct {
+	XMLName   xml.Name  `json:"-" xml:"EnumState"`
+	Value     string    `json:"value" xml:"value" jsonschema:"minLength=1"`
+	Allowed   []string  `json:"allowed" xml:"allowed"`
+	Timestamp time.Time `json:"timestamp" xml:"timestamp"`
+	Version   string    `json:"version" xml:"version"`
+}
+
+// NewForm creates a new form of type EnumState
+func (s *EnumState_v1) NewForm() Form {
+	s.Version = "EnumState_v1"
+	return s
+}
+
+// FormVersion returns the version of the form
+func (s *EnumState_v1) FormVersion() string {
+	return s.Version
+}
+
+// Allows reports whether value is one of the states in the set.
+func (s *EnumState_v1) Allows(value string) bool {
+	return slices.Contains(s.Allowed, value)
+}
+
+// TextState_v1 is a state with no fixed set of values: a status line, the name
+// of the batch being run, the last message from a controller.
+type TextState_v1 struct {
+	XMLName   xml.Name  `json:"-" xml:"TextState"`
+	Value     string    `json:"value" xml:"value"`
+	Timestamp time.Time `json:"timestamp" xml:"timestamp"`
+	Version   string    `json:"version" xml:"version"`
+}
+
+// NewForm creates a new form of type TextState
+func (s *TextState_v1) NewForm() Form {
+	s.Version = "TextState_v1"
+	return s
+}
+
+// FormVersion returns the version of the form
+func (s *TextState_v1) FormVersion() string {
+	return s.Version
+}
+
+// StateBearer is a form whose value is a state named in words, which is compared
+// as words: a publisher reports it when it changes, not when it is sampled.
+type StateBearer interface {
+	GetState() string
+}
+
+// GetState returns the state the asset is in.
+func (s *EnumState_v1) GetState() string { return s.Value }
+
+// GetState returns the state the asset is in.
+func (s *TextState_v1) GetState() string { return s.Value }
+
+// GetTimestamp returns when the values were taken.
+func (v *Vector_v1) GetTimestamp() time.Time { return v.Timestamp }
+
+// GetTimestamp returns when the asset was in the state.
+func (s *EnumState_v1) GetTimestamp() time.Time { return s.Timestamp }
+
+// GetTimestamp returns when the asset was in the state.
+func (s *TextState_v1) GetTimestamp() time.Time { return s.Timestamp }
+
+// Register the state forms in the formTypeMap
+func init() {
+	FormTypeMap["Vector_v1"] = reflect.TypeOf(Vector_v1{})
+	FormTypeMap["EnumState_v1"] = reflect.TypeOf(EnumState_v1{})
+	FormTypeMap["TextState_v1"] = reflect.TypeOf(TextState_v1{})
+}
@@ -0,0 +1,81 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package forms
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStateAndCommandFormsAreRegistered(t *testing.T) {
+	for version, f := range map[string]Form{
+		"Vector_v1": &Vector_v1{}, "EnumState_v1": &EnumState_v1{}, "TextState_v1": &TextState_v1{},
+		"Command_v1": &Command_v1{}, "CommandAck_v1": &CommandAck_v1{},
+	} {
+		if got := f.NewForm().FormVersion(); got != version {
+			t.Errorf("version %q, want %q", got, version)
+		}
+		if FormTypeMap[version] != reflect.TypeOf(f).Elem() {
+			t.Errorf("FormTypeMap has no entry for %q", version)
+		}
+	}
+	var _ VectorBearer = &Vector_v1{}
+	var _ StateBearer = &EnumState_v1{}
+	var _ StateBearer = &TextState_v1{}
+}
+
+func TestEnumStateAllows(t *testing.T) {
+	mode := EnumState_v1{Value: "idle", Allowed: []string{"heating", "cooling", "idle"}}
+	if !mode.Allows(mode.Value) || mode.Allows("defrosting") {
+		t.Error("Allows does not follow the set")
+	}
+}
+
+// A command's arguments and its acknowledgement survive JSON and XML alike.
+func TestCommandRoundTrips(t *testing.T) {
+	sent := Command_v1{ID: "42", Command: "home", Arguments: []CommandArgument{{"axis", "z"}, {"speed", "0.2"}},
+		Timestamp: time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC)}
+	sent.NewForm()
+
+	data, _ := json.Marshal(&sent)
+	var back Command_v1
+	if err := json.Unmarshal(data, &back); err != nil || !reflect.DeepEqual(back, sent) {
+		t.Errorf("JSON: %+v, %v", back, err)
+	}
+	data, err := xml.Marshal(&sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromXML Command_v1
+	if err := xml.Unmarshal(data, &fromXML); err != nil || !reflect.DeepEqual(fromXML.Arguments, sent.Arguments) {
+		t.Errorf("XML: %+v, %v\n%s", fromXML, err, data)
+	}
+	if speed, ok := back.Argument("speed"); !ok || speed != "0.2" {
+		t.Errorf("speed = %q, %t", speed, ok)
+	}
+	if _, ok := back.Argument("force"); ok {
+		t.Error("an argument that was not given was found")
+	}
+
+	ack := back.Acknowledge(CommandRejected, "axis z is locked")
+	if ack.ID != "42" || ack.Command != "home" || ack.Status != CommandRejected || ack.FormVersion() != "CommandAck_v1" {
+		t.Errorf("ack = %+v", ack)
+	}
+}
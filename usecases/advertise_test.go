@@ -126,3 +126,42 @@ func TestRegistrationWaitsForAListener(t *testing.T) {
 		t.Errorf("a service with no listener was registered as ID %d", serv.ID)
 	}
 }
+
+// TestAnIPv6ProviderIsReachedAtABracketedAddress covers a provider on a segment
+// that routes only IPv6: its record must lead with the IPv6 address, and the URL
+// a consumer is given must bracket it, or "fd00::7:20150" reads as an address
+// with one group too many and no port at all.
+func TestAnIPv6ProviderIsReachedAtABracketedAddress(t *testing.T) {
+	sys := createTestSystem(false)
+	sys.Husk.Host.IPAddresses = []string{"192.168.1.10", "fd00::7", "127.0.0.1"}
+	sys.Husk.Host.IPPreference = components.PreferIPv6
+	sys.Husk.Bound.Bind("http", 20150)
+
+	var ua *components.UnitAsset
+	for _, asset := range sys.UAssets {
+		ua = asset
+		break
+	}
+	var serv *components.Service
+	for _, s := range (*ua).GetServices() {
+		serv = s
+		break
+	}
+	payload, err := serviceRegistrationForm(&sys, ua, serv, "ServiceRecord_v1")
+	if err != nil {
+		t.Fatalf("building the registration: %v", err)
+	}
+	var sr forms.ServiceRecord_v1
+	if err := json.Unmarshal(payload, &sr); err != nil {
+		t.Fatalf("unpacking the registration: %v", err)
+	}
+	if len(sr.IPAddresses) != 3 || sr.IPAddresses[0] != "fd00::7" {
+		t.Fatalf("registered %v; want the IPv6 address first and none dropped", sr.IPAddresses)
+	}
+
+	sr.SystemName, sr.SubPath = "ds18b20", "sensor/temperature"
+	want := "http://[fd00::7]:20150/ds18b20/sensor/temperature"
+	if sp := ConvertToServicePoint(sr); sp.ServLocation != want {
+		t.Errorf("a consumer is sent to %q, want %q", sp.ServLocation, want)
+	}
+}
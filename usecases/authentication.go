@@ -306,7 +306,10 @@ func dialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
 	// A cloud peer still refuses the handshake for want of a client
 	// certificate. That is the peer's answer to give, not this dial's to
 	// predict.
-	cfg := clientTLS.Load() // nil until enrollment: tls.Dialer then uses the defaults
+	if conn, ok := dialLocal(ctx, addr); ok {
+		return tlsOverSocket(ctx, conn, addr)
+	}
+	cfg := offerHTTP2(clientTLS.Load()) // nil until enrollment and without HTTP/2: tls.Dialer then uses the defaults
 	return (&tls.Dialer{Config: cfg}).DialContext(ctx, network, addr)
 }
 
@@ -25,6 +25,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -35,6 +36,7 @@ import (
 	"time"
 
 	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
 )
 
 // FileService is what a request for a stored file is called when the asset
@@ -335,6 +337,39 @@ func isBootstrapService(sys *components.System, assetName string, serv *componen
 	return components.EffectiveMission(ua, serv) == components.MissionCore
 }
 
+// Why a request for a service was refused, as counted in the system's metrics.
+const (
+	refusedKeyUnavailable = "key_unavailable" // the authorizer's key is not held yet
+	refusedUnidentified   = "unidentified"    // no verified client certificate
+	refusedNoToken        = "no_token"
+	refusedMalformed      = "malformed_token"
+	refusedSignature      = "bad_signature"
+	refusedExpired        = "expired_token"
+	refusedClaims         = "wrong_claims" // a valid token, for another request
+	refusedUnknownService = "unknown_service"
+)
+
+// verifyTraced is VerifyToken, now, recorded as a span of the request's trace.
+func verifyTraced(r *http.Request, sys *components.System, token string, key *ecdsa.PublicKey, want TokenRequest) (claims forms.AccessToken_v1, err error) {
+	_, span := StartSpan(r.Context(), sys, "verify token", components.SpanInternal)
+	defer func() { span.End(err) }()
+	return VerifyToken(token, key, want, time.Now())
+}
+
+// tokenRefusal names why VerifyToken rejected a token.
+func tokenRefusal(err error) string {
+	switch {
+	case errors.Is(err, errTokenMalformed), errors.Is(err, errTokenUnreadable):
+		return refusedMalformed
+	case errors.Is(err, errTokenSignature):
+		return refusedSignature
+	case errors.Is(err, errTokenExpired):
+		return refusedExpired
+	default:
+		return refusedClaims
+	}
+}
+
 // AuthorizeRequest decides whether a provider may serve one incoming request.
 //
 // It runs at dispatch, after the unit asset and service are known, and returns
@@ -378,18 +413,21 @@ func AuthorizeRequest(sys *components.System, r *http.Request, assetName string,
 
 	key, ready := AuthorizerKey(sys)
 	if !ready {
+		countRefusal(sys, refusedKeyUnavailable)
 		return http.StatusServiceUnavailable,
 			fmt.Errorf("cannot verify access tokens yet: the authorizer's key has not been obtained")
 	}
 
 	subject, ok := PeerCN(r)
 	if !ok {
+		countRefusal(sys, refusedUnidentified)
 		return http.StatusUnauthorized,
 			fmt.Errorf("the caller presented no verified certificate")
 	}
 
 	token := r.Header.Get(TokenHeader)
 	if token == "" {
+		countRefusal(sys, refusedNoToken)
 		return http.StatusUnauthorized, fmt.Errorf("no access token")
 	}
 
@@ -401,7 +439,7 @@ func AuthorizeRequest(sys *components.System, r *http.Request, assetName string,
 		Action:   ActionForMethod(r.Method),
 	}
 
-	_, err := VerifyToken(token, key, want, time.Now())
+	_, err := verifyTraced(r, sys, token, key, want)
 	if err == nil {
 		return 0, nil
 	}
@@ -412,11 +450,12 @@ func AuthorizeRequest(sys *components.System, r *http.Request, assetName string,
 	// judged against the current key.
 	if strings.Contains(err.Error(), "signature") && ReacquireAuthorizerKey(sys) {
 		if refreshed, ok := AuthorizerKey(sys); ok {
-			if _, retryErr := VerifyToken(token, refreshed, want, time.Now()); retryErr == nil {
+			if _, retryErr := verifyTraced(r, sys, token, refreshed, want); retryErr == nil {
 				return 0, nil
 			}
 		}
 	}
 
+	countRefusal(sys, tokenRefusal(err))
 	return http.StatusForbidden, err
 }
@@ -0,0 +1,144 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+// Circuit breakers: a provider that keeps failing is left alone for a while
+// rather than asked on every call.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// ErrCircuitOpen is the error for a call not made because the provider's
+// circuit is open: it failed too often lately, and its cooldown has not yet
+// passed.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// The breaker's terms where the cervice sets none (see
+// Cervice.BreakerThreshold and BreakerCooldown). A probe that fails doubles the
+// cooldown, up to maxCooldown.
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 10 * time.Second
+	maxCooldown             = 5 * time.Minute
+)
+
+// breaker is one provider's circuit. Closed, every call is made and consecutive
+// failures are counted; at the threshold it opens, and calls fail at once
+// without a request. When the cooldown has passed it is half open: one call is
+// let through as a probe, and its outcome closes the circuit or opens it again
+// for twice as long.
+//
+// A provider that is down costs a control loop polling it one timeout per tick;
+// twenty consumers polling it cost it twenty requests a tick the moment it comes
+// back. The breaker spares both.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int       // consecutive, while closed
+	open      bool      // open or half open
+	openUntil time.Time // when the next probe may go
+	cooldown  time.Duration
+	probing   bool // a probe is in flight
+}
+
+// breakerFor is the circuit of one of the cervice's providers, kept on the
+// cervice and forgotten when discovery drops the provider.
+func breakerFor(cer *components.Cervice, url string) *breaker {
+	return cer.Circuit(url, func() any { return &breaker{} }).(*breaker)
+}
+
+// admit says whether a call may be made now, and takes the probe where the
+// circuit is half open. A call admitted is followed by record.
+func (b *breaker) admit() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return nil
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// isOpen reports whether admit would refuse a call now.
+func (b *breaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open && (b.probing || time.Now().Before(b.openUntil))
+}
+
+// record counts the outcome of an admitted call. A provider that answered, even
+// with something this consumer could not read, is working; a caller that gave
+// up counts for nothing either way.
+func (b *breaker) record(cer *components.Cervice, url string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.probing
+	b.probing = false
+
+	class, classified := ClassOf(err)
+	switch {
+	case !classified && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+		return
+	case !classified || !class.tripsTheBreaker():
+		if b.open {
+			log.Printf("%s: %s is answering again\n", cer.Definition, url)
+		}
+		b.failures, b.open, b.cooldown = 0, false, 0
+		return
+	}
+
+	b.failures++
+	threshold, cooldown := breakerTerms(cer)
+	switch {
+	case probe:
+		b.cooldown = min(2*b.cooldown, maxCooldown)
+	case !b.open && b.failures >= threshold:
+		b.open, b.cooldown = true, cooldown
+		log.Printf("%s: %s failed %d times running (%v); not asked again for %v\n",
+			cer.Definition, url, b.failures, err, cooldown)
+	default:
+		return
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+}
+
+// breakerTerms are the cervice's terms, defaults filled in.
+func breakerTerms(cer *components.Cervice) (threshold int, cooldown time.Duration) {
+	threshold, cooldown = cer.BreakerThreshold, cer.BreakerCooldown
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return
+}
+
+// circuitOpenError is the error for a call the breaker did not let through.
+func circuitOpenError(url string) error {
+	return fmt.Errorf("%s: %w", url, ErrCircuitOpen)
+}
@@ -0,0 +1,163 @@
+package usecases
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// answering is a transport that answers every request with status, or fails to
+// connect where status is 0, and counts the requests.
+func answering(t *testing.T, status *atomic.Int32) *atomic.Int32 {
+	t.Helper()
+	var asked atomic.Int32
+	fakeProvider(t, func(*http.Request) int {
+		asked.Add(1)
+		return int(status.Load())
+	})
+	return &asked
+}
+
+// A failed call says how it failed, and only a provider that could not be
+// reached, or refused the token, costs anything discovered.
+func TestProviderErrorsAreClassified(t *testing.T) {
+	var status atomic.Int32
+	answering(t, &status)
+	sys := createTestSystem(false)
+
+	for _, c := range []struct {
+		status    int
+		class     ErrorClass
+		tokenKept bool
+	}{
+		{0, ProviderUnreachable, false},
+		{http.StatusUnauthorized, ProviderUnauthorized, false},
+		{http.StatusForbidden, ProviderUnauthorized, false},
+		{http.StatusBadRequest, ProviderClientError, true},
+		{http.StatusNotFound, ProviderClientError, true},
+		{http.StatusTooManyRequests, ProviderBusy, true},
+		{http.StatusServiceUnavailable, ProviderBusy, true},
+		{http.StatusInternalServerError, ProviderBusy, true},
+	} {
+		status.Store(int32(c.status))
+		cer := newTestCerviceWithNodes()
+		_, err := SetState(cer, &sys, []byte(`{}`))
+		var failed *ProviderError
+		if !errors.As(err, &failed) {
+			t.Fatalf("%d: %v is not a provider error", c.status, err)
+		}
+		if failed.Class != c.class || failed.Status != c.status || failed.URL != "https://testSystem/testUnitAsset/test" {
+			t.Errorf("%d: %+v", c.status, failed)
+		}
+		if _, _, kept := pickNode(cer, "write"); kept != c.tokenKept {
+			t.Errorf("%d (%s): token kept %v", c.status, c.class, kept)
+		}
+	}
+}
+
+// A provider that keeps failing is left alone for the cooldown, then probed,
+// and a probe that succeeds closes the circuit.
+func TestABreakerOpensAndIsProbed(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	asked := answering(t, &status)
+	sys := createTestSystem(false)
+	cer := newTestCerviceWithNodes()
+	cer.BreakerThreshold, cer.BreakerCooldown = 2, 50*time.Millisecond
+
+	for range 2 {
+		if _, err := GetState(cer, &sys); errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("the circuit opened before the threshold")
+		}
+	}
+	if _, err := GetState(cer, &sys); !errors.Is(err, ErrCircuitOpen) || asked.Load() != 2 {
+		t.Fatalf("after two failures: %v, with %d requests made", err, asked.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	status.Store(http.StatusOK)
+	for range 2 {
+		if _, err := GetState(cer, &sys); err != nil {
+			t.Fatalf("after the cooldown: %v", err)
+		}
+	}
+	if asked.Load() != 4 {
+		t.Errorf("%d requests made, want 4", asked.Load())
+	}
+}
+
+// Half open, one call is let through; a probe that fails opens the circuit for
+// twice as long.
+func TestAFailedProbeDoublesTheCooldown(t *testing.T) {
+	cer := &components.Cervice{Definition: "temperature", BreakerThreshold: 1, BreakerCooldown: 40 * time.Millisecond}
+	b := breakerFor(cer, "http://a/t")
+	busy := &ProviderError{Class: ProviderBusy, Status: 503, Err: errors.New("503")}
+
+	if b.admit() != nil {
+		t.Fatal("a closed circuit refused")
+	}
+	b.record(cer, "http://a/t", busy)
+	if !errors.Is(b.admit(), ErrCircuitOpen) {
+		t.Fatal("the circuit did not open")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if b.admit() != nil {
+		t.Fatal("no probe was let through")
+	}
+	if !errors.Is(b.admit(), ErrCircuitOpen) {
+		t.Error("a second call went through while the probe was out")
+	}
+	b.record(cer, "http://a/t", busy)
+	time.Sleep(50 * time.Millisecond)
+	if !errors.Is(b.admit(), ErrCircuitOpen) {
+		t.Error("the cooldown did not double")
+	}
+
+	// A refusal is an answer: it closes the circuit.
+	time.Sleep(60 * time.Millisecond)
+	if b.admit() != nil {
+		t.Fatal("no probe after the doubled cooldown")
+	}
+	b.record(cer, "http://a/t", &ProviderError{Class: ProviderClientError, Status: 400, Err: errors.New("400")})
+	if b.admit() != nil || b.isOpen() {
+		t.Error("a provider that answered is still left alone")
+	}
+}
+
+// A breaker lives as long as its provider does: discovery dropping the
+// provider drops its breaker, and a failed call forgetting the providers does
+// not, since they are found again on the next search with their circuits as
+// they were.
+func TestABreakerGoesWithItsProvider(t *testing.T) {
+	north, south := "http://north/temperature", "http://south/temperature"
+	cer := multiProviderCervice([]components.NodeInfo{
+		{URL: north, Tokens: map[string]string{"read": ""}},
+		{URL: south, Tokens: map[string]string{"read": ""}},
+	}, nil)
+	cer.BreakerThreshold, cer.BreakerCooldown = 1, time.Minute
+	busy := &ProviderError{Class: ProviderBusy, Status: 503, Err: errors.New("503")}
+	for _, url := range []string{north, south} {
+		b := breakerFor(cer, url)
+		b.admit()
+		b.record(cer, url, busy)
+	}
+
+	pruneNodes(cer, map[string]bool{south: true}, "read")
+	if breakerFor(cer, north).isOpen() {
+		t.Error("the breaker of a provider discovery dropped is still kept")
+	}
+	if !breakerFor(cer, south).isOpen() {
+		t.Error("the breaker of a provider still registered was dropped")
+	}
+
+	cer.Mutex.Lock()
+	cer.Nodes = make(map[string][]components.NodeInfo)
+	cer.Mutex.Unlock()
+	if !breakerFor(cer, south).isOpen() {
+		t.Error("forgetting the providers after a failure closed a circuit")
+	}
+}
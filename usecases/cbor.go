@@ -0,0 +1,535 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// CBOR (RFC 8949), for the sensor gateways that pay for every byte they send.
+//
+// A form is carried in CBOR exactly as it is in JSON — the same field names,
+// the same version key, the same values — only encoded in binary: a SignalA_v1a
+// is about half the size, and no string is parsed on either end. The form is
+// rendered through its JSON encoding and back, so a form's json tags and any
+// marshaller it defines decide its CBOR too, and a new form needs nothing to be
+// sent in it.
+//
+// Written here rather than taken from a CBOR library for the reason the
+// Prometheus exposition is: the framework depends on the standard library
+// alone, and the subset a form needs — maps, arrays, text, numbers, booleans
+// and null — is small.
+
+// ContentTypeCBOR is the media type of a form encoded in CBOR.
+const ContentTypeCBOR = "application/cbor"
+
+// cborMaxDepth bounds how deeply a received item may nest. A form nests a few
+// levels; a body nesting thousands is an attempt on the decoder's stack.
+const cborMaxDepth = 64
+
+// CBOR major types.
+const (
+	cborUint   = 0 << 5
+	cborNegint = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+)
+
+// packCBOR encodes a form in CBOR by way of its JSON.
+func packCBOR(v any) ([]byte, error) {
+	return packLabelledCBOR(v, nil)
+}
+
+// packLabelledCBOR is packCBOR with the map keys labels names written as the
+// integers it gives them, as a format defined over CBOR may ask (see senml.go).
+func packLabelledCBOR(v any, labels cborLabels) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // so that an integer stays one, rather than becoming a float64
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encodeCBOR(&buf, tree, labels); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unpackCBOR decodes a CBOR item into the JSON it stands for, which is then
+// unmarshalled like any other.
+func unpackCBOR(data []byte) ([]byte, any, error) {
+	return unpackLabelledCBOR(data, nil)
+}
+
+// unpackLabelledCBOR is unpackCBOR reading the integer keys labels gives names
+// to as those names.
+func unpackLabelledCBOR(data []byte, labels cborLabels) ([]byte, any, error) {
+	d := cborDecoder{data: data, names: labels.names()}
+	tree, err := d.item(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, stray := tree.(cborBreak); stray {
+		return nil, nil, fmt.Errorf("CBOR break outside an indefinite-length item")
+	}
+	if d.pos != len(data) {
+		return nil, nil, fmt.Errorf("%d bytes after the CBOR item", len(data)-d.pos)
+	}
+	asJSON, err := json.Marshal(tree)
+	if err != nil {
+		return nil, nil, err
+	}
+	return asJSON, tree, nil
+}
+
+// cborLabels gives map keys integer labels in place of their names. A form
+// has none; a format that wants the bytes back, like SenML, defines its own.
+type cborLabels map[string]int64
+
+// names is the table read the other way, for the decoder.
+func (l cborLabels) names() map[int64]string {
+	if l == nil {
+		return nil
+	}
+	names := make(map[int64]string, len(l))
+	for name, label := range l {
+		names[label] = name
+	}
+	return names
+}
+
+// encodeCBOR writes one JSON value as CBOR. Map keys are sorted, as JSON's are,
+// so that a form always encodes to the same bytes; a key labels names is
+// written as its label.
+func encodeCBOR(buf *bytes.Buffer, v any, labels cborLabels) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(cborSimple | 22)
+	case bool:
+		if v {
+			buf.WriteByte(cborSimple | 21)
+		} else {
+			buf.WriteByte(cborSimple | 20)
+		}
+	case string:
+		writeCBORHead(buf, cborText, uint64(len(v)))
+		buf.WriteString(v)
+	case json.Number:
+		encodeCBORNumber(buf, v)
+	case []any:
+		writeCBORHead(buf, cborArray, uint64(len(v)))
+		for _, item := range v {
+			if err := encodeCBOR(buf, item, labels); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeCBORHead(buf, cborMap, uint64(len(v)))
+		for _, k := range keys {
+			if label, ok := labels[k]; ok {
+				encodeCBORNumber(buf, json.Number(strconv.FormatInt(label, 10)))
+			} else {
+				writeCBORHead(buf, cborText, uint64(len(k)))
+				buf.WriteString(k)
+			}
+			if err := encodeCBOR(buf, v[k], labels); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %T in CBOR", v)
+	}
+	return nil
+}
+
+// encodeCBORNumber writes an integer as one, and any other number in the
+// shortest float that holds it exactly.
+func encodeCBORNumber(buf *bytes.Buffer, n json.Number) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		if i >= 0 {
+			writeCBORHead(buf, cborUint, uint64(i))
+		} else {
+			writeCBORHead(buf, cborNegint, uint64(-1-i))
+		}
+		return
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		writeCBORHead(buf, cborUint, u)
+		return
+	}
+	f, _ := strconv.ParseFloat(string(n), 64) // JSON has already vouched for it
+	if f32 := float32(f); float64(f32) == f {
+		buf.WriteByte(cborSimple | 26)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(f32))
+		return
+	}
+	buf.WriteByte(cborSimple | 27)
+	binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+}
+
+// writeCBORHead writes a major type and its argument in the fewest bytes.
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// cborDecoder reads the items a form can be made of, from a sender the
+// framework does not trust: every length is checked against what is left
+// before anything is allocated for it.
+type cborDecoder struct {
+	data  []byte
+	pos   int
+	names map[int64]string // the integer keys that stand for names, if any
+}
+
+// cborBreak is what item returns for the "break" that ends an indefinite
+// length item.
+type cborBreak struct{}
+
+func (d *cborDecoder) item(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, fmt.Errorf("CBOR nested deeper than %d", cborMaxDepth)
+	}
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("CBOR item truncated")
+	}
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial&0xe0, initial&0x1f
+
+	if major == cborSimple {
+		return d.simple(info)
+	}
+	if info == 31 {
+		return d.indefinite(major, depth)
+	}
+	n, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		return n, nil
+	case cborNegint:
+		if n > math.MaxInt64 {
+			return -1 - float64(n), nil
+		}
+		return -1 - int64(n), nil
+	case cborBytes, cborText:
+		raw, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborBytes {
+			return raw, nil // rendered in base64, as JSON renders a []byte
+		}
+		if !utf8.Valid(raw) {
+			return nil, fmt.Errorf("CBOR text is not UTF-8")
+		}
+		return string(raw), nil
+	case cborArray:
+		if n > uint64(len(d.data)-d.pos) { // every element takes a byte at least
+			return nil, fmt.Errorf("CBOR array of %d truncated", n)
+		}
+		items := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			item, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, stray := item.(cborBreak); stray {
+				return nil, fmt.Errorf("CBOR break in an array of %d", n)
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case cborMap:
+		if n > uint64(len(d.data)-d.pos)/2 {
+			return nil, fmt.Errorf("CBOR map of %d truncated", n)
+		}
+		m := make(map[string]any, n)
+		for i := uint64(0); i < n; i++ {
+			if err := d.entry(m, depth); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	default: // cborTag
+		return d.tagged(n, depth)
+	}
+}
+
+// simple reads the booleans, null, undefined and the floats.
+func (d *cborDecoder) simple(info byte) (any, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		raw, err := d.take(2)
+		if err != nil {
+			return nil, err
+		}
+		return halfToFloat(binary.BigEndian.Uint16(raw)), nil
+	case 26:
+		raw, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), nil
+	case 27:
+		raw, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+	case 31:
+		return cborBreak{}, nil
+	}
+	return nil, fmt.Errorf("CBOR simple value %d has no meaning in a form", info)
+}
+
+// indefinite reads an array, a map or a string sent in chunks of unknown count.
+func (d *cborDecoder) indefinite(major byte, depth int) (any, error) {
+	switch major {
+	case cborArray:
+		items := []any{}
+		for {
+			item, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, done := item.(cborBreak); done {
+				return items, nil
+			}
+			items = append(items, item)
+		}
+	case cborMap:
+		m := map[string]any{}
+		for {
+			if d.pos < len(d.data) && d.data[d.pos] == cborSimple|31 {
+				d.pos++
+				return m, nil
+			}
+			if err := d.entry(m, depth); err != nil {
+				return nil, err
+			}
+		}
+	case cborBytes, cborText:
+		// Each chunk is a string of the same type and known length (RFC 8949
+		// §3.2.3); anything else makes the whole item malformed, not shorter.
+		var joined []byte
+		for {
+			if d.pos < len(d.data) && d.data[d.pos] == major|31 {
+				return nil, fmt.Errorf("CBOR indefinite-length string inside another")
+			}
+			chunk, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch chunk := chunk.(type) {
+			case cborBreak:
+				if major == cborText {
+					return string(joined), nil
+				}
+				return joined, nil
+			case string:
+				if major != cborText {
+					return nil, fmt.Errorf("CBOR text chunk in a byte string")
+				}
+				joined = append(joined, chunk...)
+			case []byte:
+				if major != cborBytes {
+					return nil, fmt.Errorf("CBOR byte chunk in a text string")
+				}
+				joined = append(joined, chunk...)
+			default:
+				return nil, fmt.Errorf("CBOR string chunk is a %T, not a string", chunk)
+			}
+		}
+	}
+	return nil, fmt.Errorf("CBOR major type %d cannot have an indefinite length", major>>5)
+}
+
+// entry reads one key and value into m. Only text keys, or the integers the
+// decoder was given names for: a form's fields have names.
+func (d *cborDecoder) entry(m map[string]any, depth int) error {
+	key, err := d.item(depth + 1)
+	if err != nil {
+		return err
+	}
+	name, ok := key.(string)
+	if label, isInt := cborInt(key); isInt && d.names != nil {
+		name, ok = d.names[label]
+	}
+	if !ok {
+		if _, stray := key.(cborBreak); stray {
+			return fmt.Errorf("CBOR break in a map of known length")
+		}
+		return fmt.Errorf("CBOR map key %v is not text", key)
+	}
+	value, err := d.item(depth + 1)
+	if err != nil {
+		return err
+	}
+	if _, isBreak := value.(cborBreak); isBreak {
+		return fmt.Errorf("CBOR map entry %q has no value", name)
+	}
+	m[name] = value
+	return nil
+}
+
+// cborInt reports the integer a decoded key holds, if it is one.
+func cborInt(key any) (int64, bool) {
+	switch k := key.(type) {
+	case int64:
+		return k, true
+	case uint64:
+		if k <= math.MaxInt64 {
+			return int64(k), true
+		}
+	}
+	return 0, false
+}
+
+// tagged reads a tagged item. The two date tags become the RFC 3339 text a
+// form's time.Time reads; any other tag is dropped and its content kept.
+func (d *cborDecoder) tagged(tag uint64, depth int) (any, error) {
+	content, err := d.item(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	if tag != 1 {
+		return content, nil
+	}
+	var seconds float64
+	switch c := content.(type) {
+	case uint64:
+		seconds = float64(c)
+	case int64:
+		seconds = float64(c)
+	case float64:
+		seconds = c
+	default:
+		return nil, fmt.Errorf("CBOR epoch date is a %T", content)
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC().Format(time.RFC3339Nano), nil
+}
+
+// argument reads the number that follows an initial byte.
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		raw, err := d.take(1)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(raw[0]), nil
+	case info == 25:
+		raw, err := d.take(2)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint16(raw)), nil
+	case info == 26:
+		raw, err := d.take(4)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint32(raw)), nil
+	case info == 27:
+		raw, err := d.take(8)
+		if err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(raw), nil
+	}
+	return 0, fmt.Errorf("CBOR additional information %d is reserved", info)
+}
+
+// take returns the next n bytes, if there are that many.
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("CBOR item truncated")
+	}
+	raw := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return raw, nil
+}
+
+// halfToFloat widens an IEEE 754 half-precision float, which a sender may use
+// for a value that fits.
+func halfToFloat(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			return sign * math.Inf(1)
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(mant+1024, exp-25)
+}
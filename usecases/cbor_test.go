@@ -0,0 +1,172 @@
+package usecases
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// fill gives every settable field of v a value other than its zero, so that a
+// round trip that drops or mangles a field shows. The version is left to NewForm.
+func fill(v reflect.Value, depth int) {
+	if depth > 4 {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(time.Date(2026, 3, 14, 15, 9, 26, 535000000, time.UTC)))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() && v.Type().Field(i).Name != "Version" {
+				fill(v.Field(i), depth+1)
+			}
+		}
+	case reflect.String:
+		v.SetString("ds18b20 ±0.5 °C")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(-42)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(42)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(21.3)
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 1, 1)
+		fill(s.Index(0), depth+1)
+		v.Set(s)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		key, value := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		fill(key, depth+1)
+		fill(value, depth+1)
+		m.SetMapIndex(key, value)
+		v.Set(m)
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		fill(p.Elem(), depth+1)
+		v.Set(p)
+	}
+}
+
+// Every registered form survives CBOR unchanged, and comes back as its own type.
+func TestEveryFormRoundTripsThroughCBOR(t *testing.T) {
+	for version, formType := range forms.FormTypeMap {
+		f := reflect.New(formType).Interface().(forms.Form)
+		fill(reflect.ValueOf(f).Elem(), 0)
+		f = f.NewForm()
+
+		data, err := Pack(f, ContentTypeCBOR)
+		if err != nil {
+			t.Errorf("%s: Pack: %v", version, err)
+			continue
+		}
+		back, err := Unpack(data, ContentTypeCBOR)
+		if err != nil {
+			t.Errorf("%s: Unpack: %v", version, err)
+			continue
+		}
+		if reflect.TypeOf(back) != reflect.TypeOf(f) {
+			t.Errorf("%s: came back as %T", version, back)
+			continue
+		}
+		want, _ := json.Marshal(f)
+		got, _ := json.Marshal(back)
+		if !bytes.Equal(want, got) {
+			t.Errorf("%s changed in CBOR:\n sent %s\n got  %s", version, want, got)
+		}
+		if asJSON, _ := Pack(f, "application/json"); len(data) >= len(asJSON) {
+			t.Errorf("%s: CBOR is %d bytes, JSON %d", version, len(data), len(asJSON))
+		}
+	}
+}
+
+// A known encoding, from RFC 8949's appendix A, decodes to what it says.
+func TestCBORDecodesTheRFCExamples(t *testing.T) {
+	cases := []struct {
+		hex  []byte
+		want string
+	}{
+		{[]byte{0x18, 0x64}, `100`},
+		{[]byte{0x39, 0x03, 0xe7}, `-1000`},
+		{[]byte{0xf9, 0x3c, 0x00}, `1`},
+		{[]byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}, `1.1`},
+		{[]byte{0xf5}, `true`},
+		{[]byte{0xf6}, `null`},
+		{[]byte{0x64, 0x49, 0x45, 0x54, 0x46}, `"IETF"`},
+		{[]byte{0x83, 0x01, 0x02, 0x03}, `[1,2,3]`},
+		{[]byte{0xa2, 0x61, 0x61, 0x01, 0x61, 0x62, 0x82, 0x02, 0x03}, `{"a":1,"b":[2,3]}`},
+		{[]byte{0xbf, 0x61, 0x61, 0x01, 0x61, 0x62, 0x9f, 0x02, 0x03, 0xff, 0xff}, `{"a":1,"b":[2,3]}`},
+		{[]byte{0x7f, 0x65, 0x73, 0x74, 0x72, 0x65, 0x61, 0x64, 0x6d, 0x69, 0x6e, 0x67, 0xff}, `"streaming"`},
+		{[]byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}, `"2013-03-21T20:04:00Z"`},
+	}
+	for _, c := range cases {
+		got, _, err := unpackCBOR(c.hex)
+		if err != nil || string(got) != c.want {
+			t.Errorf("% x = %s, %v; want %s", c.hex, got, err, c.want)
+		}
+	}
+}
+
+// What a sender the system does not trust can send: nothing it declares is
+// believed before the bytes are there.
+func TestCBORRefusesMalformedInput(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x81}, 1000)
+	for name, data := range map[string][]byte{
+		"empty":             {},
+		"truncated text":    {0x64, 0x49, 0x45},
+		"a vast array":      {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"a vast map":        {0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"nested too deeply": append(deep, 0x01),
+		"an integer key":    {0xa1, 0x01, 0x02},
+		"a stray break":     {0xff},
+		"trailing bytes":    {0x01, 0x02},
+		"invalid UTF-8":     {0x61, 0xff},
+		"an integer chunk":  {0x7f, 0x61, 0x61, 0x01, 0xff},
+		"a map chunk":       {0x5f, 0xa0, 0xff},
+		"text in bytes":     {0x5f, 0x61, 0x61, 0xff},
+		"bytes in text":     {0x7f, 0x41, 0x61, 0xff},
+		"a nested stream":   {0x7f, 0x7f, 0x61, 0x61, 0xff, 0xff},
+	} {
+		if _, _, err := unpackCBOR(data); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+	if _, err := Unpack([]byte{0x83, 0x01, 0x02, 0x03}, ContentTypeCBOR); err == nil {
+		t.Error("an array was unpacked as a form")
+	}
+}
+
+// A caller that prefers CBOR gets it, and one that asks for something Pack
+// cannot produce is not sent JSON under that name.
+func TestCBORIsNegotiated(t *testing.T) {
+	if got, _ := getBestContentType("application/cbor, application/json;q=0.5"); got != ContentTypeCBOR {
+		t.Errorf("chose %s", got)
+	}
+	if got, ok := getBestContentType("text/html"); ok {
+		t.Errorf("chose %s for text/html", got)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/ds18b20/sensor_Id/temperature", nil)
+	r.Header.Set("Accept", ContentTypeCBOR)
+	w := httptest.NewRecorder()
+	HTTPProcessGetRequest(w, r, sample(21.5))
+	if ct := w.Header().Get("Content-Type"); ct != ContentTypeCBOR {
+		t.Fatalf("answered in %s", ct)
+	}
+	if f, err := Unpack(w.Body.Bytes(), ContentTypeCBOR); err != nil || f.(*forms.SignalA_v1a).Value != 21.5 {
+		t.Errorf("the answer unpacked to %v, %v", f, err)
+	}
+	if halfToFloat(0x7c00) != math.Inf(1) || halfToFloat(0x0001) != 5.960464477539063e-8 {
+		t.Error("half-precision floats are misread")
+	}
+}
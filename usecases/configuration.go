@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/sdoque/mbaigo/components"
 )
@@ -50,8 +51,12 @@ type templateOut struct {
 	CName       string                  `json:"systemname"`
 	LocalCloud  string                  `json:"localcloud,omitempty"`
 	IPAddresses []string                `json:"ipAddresses"`
+	IPPrefer    string                  `json:"ipPreference,omitempty"`
 	Assets      []ConfigurableAsset     `json:"unit_assets"`
 	Protocols   map[string]int          `json:"protocolsNports"`
+	ListenHost  string                  `json:"listenHost,omitempty"`
+	Sockets     map[string]string       `json:"unixSockets,omitempty"`
+	SocketDir   string                  `json:"socketDir,omitempty"`
 	CCoreS      []components.CoreSystem `json:"coreSystems"`
 }
 
@@ -62,7 +67,17 @@ type configFileIn struct {
 	CName       string                  `json:"systemname"`
 	LocalCloud  string                  `json:"localcloud,omitempty"`
 	IPAddresses []string                `json:"ipAddresses"`
+	IPPrefer    string                  `json:"ipPreference,omitempty"`
 	Protocols   map[string]int          `json:"protocolsNports"`
+	ListenHost  string                  `json:"listenHost,omitempty"`
+	Sockets     map[string]string       `json:"unixSockets,omitempty"`
+	SocketDir   string                  `json:"socketDir,omitempty"`
+	Tracing     string                  `json:"tracing,omitempty"`
+	DrainSecs   int                     `json:"drainSeconds,omitempty"`
+	HTTP2       *bool                   `json:"http2,omitempty"`
+	MaxBody     int64                   `json:"maxBodyBytes,omitempty"`
+	RateLimit   *components.RateLimit   `json:"rateLimit,omitempty"`
+	Validate    *bool                   `json:"validateForms,omitempty"`
 	CCoreS      []components.CoreSystem `json:"coreSystems"`
 	Resources   []json.RawMessage       `json:"unit_assets"`
 }
@@ -107,7 +122,16 @@ func setupDefaultConfig(sys *components.System) (defaultConfig templateOut, err
 		}
 	}
 	defaultConfig.IPAddresses = sys.Husk.Host.IPAddresses
+	// Written out even when the system left it blank, so the operator of an
+	// IPv6-only segment finds the setting in the file rather than in the code.
+	defaultConfig.IPPrefer = sys.Husk.Host.IPPreference
+	if defaultConfig.IPPrefer == "" {
+		defaultConfig.IPPrefer = components.PreferIPv4
+	}
 	defaultConfig.Protocols = sys.Husk.ProtoPort
+	defaultConfig.ListenHost = sys.Husk.ListenHost
+	defaultConfig.Sockets = sys.Husk.UnixSockets
+	defaultConfig.SocketDir = sys.Husk.SocketDir
 	defaultConfig.Assets = []ConfigurableAsset{confAsset} // this is a list of unit assets
 
 	// The host's own address rather than "localhost".
@@ -120,26 +144,26 @@ func setupDefaultConfig(sys *components.System) (defaultConfig templateOut, err
 	// certificate that never mentions localhost. Writing the address the system
 	// already knows about itself means switching the scheme is the only edit.
 	//
-	// IPAddresses[0] is the same choice the knowledge graph makes when it builds
-	// a service URL, and IpAddresses appends 127.0.0.1 last on purpose, so this
+	// AdvertisedIP is the same choice the knowledge graph makes when it builds
+	// a service URL, and Advertised puts loopback last on purpose, so this
 	// falls back to loopback exactly when there is nothing else — which is what
 	// localhost meant anyway.
 	host := "localhost"
-	if len(sys.Husk.Host.IPAddresses) > 0 {
-		host = sys.Husk.Host.IPAddresses[0]
+	if ip := sys.Husk.Host.AdvertisedIP(); ip != "" {
+		host = ip
 	}
 
 	servReg := components.CoreSystem{
 		Name: "serviceregistrar",
-		Url:  "http://" + host + ":20102/serviceregistrar/registry",
+		Url:  "http://" + components.HostPort(host, 20102) + "/serviceregistrar/registry",
 	}
 	orches := components.CoreSystem{
 		Name: "orchestrator",
-		Url:  "http://" + host + ":20103/orchestrator/orchestration",
+		Url:  "http://" + components.HostPort(host, 20103) + "/orchestrator/orchestration",
 	}
 	ca := components.CoreSystem{
 		Name: "ca",
-		Url:  "http://" + host + ":20100/ca/certification",
+		Url:  "http://" + components.HostPort(host, 20100) + "/ca/certification",
 	}
 	// The authorizer's slot, empty because authorization is adopted per
 	// deployment rather than assumed.
@@ -236,6 +260,16 @@ func Configure(sys *components.System) ([]json.RawMessage, error) {
 	if len(configurationIn.IPAddresses) > 0 {
 		sys.Husk.Host.IPAddresses = configurationIn.IPAddresses
 	}
+	// A file written before the setting existed says nothing, and keeps the
+	// system's own preference. A misspelled one is refused rather than read as
+	// IPv4: the operator who wrote "IPv6" meant something by it.
+	switch configurationIn.IPPrefer {
+	case "":
+	case components.PreferIPv4, components.PreferIPv6:
+		sys.Husk.Host.IPPreference = configurationIn.IPPrefer
+	default:
+		return nil, fmt.Errorf("ipPreference %q is neither %q nor %q", configurationIn.IPPrefer, components.PreferIPv4, components.PreferIPv6)
+	}
 	// If the systemconfig file has a LocalCloud defined, add it to the system details
 	if configurationIn.LocalCloud != "" {
 		if sys.Husk.Details == nil {
@@ -244,6 +278,49 @@ func Configure(sys *components.System) ([]json.RawMessage, error) {
 		sys.Husk.Details["LocalCloud"] = []string{configurationIn.LocalCloud}
 	}
 	sys.Husk.ProtoPort = configurationIn.Protocols
+	// Where to listen is a setting, so the file decides wherever it says
+	// anything and the system's own choice stands where it is silent.
+	if configurationIn.ListenHost != "" {
+		sys.Husk.ListenHost = configurationIn.ListenHost
+	}
+	if configurationIn.Sockets != nil {
+		sys.Husk.UnixSockets = configurationIn.Sockets
+	}
+	if configurationIn.SocketDir != "" {
+		sys.Husk.SocketDir = configurationIn.SocketDir
+	}
+	// Where trace spans go, if anywhere. Absent, the system still passes its
+	// callers' traces on to its providers; it only keeps no spans of its own.
+	if configurationIn.Tracing != "" {
+		exporter, err := TraceExporter(sys.Ctx, configurationIn.Tracing)
+		if err != nil {
+			return nil, err
+		}
+		sys.Traces = exporter
+	}
+	// How long a shutdown may wait for the registrar and for requests in
+	// flight. An operator whose orchestrator allows longer than the default
+	// grace period can let the system use it.
+	if configurationIn.DrainSecs > 0 {
+		sys.Husk.DrainTimeout = time.Duration(configurationIn.DrainSecs) * time.Second
+	}
+	// Whether to speak HTTP/2. A pointer, so that a file silent on it leaves
+	// the system's own choice alone.
+	if configurationIn.HTTP2 != nil {
+		sys.Husk.HTTP2 = *configurationIn.HTTP2
+	}
+	// What one request may carry and how often one peer may call. A service's
+	// own maxBody, in its entry, overrides the first.
+	if configurationIn.MaxBody > 0 {
+		sys.Husk.MaxBody = configurationIn.MaxBody
+	}
+	if configurationIn.RateLimit != nil {
+		sys.Husk.RateLimit = *configurationIn.RateLimit
+	}
+	// Whether what the system is sent is held to its form's schema.
+	if configurationIn.Validate != nil {
+		sys.Husk.ValidateForms = *configurationIn.Validate
+	}
 	for _, ccore := range configurationIn.CCoreS {
 		newCore := ccore
 		sys.Husk.CoreS = append(sys.Husk.CoreS, &newCore)
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -509,3 +510,75 @@ func TestSeededCoreSystemsNameTheHostNotLocalhost(t *testing.T) {
 		t.Errorf("the authorizer is seeded as %q; adoption is per deployment", url)
 	}
 }
+
+// The preference for IPv6 is read from the file, and the core systems seeded
+// for an IPv6-only host are written as URLs a client can parse.
+func TestConfigureIPPreference(t *testing.T) {
+	t.Run("seeded URLs bracket an IPv6 host", func(t *testing.T) {
+		sys := components.NewSystem("ds18b20", context.Background())
+		sys.Husk = &components.Husk{
+			Host:      &components.HostingDevice{IPAddresses: []string{"fd00::7", "127.0.0.1"}},
+			ProtoPort: map[string]int{"http": 20150},
+			Details:   map[string][]string{},
+		}
+		sys.UAssets["sensor"] = &components.UnitAsset{Name: "sensor", Mission: components.MissionMeasurement}
+
+		config, err := setupDefaultConfig(&sys)
+		if err != nil {
+			t.Fatalf("building the default configuration: %v", err)
+		}
+		if config.IPPrefer != components.PreferIPv4 {
+			t.Errorf("the template writes ipPreference %q, want the default spelled out", config.IPPrefer)
+		}
+		for _, core := range config.CCoreS {
+			if core.Url != "" && !strings.HasPrefix(core.Url, "http://[fd00::7]:") {
+				t.Errorf("%s is seeded as %q", core.Name, core.Url)
+			}
+		}
+	})
+
+	for _, tc := range []struct {
+		preference string
+		want       string
+		fails      bool
+	}{
+		{"ipv6", components.PreferIPv6, false},
+		{"", "", false},
+		{"IPv6", "", true},
+	} {
+		t.Run("file says "+strconv.Quote(tc.preference), func(t *testing.T) {
+			testSys := createTestSystem(false)
+			if err := createConfigNoTraits(&testSys, 1); err != nil {
+				t.Fatalf("setup failed: %v", err)
+			}
+			defer cleanup()
+			raw, err := os.ReadFile("systemconfig.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var file map[string]any
+			if err := json.Unmarshal(raw, &file); err != nil {
+				t.Fatal(err)
+			}
+			file["ipPreference"] = tc.preference
+			raw, _ = json.Marshal(file)
+			if err := os.WriteFile("systemconfig.json", raw, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Configure(&testSys)
+			if tc.fails {
+				if err == nil {
+					t.Errorf("ipPreference %q was accepted", tc.preference)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Configure returned unexpected error: %v", err)
+			}
+			if testSys.Husk.Host.IPPreference != tc.want {
+				t.Errorf("preference is %q, want %q", testSys.Husk.Host.IPPreference, tc.want)
+			}
+		})
+	}
+}
@@ -20,11 +20,15 @@
 package usecases
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"slices"
+	"strconv"
 	"testing"
+	"time"
 
 	"net/http"
 	"net/url"
@@ -35,32 +39,119 @@ import (
 
 // GetState request the current state of a unit asset (via the asset's service)
 func GetState(cer *components.Cervice, sys *components.System) (f forms.Form, err error) {
-	return stateHandler(http.MethodGet, cer, sys, nil)
+	return GetStateCtx(systemContext(sys), cer, sys)
+}
+
+// GetStateCtx is GetState bounded by ctx: a control loop with a 200 ms period
+// gives it a 200 ms deadline, and the discovery and the request to the provider
+// stop when it passes; an answer that arrives regardless is not converted into
+// the consumer's unit. GetState is the same call with the system's context, so a
+// system shutting down cancels it.
+//
+// A call canceled or out of time is an error for which errors.Is finds
+// ctx.Err(), and it costs the cervice nothing: the provider was not found
+// wanting, only the caller's patience, so what was discovered is kept.
+func GetStateCtx(ctx context.Context, cer *components.Cervice, sys *components.System) (f forms.Form, err error) {
+	return stateHandler(ctx, http.MethodGet, cer, sys, nil)
 }
 
 // GetStates requests the current state of certain services of a unit asset depending on requested definition and/or details
 func GetStates(cer *components.Cervice, sys *components.System) (f []forms.Form, err []error) {
-	return stateHandlers(http.MethodGet, cer, sys, nil)
+	return GetStatesCtx(systemContext(sys), cer, sys)
+}
+
+// GetStatesCtx is GetStates bounded by ctx; see GetStateCtx.
+func GetStatesCtx(ctx context.Context, cer *components.Cervice, sys *components.System) (f []forms.Form, err []error) {
+	return stateHandlers(ctx, http.MethodGet, cer, sys, nil)
 }
 
 // SetState puts a request to change the state of a unit asset (via the asset's service)
 func SetState(cer *components.Cervice, sys *components.System, bodyBytes []byte) (f forms.Form, err error) {
-	return stateHandler(http.MethodPut, cer, sys, bodyBytes)
+	return SetStateCtx(systemContext(sys), cer, sys, bodyBytes)
+}
+
+// SetStateCtx is SetState bounded by ctx; see GetStateCtx. A write whose
+// deadline passes may still have reached the provider: the caller knows only
+// that no answer came in time.
+func SetStateCtx(ctx context.Context, cer *components.Cervice, sys *components.System, bodyBytes []byte) (f forms.Form, err error) {
+	return stateHandler(ctx, http.MethodPut, cer, sys, bodyBytes)
+}
+
+// Invoke posts a request for the provider to do something — run a command,
+// start a job — rather than to read or replace its state. A retry policy on the
+// cervice does not retry it unless the policy says the invocation is
+// idempotent: a command that reached the provider and whose answer was lost
+// would otherwise be carried out twice.
+func Invoke(cer *components.Cervice, sys *components.System, bodyBytes []byte) (f forms.Form, err error) {
+	return InvokeCtx(systemContext(sys), cer, sys, bodyBytes)
 }
 
-func stateHandler(httpMethod string, cer *components.Cervice, sys *components.System, bodyBytes []byte) (f forms.Form, err error) {
+// InvokeCtx is Invoke bounded by ctx; see GetStateCtx.
+func InvokeCtx(ctx context.Context, cer *components.Cervice, sys *components.System, bodyBytes []byte) (f forms.Form, err error) {
+	return stateHandler(ctx, http.MethodPost, cer, sys, bodyBytes)
+}
+
+// systemContext is the context a call made without one runs in: the system's,
+// so that shutting it down cancels what is in flight. A System built outside
+// the usual startup may have none.
+func systemContext(sys *components.System) context.Context {
+	if sys == nil || sys.Ctx == nil {
+		return context.Background()
+	}
+	return sys.Ctx
+}
+
+func stateHandler(ctx context.Context, httpMethod string, cer *components.Cervice, sys *components.System, bodyBytes []byte) (f forms.Form, err error) {
+	return stateRequest(ctx, httpMethod, cer, sys, bodyBytes, nil)
+}
+
+// stateRequest is stateHandler with a query for the provider, which a followed
+// value cannot answer.
+func stateRequest(ctx context.Context, httpMethod string, cer *components.Cervice, sys *components.System, bodyBytes []byte, query url.Values) (f forms.Form, err error) {
 	// The action is what this call will actually do, not what Cervice.Mode says
 	// it might. The provider recomputes it from the method, so a token minted for
 	// anything else is refused.
 	action := ActionForMethod(httpMethod)
 
+	// The root of a trace, unless the caller's context already carries one.
+	name := "GetState"
+	switch httpMethod {
+	case http.MethodGet:
+	case http.MethodPost:
+		name = "Invoke"
+	default:
+		name = "SetState"
+	}
+	ctx, span := StartSpan(ctx, sys, name+" "+cer.Definition, components.SpanInternal)
+	defer func() { span.End(err) }()
+
+	// Each attempt from the start: a retry after a provider was passed over goes
+	// to another, and one after everything was forgotten searches again.
+	for attempt := 1; ; attempt++ {
+		f, err = stateAttempt(ctx, span, httpMethod, action, cer, sys, bodyBytes, query)
+		wait, again := retryAfter(cer.Retry, httpMethod, attempt, err)
+		if !again {
+			return f, err
+		}
+		span.SetAttribute("retries", strconv.Itoa(attempt))
+		select {
+		case <-ctx.Done():
+			return f, err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// stateAttempt is one attempt at a stateRequest, within its span.
+func stateAttempt(ctx context.Context, span *Span, httpMethod, action string, cer *components.Cervice, sys *components.System,
+	bodyBytes []byte, query url.Values) (f forms.Form, err error) {
 	// Nothing discovered yet, or what is discovered was discovered for a
 	// different action — a cervice used for both a GET and a PUT, or one whose
 	// Mode did not describe this call. Either way, ask for this action rather
 	// than present a token minted for another one.
 	serviceUrl, token, found := pickNode(cer, action)
 	if !found {
-		if err = Search4ServicesAs(cer, sys, action); err != nil {
+		if err = search4ServicesAs(ctx, cer, sys, action); err != nil {
 			return f, err
 		}
 		serviceUrl, token, _ = pickNode(cer, action)
@@ -73,15 +164,16 @@ func stateHandler(httpMethod string, cer *components.Cervice, sys *components.Sy
 	//
 	// Only for a read. A PUT is an instruction to a provider and there is
 	// nothing cached about it.
-	if httpMethod == http.MethodGet {
+	if httpMethod == http.MethodGet && query == nil {
 		Follow(cer, sys)
 		if payload, mediaType, fresh := cer.Recall(); fresh {
-			f, err = Unpack(payload, mediaType)
+			span.SetAttribute("followed", "true")
+			f, err = UnpackAs(payload, mediaType, cer.FormVersion)
 			if err == nil {
 				// The same conversion a polled reading gets, by the same code:
 				// the provider publishes in its own unit and the consumer reads
 				// in the one it asked for, cached or not.
-				return NormalizeUnits(cer, f)
+				return normalizeTraced(ctx, cer, f)
 			}
 			// Unreadable, so fall through and ask. Something changed at the
 			// other end that this consumer does not understand, and a request
@@ -90,13 +182,26 @@ func stateHandler(httpMethod string, cer *components.Cervice, sys *components.Sy
 		}
 	}
 
-	resp, err := sendHTTPReqWithToken(httpMethod, serviceUrl, token, bodyBytes)
+	providerUrl := serviceUrl
+	if query != nil {
+		serviceUrl += "?" + query.Encode()
+	}
+
+	circuit := breakerFor(cer, providerUrl)
+	if err = circuit.admit(); err != nil {
+		return f, circuitOpenError(providerUrl)
+	}
+	start := time.Now()
+	defer func() { observeConsumption(sys, cer, httpMethod, start, err) }()
+	resp, err := sendHTTPReqWithToken(acceptingVersion(ctx, cer.FormVersion), httpMethod, serviceUrl, token, bodyBytes)
+	circuit.record(cer, providerUrl, err)
+	if err != nil && ctx.Err() != nil {
+		// The caller stopped waiting, which says nothing about the provider.
+		return f, err
+	}
+	observeProvider(cer, providerUrl, start, err)
 	if err != nil {
-		// Failed to reach the provider: forget everything discovered, so the next
-		// call searches again.
-		cer.Mutex.Lock()
-		cer.Nodes = make(map[string][]components.NodeInfo)
-		cer.Mutex.Unlock()
+		settleFailure(cer, providerUrl, action, err)
 		return f, err
 	}
 	defer resp.Body.Close()
@@ -113,31 +218,79 @@ func stateHandler(httpMethod string, cer *components.Cervice, sys *components.Sy
 	}
 
 	headerContentType := resp.Header.Get("Content-Type")
-	f, err = Unpack(bodyBytes, headerContentType)
+	f, err = UnpackAs(bodyBytes, headerContentType, cer.FormVersion)
 	if err != nil {
 		return f, err
 	}
 	// The provider answers in its own unit; the consumer reads in the one it
 	// asked for. Neither has to know about the other.
-	return NormalizeUnits(cer, f)
+	return normalizeTraced(ctx, cer, f)
 }
 
-// pickNode returns the first node discovered for one action, and whether any
-// was. It looks past the first entry: a cervice discovered for two actions holds
-// one node per provider, but a provider that answered only one of the two
-// discoveries is present without a token for the other.
+// pickNode returns the node a call for one action goes to, as the cervice's
+// Selector chooses among those discovered for it, and whether any was. It looks
+// past the first entry: a cervice discovered for two actions holds one node per
+// provider, but a provider that answered only one of the two discoveries is
+// present without a token for the other.
 func pickNode(cer *components.Cervice, action string) (url, token string, ok bool) {
-	cer.Mutex.RLock()
-	defer cer.Mutex.RUnlock()
+	var candidates []components.NodeInfo
+	for _, ni := range cer.Providers() {
+		if _, discovered := ni.TokenFor(action); discovered {
+			candidates = append(candidates, ni)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", "", false
+	}
+	// A provider whose circuit is open is chosen only when every one's is, and
+	// the call then fails at once.
+	if closed := slices.DeleteFunc(slices.Clone(candidates), func(ni components.NodeInfo) bool {
+		return breakerFor(cer, ni.URL).isOpen()
+	}); len(closed) > 0 {
+		candidates = closed
+	}
+	ni := candidates[chooseAmong(cer.Selector, candidates)]
+	token, _ = ni.TokenFor(action)
+	return ni.URL, token, true
+}
 
-	for _, nodes := range cer.Nodes {
-		for _, ni := range nodes {
-			if tok, discovered := ni.TokenFor(action); discovered {
-				return ni.URL, tok, true
-			}
+// failOver drops one provider that failed a call for action, so that the next
+// call goes to another, and reports whether another remains. Only its token is
+// forgotten, as in a GetStates round; with nothing left to fail over to, the
+// caller forgets everything and the next call searches again.
+func failOver(cer *components.Cervice, url, action string) bool {
+	others := false
+	for _, ni := range cer.Providers() {
+		if _, discovered := ni.TokenFor(action); discovered && ni.URL != url {
+			others = true
 		}
 	}
-	return "", "", false
+	if others {
+		forgetToken(cer, url, action)
+	}
+	return others
+}
+
+// settleFailure does what a failed call warrants, by its class. A provider
+// that could not be reached is passed over for another where one is discovered
+// for this action, and otherwise everything discovered is forgotten, so the
+// next call searches again. One that refused the token loses it, and the next
+// call obtains another. One that is busy, or that refused what was sent, keeps
+// everything: rediscovering it would find the same provider with the same
+// token, at the cost of a round trip to the orchestrator on every call.
+func settleFailure(cer *components.Cervice, url, action string, err error) {
+	class, _ := ClassOf(err)
+	switch class {
+	case ProviderUnreachable:
+		if failOver(cer, url, action) {
+			return
+		}
+		cer.Mutex.Lock()
+		cer.Nodes = make(map[string][]components.NodeInfo)
+		cer.Mutex.Unlock()
+	case ProviderUnauthorized:
+		forgetToken(cer, url, action)
+	}
 }
 
 const messengerMaxErrors int = 3
@@ -192,6 +345,9 @@ func Log(sys *components.System, lvl forms.MessageLevel, msg string, args ...any
 	failed := make(map[string]bool, len(messengers))
 	for host := range messengers {
 		failed[host] = sendLogMessage(host, body) != nil
+		if failed[host] {
+			metricsOf(sys).Add(metricMessengerErrs, "Log messages a messenger could not be sent.", nil, 1)
+		}
 	}
 
 	sys.Mutex.Lock()
@@ -232,16 +388,42 @@ func sendLogMessage(host string, body []byte) error {
 	return nil
 }
 
-func stateHandlers(httpMethod string, cer *components.Cervice, sys *components.System, bodyBytes []byte) (f []forms.Form, err []error) {
+func stateHandlers(ctx context.Context, httpMethod string, cer *components.Cervice, sys *components.System, bodyBytes []byte) (f []forms.Form, err []error) {
+	states, roundErr := providerStates(ctx, httpMethod, cer, sys, bodyBytes)
+	if roundErr != nil {
+		return []forms.Form{nil}, []error{roundErr}
+	}
+	for _, state := range states {
+		f = append(f, state.Form)
+		err = append(err, state.Err)
+	}
+	return f, err
+}
+
+// providerStates is a round of GetStates: every provider asked, and each
+// answer filed under the provider that gave it. The error is for a round that
+// could not be made at all.
+func providerStates(ctx context.Context, httpMethod string, cer *components.Cervice, sys *components.System, bodyBytes []byte) (states []ProviderState, err error) {
 	// As in stateHandler: the action is what this call performs, not what
 	// Cervice.Mode says it might.
 	action := ActionForMethod(httpMethod)
 
+	ctx, span := StartSpan(ctx, sys, "GetStates "+cer.Definition, components.SpanInternal)
+	defer func() {
+		if err == nil {
+			errs := make([]error, len(states))
+			for i, state := range states {
+				errs[i] = state.Err
+			}
+			span.End(errors.Join(errs...))
+			return
+		}
+		span.End(err)
+	}()
+
 	if cer.ProviderCount() == 0 {
-		if currentErr := Search4MultipleServicesAs(cer, sys, action); currentErr != nil {
-			f = append(f, nil)
-			err = append(err, currentErr)
-			return f, err
+		if err = search4MultipleServicesAs(ctx, cer, sys, action); err != nil {
+			return nil, err
 		}
 	}
 
@@ -254,18 +436,16 @@ func stateHandlers(httpMethod string, cer *components.Cervice, sys *components.S
 	// from it is `fatal error: concurrent map iteration and map write`; holding
 	// the lock across the requests instead would block every other user of this
 	// cervice for as long as the slowest provider takes to time out.
-	providers := cer.Providers()
+	nodes, providers := cer.ProvidersByNode()
 
 	// Discovered for a different action than this call performs. One round for
 	// the whole cervice rather than one per provider: they were all discovered
 	// together and they all need the same action.
 	if needsDiscovery(providers, action) {
-		if currentErr := Search4MultipleServicesAs(cer, sys, action); currentErr != nil {
-			f = append(f, nil)
-			err = append(err, currentErr)
-			return f, err
+		if err = search4MultipleServicesAs(ctx, cer, sys, action); err != nil {
+			return nil, err
 		}
-		providers = cer.Providers()
+		nodes, providers = cer.ProvidersByNode()
 	}
 
 	// No providers is an answer, and it has to be given as one. Returning empty
@@ -275,51 +455,30 @@ func stateHandlers(httpMethod string, cer *components.Cervice, sys *components.S
 	// registrar restarting, or a detail that stopped matching, is enough to
 	// produce it.
 	if len(providers) == 0 {
-		return []forms.Form{nil}, []error{
-			fmt.Errorf("no provider of %q is available for %s", cer.Definition, action)}
+		return nil, fmt.Errorf("no provider of %q is available for %s", cer.Definition, action)
 	}
 
-	failures := 0
-	for _, ni := range providers {
+	for i, ni := range providers {
 		if len(ni.URL) == 0 {
 			continue
 		}
-		formValue, currentErr := askOneProvider(httpMethod, ni, cer, action, bodyBytes)
-		if currentErr != nil {
-			failures++
-			// Forget this one provider's token, not the whole set. Clearing
-			// everything on one failure threw away the providers that had just
-			// answered; clearing nothing until they had all failed left a
-			// powered-off sensor in the list forever, retried every round at the
-			// cost of its own timeout, and still there long after the registrar
-			// had stopped listing it. Without a token for this action the node
-			// is rediscovered on the next call, which either finds it again or
-			// does not.
-			if errors.As(currentErr, &staleProvider{}) {
-				forgetToken(cer, ni.URL, action)
-			}
-			f = append(f, nil)
-			err = append(err, currentErr)
-			continue
-		}
-		f = append(f, formValue)
-		err = append(err, nil)
+		states = append(states, ProviderState{Node: nodes[i], URL: ni.URL, provider: ni})
 	}
-
-	_ = failures // every failure has already forgotten its own provider
-
-	return f, err
+	askProviders(ctx, httpMethod, cer, sys, action, bodyBytes, states)
+	return states, nil
 }
 
 // staleProvider marks a failure that says something about the *provider* rather
 // than about the answer it gave.
 //
 // Only these are worth forgetting a token over: it could not be reached, or it
-// refused the credential. An empty body, a form version this consumer does not
-// know, a unit it cannot convert — those are the provider answering, and it is
-// still the provider it was discovered as. Forgetting the token on those meant
-// one sensor answering in an unknown form cost a full rediscovery of every
-// provider on every poll thereafter, for as long as it kept answering.
+// refused the credential (see ErrorClass). An empty body, a form version this
+// consumer does not know, a unit it cannot convert — those are the provider
+// answering, and it is still the provider it was discovered as. Forgetting the
+// token on those meant one sensor answering in an unknown form cost a full
+// rediscovery of every provider on every poll thereafter, for as long as it
+// kept answering. So did a 503 from a provider still warming up, and a 400 for
+// this consumer's own payload.
 type staleProvider struct{ err error }
 
 func (s staleProvider) Error() string { return s.err.Error() }
@@ -339,7 +498,7 @@ func forgetToken(cer *components.Cervice, url, action string) {
 	for node, nodes := range cer.Nodes {
 		for i, ni := range nodes {
 			if ni.URL == url && ni.Tokens != nil {
-				delete(ni.Tokens, action)
+				ni.Tokens = withoutToken(ni.Tokens, action)
 				cer.Nodes[node][i] = ni
 			}
 		}
@@ -367,13 +526,18 @@ func needsDiscovery(providers []components.NodeInfo, action string) bool {
 // closed when this provider is done with. The loop used to defer every Close to
 // the end of the round, holding one connection open per provider for the
 // duration of the slowest of them.
-func askOneProvider(httpMethod string, ni components.NodeInfo, cer *components.Cervice, action string, bodyBytes []byte) (forms.Form, error) {
+func askOneProvider(ctx context.Context, httpMethod string, ni components.NodeInfo, cer *components.Cervice, action string, bodyBytes []byte) (forms.Form, error) {
 	token, _ := ni.TokenFor(action)
-	resp, err := sendHTTPReqWithToken(httpMethod, ni.URL, token, bodyBytes)
-	if err != nil {
-		// Unreachable: whatever was discovered is not there now.
+	resp, err := sendHTTPReqWithToken(acceptingVersion(ctx, cer.FormVersion), httpMethod, ni.URL, token, bodyBytes)
+	if class, _ := ClassOf(err); class.costsTheToken() {
+		// Unreachable, or the token refused: whatever was discovered is not
+		// there now, or not good for this.
 		return nil, staleProvider{err}
 	}
+	if err != nil {
+		// Out of time, busy, or refusing what was sent: the token is kept.
+		return nil, err
+	}
 	defer resp.Body.Close()
 
 	// Every non-2xx answer, refusal included, has already been turned into an
@@ -391,7 +555,7 @@ func askOneProvider(httpMethod string, ni components.NodeInfo, cer *components.C
 		return nil, fmt.Errorf("got empty response body")
 	}
 
-	formValue, err := Unpack(respBytes, resp.Header.Get("Content-Type"))
+	formValue, err := UnpackAs(respBytes, resp.Header.Get("Content-Type"), cer.FormVersion)
 	if err != nil {
 		return nil, fmt.Errorf("unpacking response body: %w", err)
 	}
@@ -399,5 +563,5 @@ func askOneProvider(httpMethod string, ni components.NodeInfo, cer *components.C
 	// Each provider answers in its own unit. Without this the caller received a
 	// mixture — °C from one sensor and °F from the next — with nothing in the
 	// slice to say which was which.
-	return NormalizeUnits(cer, formValue)
+	return normalizeTraced(ctx, cer, formValue)
 }
@@ -17,6 +17,7 @@
 package usecases
 
 import (
+	"context"
 	jsonpkg "encoding/json"
 	"fmt"
 	"io"
@@ -182,7 +183,7 @@ func TestGetStatesDoesNotSendOneProvidersAnswerToTheNext(t *testing.T) {
 
 	sys := createTestSystem(false)
 	request := []byte(signalBody(99, "<http://qudt.org/vocab/unit/DEG_C>"))
-	if _, errs := stateHandlers(http.MethodPut, cer, &sys, request); errs[0] != nil || errs[1] != nil {
+	if _, errs := stateHandlers(context.Background(), http.MethodPut, cer, &sys, request); errs[0] != nil || errs[1] != nil {
 		t.Fatalf("unexpected errors: %v", errs)
 	}
 
@@ -691,7 +692,7 @@ func TestAnEmptyRoundIsAnError(t *testing.T) {
 	cer := multiProviderCervice(nil, map[string][]string{"Forms": {"SignalA_v1a"}})
 	sys := createTestSystem(false)
 
-	forms, errs := stateHandlers(http.MethodGet, cer, &sys, nil)
+	forms, errs := stateHandlers(context.Background(), http.MethodGet, cer, &sys, nil)
 	if len(errs) == 0 {
 		t.Fatal("a round with no providers reported no error at all")
 	}
@@ -731,7 +732,7 @@ func TestOnlyTheProviderItselfCostsItsToken(t *testing.T) {
 	}, map[string][]string{"Forms": {"SignalA_v1a"}})
 	sys := createTestSystem(false)
 
-	_, errs := stateHandlers(http.MethodGet, cer, &sys, nil)
+	_, errs := stateHandlers(context.Background(), http.MethodGet, cer, &sys, nil)
 	if len(errs) == 0 || errs[0] == nil {
 		t.Fatal("an unreadable answer was not reported")
 	}
@@ -759,7 +760,7 @@ func TestARefusedCredentialCostsItsToken(t *testing.T) {
 	}, map[string][]string{"Forms": {"SignalA_v1a"}})
 	sys := createTestSystem(false)
 
-	_, errs := stateHandlers(http.MethodGet, cer, &sys, nil)
+	_, errs := stateHandlers(context.Background(), http.MethodGet, cer, &sys, nil)
 	if len(errs) == 0 || errs[0] == nil {
 		t.Fatal("a refusal was not reported")
 	}
@@ -805,7 +806,7 @@ func TestAProviderThatIsNotReadySaysSo(t *testing.T) {
 	}, map[string][]string{"Forms": {"SignalA_v1a"}})
 	sys := createTestSystem(false)
 
-	_, errs := stateHandlers(http.MethodGet, cer, &sys, nil)
+	_, errs := stateHandlers(context.Background(), http.MethodGet, cer, &sys, nil)
 	if len(errs) == 0 || errs[0] == nil {
 		t.Fatal("a provider that refused to serve was not reported")
 	}
@@ -816,12 +817,14 @@ func TestAProviderThatIsNotReadySaysSo(t *testing.T) {
 		t.Errorf("the failure reads %q, which does not say the provider is unavailable", errs[0])
 	}
 
-	// The token does not survive, and that is the current behavior rather than
-	// a decision: askOneProvider marks every failed request a stale provider,
-	// so a 503 costs the token exactly as a 403 does. It is recorded here so a
-	// change to it is deliberate. The cost is one rediscovery per poll while a
-	// provider warms up, which the retry backoff has already cut to seconds.
-	if _, _, ok := pickNode(cer, "read"); ok {
-		t.Log("a 503 now keeps its token; if that was intended, update this test")
+	// The token survives: a provider that is busy is still the provider it was
+	// discovered as, and the token it will accept once it has the key is the
+	// one it has. Forgetting it cost one rediscovery per poll while a provider
+	// warmed up.
+	if class, _ := ClassOf(errs[0]); class != ProviderBusy {
+		t.Errorf("a 503 is classed %q", class)
+	}
+	if _, _, ok := pickNode(cer, "read"); !ok {
+		t.Error("a 503 cost the provider its token")
 	}
 }
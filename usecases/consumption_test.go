@@ -11,6 +11,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sdoque/mbaigo/components"
 	"github.com/sdoque/mbaigo/forms"
@@ -387,6 +388,9 @@ var getStatesTestParams = []getStatesTestStruct{
 func TestGetStates(t *testing.T) {
 	for _, testCase := range getStatesTestParams {
 		testCer := newTestCerviceWithoutNodes()
+		// The mock fails the nth request, which names a provider only when
+		// they are asked one at a time.
+		testCer.Fanout = 1
 		testSys := createTestSystem(false)
 		newMockTransport(t, testCase.body, testCase.mockTransportErr, testCase.errHTTP)
 
@@ -430,8 +434,10 @@ func TestGetStates(t *testing.T) {
 	newMockTransport(t, createWorkingHttpResp(), 0, nil)
 
 	res, err = GetStates(&cerWithBrokenUrlNode, &testSys)
-	expectedForm = []forms.Form{form.NewForm(), nil, form.NewForm()}
-	expectedErr = []error{nil, fmt.Errorf("Error"), nil}
+	// In the order of their URLs, not the one they were listed in, and the
+	// broken one sorts first.
+	expectedForm = []forms.Form{nil, form.NewForm(), form.NewForm()}
+	expectedErr = []error{fmt.Errorf("Error"), nil, nil}
 
 	if !formsEqual(res, expectedForm) || !errEqual(err, expectedErr) {
 		t.Errorf("Test case: Error with broken url \nExpected forms: %v\nGot: %v\nExpected error: %v, Got error: %v",
@@ -521,3 +527,114 @@ func TestLog(t *testing.T) {
 		t.Errorf("expected error count %d, got %d", want, got)
 	}
 }
+
+// waitForCaller is a provider that never answers: the request ends when the
+// caller stops waiting for it.
+func waitForCaller(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+// neverAnswers is the status of a fakeProvider that does not answer at all.
+const neverAnswers = -1
+
+// fakeProvider installs a transport that answers each request with the status
+// statusFor gives it: a reading for 200, a refused connection for 0, nothing
+// until the caller gives up for neverAnswers, and "no" for any other status.
+func fakeProvider(t *testing.T, statusFor func(*http.Request) int) {
+	t.Helper()
+	answer := createWorkingHttpResp()
+	useTransport(t, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		switch code := statusFor(req); code {
+		case neverAnswers:
+			return waitForCaller(req)
+		case 0:
+			return nil, errors.New("connection refused")
+		case http.StatusOK:
+			resp := answer()
+			resp.Request = req
+			return resp, nil
+		default:
+			return &http.Response{
+				Status: http.StatusText(code), StatusCode: code,
+				Body: io.NopCloser(strings.NewReader("no")), Request: req,
+			}, nil
+		}
+	}))
+}
+
+// A deadline bounds the call, and running out of time is not held against the
+// provider: what was discovered is still there for the next call.
+func TestAStateCallEndsAtItsDeadline(t *testing.T) {
+	useTransport(t, roundTripperFunc(waitForCaller))
+	sys := createTestSystem(false)
+	cer := newTestCerviceWithNodes()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := GetStateCtx(ctx, cer, &sys)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the deadline", err)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("took %v", took)
+	}
+	if cer.ProviderCount() != 1 {
+		t.Error("a caller's deadline forgot the provider")
+	}
+
+	cer = multiProviderCervice([]components.NodeInfo{
+		{URL: "http://slow/temperature", Tokens: map[string]string{"read": "good"}},
+	}, map[string][]string{"Forms": {"SignalA_v1a"}})
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, errs := GetStatesCtx(ctx, cer, &sys); !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Fatalf("errs = %v, want the deadline", errs)
+	}
+	if _, kept := cer.Providers()[0].TokenFor("read"); !kept {
+		t.Error("a caller's deadline forgot the provider's token")
+	}
+}
+
+// A provider that answers only once the deadline has passed is too late too:
+// its answer is not converted and handed back as if it were in time.
+func TestALateAnswerIsNotConverted(t *testing.T) {
+	useTransport(t, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return createWorkingHttpResp()(), nil
+	}))
+	sys := createTestSystem(false)
+	cer := newTestCerviceWithNodes()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if f, err := GetStateCtx(ctx, cer, &sys); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, %v; want the deadline", f, err)
+	}
+}
+
+// A call made without a context runs in the system's, and a system that is
+// shutting down cancels it.
+func TestAStateCallEndsWithTheSystem(t *testing.T) {
+	useTransport(t, roundTripperFunc(waitForCaller))
+	sys := createTestSystem(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	sys.Ctx = ctx
+	cer := newTestCerviceWithNodes()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := SetState(cer, &sys, []byte(`{"version": "SignalA_v1.0"}`))
+		done <- err
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("SetState outlived the system")
+	}
+}
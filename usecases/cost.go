@@ -79,6 +79,10 @@ func ACServices(w http.ResponseWriter, r *http.Request, ua *components.UnitAsset
 	case "PUT":
 		defer r.Body.Close()
 		bodyBytes, err := io.ReadAll(r.Body) // Use io.ReadAll instead of ioutil.ReadAll
+		if BodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Error reading registration response body"+err.Error(), http.StatusBadRequest)
 			return
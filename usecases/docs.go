@@ -28,6 +28,7 @@
 package usecases
 
 import (
+	"encoding/json"
 	"fmt"
 	"html"
 	"log"
@@ -53,7 +54,7 @@ func SysHateoas(w http.ResponseWriter, req *http.Request, sys components.System)
 		for key, values := range (*unitasset).GetDetails() {
 			metaservice += html.EscapeString(key) + ": " + html.EscapeString(fmt.Sprintf("%v", values)) + " "
 		}
-		text += "<li><b><a href=\"http://" + sys.Husk.Host.IPAddresses[0] + ":" + strconv.Itoa(sys.Husk.ProtoPort["http"]) + "/" + html.EscapeString(sys.Name) + "/" + html.EscapeString((*unitasset).GetName()) + "/doc" + "\">" + html.EscapeString((*unitasset).GetName()) + "</a></b> with details " + metaservice + "</li>\n"
+		text += "<li><b><a href=\"http://" + components.HostPort(servingAddress(&sys), sys.Husk.ProtoPort["http"]) + "/" + html.EscapeString(sys.Name) + "/" + html.EscapeString((*unitasset).GetName()) + "/doc" + "\">" + html.EscapeString((*unitasset).GetName()) + "</a></b> with details " + metaservice + "</li>\n"
 	}
 
 	// This part of the code is commented out because it is not used in the current implementation because the assets on a PLC might have different services
@@ -80,6 +81,9 @@ func SysHateoas(w http.ResponseWriter, req *http.Request, sys components.System)
 		text += "<li> " + html.EscapeString(IPAddre) + "</em></li>\n"
 	}
 
+	text += "</ul> <p> The forms it can read and send, each with its JSON Schema:</p><ul>\n"
+	text += formSchemasHTML(sys.Name)
+
 	text += "</ul></body></html>"
 	_, err := w.Write([]byte(text))
 	if err != nil {
@@ -105,7 +109,7 @@ func ResHateoas(w http.ResponseWriter, req *http.Request, ua components.UnitAsse
 		for key, values := range service.Details {
 			metaservice += html.EscapeString(key) + ": " + html.EscapeString(fmt.Sprintf("%v", values)) + " "
 		}
-		text += "<li><a href=\"http://" + sys.Husk.Host.IPAddresses[0] + ":" + strconv.Itoa(sys.Husk.ProtoPort["http"]) + "/" + html.EscapeString(sys.Name) + "/" + html.EscapeString(uaName) + "/" + html.EscapeString(service.SubPath) + "/doc\">" + html.EscapeString(service.Definition) + "</a> with details: " + metaservice + "</li>\n"
+		text += "<li><a href=\"http://" + components.HostPort(servingAddress(&sys), sys.Husk.ProtoPort["http"]) + "/" + html.EscapeString(sys.Name) + "/" + html.EscapeString(uaName) + "/" + html.EscapeString(service.SubPath) + "/doc\">" + html.EscapeString(service.Definition) + "</a> with details: " + metaservice + "</li>\n"
 	}
 
 	text += "</ul></body></html>"
@@ -126,13 +130,33 @@ func ServiceHateoas(w http.ResponseWriter, req *http.Request, serv components.Se
 	for key, values := range serv.Details {
 		metaservice += html.EscapeString(key) + ": " + html.EscapeString(fmt.Sprintf("%v", values)) + " "
 	}
-	text += "The service <b><a href=\"http://" + sys.Husk.Host.IPAddresses[0] + ":" + strconv.Itoa(sys.Husk.ProtoPort["http"]) + "/" + html.EscapeString(sys.Name) + "/" + html.EscapeString(uaName) + "/" + html.EscapeString(serv.SubPath) + "\">" + html.EscapeString(serv.Definition) + "</a> </b> " + html.EscapeString(serv.Description) + " and has the details " + metaservice
+	text += "The service <b><a href=\"http://" + components.HostPort(servingAddress(&sys), sys.Husk.ProtoPort["http"]) + "/" + html.EscapeString(sys.Name) + "/" + html.EscapeString(uaName) + "/" + html.EscapeString(serv.SubPath) + "\">" + html.EscapeString(serv.Definition) + "</a> </b> " + html.EscapeString(serv.Description) + " and has the details " + metaservice
 	_, err := w.Write([]byte(text))
 	if err != nil {
 		log.Printf("Error while writing response body for ServiceHateoas: %v", err)
 	}
 }
 
+// formSchemasHTML lists the registered forms, each linked to its schema and with
+// the schema itself folded beneath, so a page saved for reading offline still
+// has them.
+func formSchemasHTML(sysName string) string {
+	text := ""
+	for _, version := range formVersions() {
+		schema, err := FormSchema(version)
+		if err != nil {
+			continue
+		}
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			continue
+		}
+		text += "<li><details><summary><a href=\"/" + html.EscapeString(sysName) + "/forms/" + html.EscapeString(version) + "\">" +
+			html.EscapeString(version) + "</a></summary><pre>" + html.EscapeString(string(data)) + "</pre></details></li>\n"
+	}
+	return text
+}
+
 // // getFirstAsset returns the first key-value pair in the Assets map
 // func getFirstAsset(assetMap map[string]*components.UnitAsset) []components.UnitAsset {
 // 	var assetList []components.UnitAsset
@@ -0,0 +1,154 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+// Fan-out: a GetStates round asks its providers at once rather than in turn.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// The bounds of a GetStates round where the cervice sets none (see
+// Cervice.Fanout, ProviderTimeout and RoundTimeout).
+const (
+	defaultFanout          = 8
+	defaultProviderTimeout = 5 * time.Second
+	defaultRoundTimeout    = 10 * time.Second
+)
+
+// ProviderState is one provider's part in a GetStates round: who it is, and
+// what it answered or why it did not.
+type ProviderState struct {
+	Node string     // the node it was discovered under
+	URL  string     // its service's URL
+	Form forms.Form // its reading, in the cervice's unit; nil where Err is not
+	Err  error
+
+	provider components.NodeInfo // what it was discovered as, token included
+}
+
+// GetProviderStates asks every provider of the cervice for its state and
+// returns each answer beside the provider that gave it, ordered by node and
+// URL the same way on every round. It is GetStates for a caller that needs to
+// know which sensor said what: a slice of forms with a nil in it says a reading
+// is missing, not whose.
+//
+// The error is for a round that could not be made — no orchestrator, or no
+// provider — and a provider that fails is an Err in its own entry.
+func GetProviderStates(cer *components.Cervice, sys *components.System) ([]ProviderState, error) {
+	return GetProviderStatesCtx(systemContext(sys), cer, sys)
+}
+
+// GetProviderStatesCtx is GetProviderStates bounded by ctx; see GetStateCtx.
+func GetProviderStatesCtx(ctx context.Context, cer *components.Cervice, sys *components.System) ([]ProviderState, error) {
+	return providerStates(ctx, http.MethodGet, cer, sys, nil)
+}
+
+// askProviders fills in states, asking up to the cervice's Fanout providers at
+// a time. Asked in turn, one sensor that does not answer held up every provider
+// after it for its full timeout, and a round of twenty could take minutes. Each
+// provider now has ProviderTimeout to answer and the round RoundTimeout
+// altogether, within whatever ctx allows; a provider still waiting for a turn
+// when the round ends is reported as not asked.
+//
+// Each request writes only its own entry, so the order is the one states came
+// in whatever order the answers arrive in.
+func askProviders(ctx context.Context, httpMethod string, cer *components.Cervice, sys *components.System,
+	action string, bodyBytes []byte, states []ProviderState) {
+	fanout, perProvider, perRound := roundBounds(cer)
+	ctx, cancel := context.WithTimeout(ctx, perRound)
+	defer cancel()
+
+	turns := make(chan struct{}, fanout)
+	var wg sync.WaitGroup
+	for i := range states {
+		select {
+		case turns <- struct{}{}:
+		case <-ctx.Done():
+			states[i].Err = fmt.Errorf("%s was not asked: %w", states[i].URL, ctx.Err())
+			continue
+		}
+		wg.Add(1)
+		go func(state *ProviderState) {
+			defer func() { <-turns; wg.Done() }()
+			askInTime(ctx, perProvider, httpMethod, cer, sys, action, bodyBytes, state)
+		}(&states[i])
+	}
+	wg.Wait()
+}
+
+// askInTime asks one provider, and gives it perProvider to answer.
+func askInTime(round context.Context, perProvider time.Duration, httpMethod string, cer *components.Cervice,
+	sys *components.System, action string, bodyBytes []byte, state *ProviderState) {
+	circuit := breakerFor(cer, state.URL)
+	if err := circuit.admit(); err != nil {
+		state.Err = circuitOpenError(state.URL)
+		return
+	}
+	ctx, cancel := context.WithTimeout(round, perProvider)
+	defer cancel()
+
+	start := time.Now()
+	state.Form, state.Err = askOneProvider(ctx, httpMethod, state.provider, cer, action, bodyBytes)
+	if state.Err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && round.Err() == nil {
+		// Its own deadline, not the round's or the caller's: this provider did
+		// not answer, where the others had time to.
+		state.Err = staleProvider{&ProviderError{URL: state.URL, Class: ProviderUnreachable,
+			Err: fmt.Errorf("%s did not answer within %v: %w", state.URL, perProvider, state.Err)}}
+	}
+	circuit.record(cer, state.URL, state.Err)
+	observeConsumption(sys, cer, httpMethod, start, state.Err)
+	if round.Err() == nil {
+		observeProvider(cer, state.URL, start, state.Err)
+	}
+	if state.Err == nil {
+		return
+	}
+	// Forget this one provider's token, not the whole set. Clearing everything
+	// on one failure threw away the providers that had just answered; clearing
+	// nothing until they had all failed left a powered-off sensor in the list
+	// forever, retried every round at the cost of its own timeout, and still
+	// there long after the registrar had stopped listing it. Without a token for
+	// this action the node is rediscovered on the next call, which either finds
+	// it again or does not.
+	if errors.As(state.Err, &staleProvider{}) {
+		forgetToken(cer, state.URL, action)
+	}
+}
+
+// roundBounds are the cervice's bounds on a round, defaults filled in.
+func roundBounds(cer *components.Cervice) (fanout int, perProvider, perRound time.Duration) {
+	fanout, perProvider, perRound = cer.Fanout, cer.ProviderTimeout, cer.RoundTimeout
+	if fanout <= 0 {
+		fanout = defaultFanout
+	}
+	if perProvider <= 0 {
+		perProvider = defaultProviderTimeout
+	}
+	if perRound <= 0 {
+		perRound = defaultRoundTimeout
+	}
+	return
+}
@@ -0,0 +1,172 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// A round of providers where the one on "silent" never answers.
+func fanoutRound(t *testing.T) *components.Cervice {
+	t.Helper()
+	fakeProvider(t, func(req *http.Request) int {
+		if strings.Contains(req.URL.Host, "silent") {
+			return neverAnswers
+		}
+		return http.StatusOK
+	})
+	return &components.Cervice{
+		Definition: "temperature",
+		Nodes: map[string][]components.NodeInfo{
+			"zone2": {readNode("http://b/temperature"), readNode("http://silent/temperature")},
+			"zone1": {readNode("http://a/temperature")},
+		},
+		ProviderTimeout: 100 * time.Millisecond,
+	}
+}
+
+// A provider that does not answer costs the round its own timeout and no more,
+// the answers come back under the providers that gave them, in the same order
+// every round, and only the silent one's token is forgotten.
+func TestAProviderThatDoesNotAnswerDoesNotHoldUpTheRound(t *testing.T) {
+	sys := createTestSystem(false)
+	cer := fanoutRound(t)
+
+	start := time.Now()
+	states, err := GetProviderStates(cer, &sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("the round took %v", took)
+	}
+	var order []string
+	for _, s := range states {
+		order = append(order, s.Node+" "+s.URL)
+	}
+	want := []string{"zone1 http://a/temperature", "zone2 http://b/temperature", "zone2 http://silent/temperature"}
+	if !equalStrings(order, want) {
+		t.Fatalf("order = %q, want %q", order, want)
+	}
+	if states[0].Err != nil || states[1].Err != nil || states[0].Form == nil {
+		t.Errorf("the answering providers: %+v", states[:2])
+	}
+	if !errors.Is(states[2].Err, context.DeadlineExceeded) || states[2].Form != nil {
+		t.Errorf("the silent provider: %+v", states[2])
+	}
+	for _, ni := range cer.Providers() {
+		_, kept := ni.TokenFor("read")
+		if kept == strings.Contains(ni.URL, "silent") {
+			t.Errorf("%s: token kept %v", ni.URL, kept)
+		}
+	}
+
+	// GetStates is the same round, without the names.
+	cer = fanoutRound(t)
+	f, errs := GetStates(cer, &sys)
+	if len(f) != 3 || f[2] != nil || errs[0] != nil || errs[2] == nil {
+		t.Errorf("GetStates = %v, %v", f, errs)
+	}
+}
+
+// No more than Fanout providers are asked at once, and a round that runs out of
+// time reports the providers it did not get to.
+func TestARoundIsBounded(t *testing.T) {
+	sys := createTestSystem(false)
+	var mu sync.Mutex
+	asking, most := 0, 0
+	answer := createWorkingHttpResp()
+	useTransport(t, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		asking++
+		most = max(most, asking)
+		mu.Unlock()
+		defer func() { mu.Lock(); asking--; mu.Unlock() }()
+		time.Sleep(20 * time.Millisecond)
+		resp := answer()
+		resp.Request = req
+		return resp, nil
+	}))
+	nodes := map[string][]components.NodeInfo{}
+	for _, host := range []string{"a", "b", "c", "d", "e", "f"} {
+		nodes[host] = []components.NodeInfo{readNode("http://" + host + "/temperature")}
+	}
+
+	cer := &components.Cervice{Definition: "temperature", Nodes: nodes, Fanout: 2}
+	states, err := GetProviderStates(cer, &sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range states {
+		if s.Err != nil {
+			t.Errorf("%s: %v", s.URL, s.Err)
+		}
+	}
+	if most != 2 {
+		t.Errorf("%d providers were asked at once, want 2", most)
+	}
+
+	cer = &components.Cervice{Definition: "temperature", Nodes: nodes, Fanout: 1, RoundTimeout: 50 * time.Millisecond}
+	states, _ = GetProviderStates(cer, &sys)
+	if last := states[len(states)-1]; !errors.Is(last.Err, context.DeadlineExceeded) {
+		t.Errorf("the last provider of a round out of time: %v", last.Err)
+	}
+	if _, kept := cer.Providers()[len(states)-1].TokenFor("read"); !kept {
+		t.Error("the round's deadline forgot a provider that was never asked")
+	}
+}
+
+// The order of a round is the cervice's, not the registrar's: the same two
+// providers listed the other way round on a later discovery are asked and
+// reported in the same order as before.
+func TestARoundKeepsItsOrderWhateverTheRegistrarSends(t *testing.T) {
+	point := func(host string) string {
+		return `{"providerName":"` + host + `","definition":"temperature","serviceURL":"http://` + host +
+			`/temperature","serviceNode":"zone","token":"t","version":"ServicePoint_v1"}`
+	}
+	lists := []string{point("b") + "," + point("a"), point("a") + "," + point("b")}
+	var discoveries atomic.Int32
+	answer := createWorkingHttpResp()
+	useTransport(t, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/temperature") {
+			resp := answer()
+			resp.Request = req
+			return resp, nil
+		}
+		list := lists[min(int(discoveries.Add(1)), len(lists))-1]
+		return &http.Response{
+			Status: "200 OK", StatusCode: 200,
+			Header:  http.Header{"Content-Type": []string{"application/json"}},
+			Body:    io.NopCloser(strings.NewReader(`{"version":"ServicePointList_v1","list":[` + list + `]}`)),
+			Request: req,
+		}, nil
+	}))
+	sys := createTestSystem(false)
+	cer := multiProviderCervice(nil, map[string][]string{"Forms": {"SignalA_v1a"}})
+
+	for round := range lists {
+		cer.Nodes = map[string][]components.NodeInfo{} // as if the registrar had been asked afresh
+		states, err := GetProviderStates(cer, &sys)
+		if err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+		var order []string
+		for _, s := range states {
+			order = append(order, s.URL)
+		}
+		if !equalStrings(order, []string{"http://a/temperature", "http://b/temperature"}) {
+			t.Errorf("round %d: %q", round, order)
+		}
+	}
+	if discoveries.Load() != int32(len(lists)) {
+		t.Errorf("discovered %d times, want once a round", discoveries.Load())
+	}
+}
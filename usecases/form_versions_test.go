@@ -0,0 +1,132 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// reading1 and reading2 are two versions of one form; the second added a unit,
+// which an older reading is taken to have been in.
+type reading1 struct {
+	Value   float64 `json:"value"`
+	Version string  `json:"version"`
+}
+
+func (r *reading1) NewForm() forms.Form { r.Version = "Reading_v1"; return r }
+func (r *reading1) FormVersion() string { return r.Version }
+
+type reading2 struct {
+	Value   float64 `json:"value"`
+	Unit    string  `json:"unit"`
+	Version string  `json:"version"`
+}
+
+func (r *reading2) NewForm() forms.Form { r.Version = "Reading_v2"; return r }
+func (r *reading2) FormVersion() string { return r.Version }
+
+// The two versions register as a production form does, once, beside the
+// migrations between them; the migration registry has no way to remove one.
+func init() {
+	forms.FormTypeMap["Reading_v1"] = reflect.TypeOf(reading1{})
+	forms.FormTypeMap["Reading_v2"] = reflect.TypeOf(reading2{})
+	forms.RegisterMigration("Reading_v1", "Reading_v2", func(f forms.Form) (forms.Form, error) {
+		up := &reading2{Value: f.(*reading1).Value, Unit: "degC"}
+		return up.NewForm(), nil
+	})
+	forms.RegisterMigration("Reading_v2", "Reading_v1", func(f forms.Form) (forms.Form, error) {
+		down := &reading1{Value: f.(*reading2).Value}
+		return down.NewForm(), nil
+	})
+}
+
+// Pack sends an older peer the version it asks for, and UnpackAs reads an older
+// payload as the version the reader works in.
+func TestPackAndUnpackMigrate(t *testing.T) {
+	current := (&reading2{Value: 21.5, Unit: "degC"}).NewForm()
+
+	data, err := Pack(current, "application/json; version=Reading_v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent map[string]any
+	if err := json.Unmarshal(data, &sent); err != nil || !reflect.DeepEqual(sent, map[string]any{"value": 21.5, "version": "Reading_v1"}) {
+		t.Errorf("packed %s", data)
+	}
+	if _, err := Pack(current, "application/json; version=Reading_v9"); err == nil {
+		t.Error("packed a version nothing migrates to")
+	}
+
+	f, err := UnpackAs(data, "application/json", "Reading_v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := f.(*reading2); !ok || *got != (reading2{Value: 21.5, Unit: "degC", Version: "Reading_v2"}) {
+		t.Errorf("unpacked %#v", f)
+	}
+	if f, _ := UnpackAs(data, "application/json", ""); f.FormVersion() != "Reading_v1" {
+		t.Errorf("with no version asked for, unpacked %s", f.FormVersion())
+	}
+}
+
+// A provider answers in the version asked for and says so, and answers 406 to
+// one it cannot make.
+func TestProviderNegotiatesTheVersion(t *testing.T) {
+	get := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/thermostat/controller/reading", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		HTTPProcessGetRequest(w, r, (&reading2{Value: 21.5, Unit: "degC"}).NewForm())
+		return w
+	}
+
+	w := get("application/json; version=Reading_v1")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json; version=Reading_v1" {
+		t.Fatalf("answered %d in %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `"version": "Reading_v1"`) {
+		t.Errorf("body %s", w.Body.String())
+	}
+	if w := get("application/json"); !strings.Contains(w.Body.String(), `"version": "Reading_v2"`) {
+		t.Errorf("with no version asked for, body %s", w.Body.String())
+	}
+	if w := get("application/json; version=Reading_v9"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("an unknown version answered %d", w.Code)
+	}
+}
+
+// A consumer asks for the version it works in, and reads it even from a
+// provider that ignores the question.
+func TestConsumerAsksForItsVersion(t *testing.T) {
+	var accepted string
+	useTransport(t, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		accepted = req.Header.Get("Accept")
+		data, _ := json.Marshal((&reading2{Value: 21.5, Unit: "degC"}).NewForm())
+		return &http.Response{
+			Status: "200 OK", StatusCode: 200,
+			Header:  http.Header{"Content-Type": []string{"application/json"}},
+			Body:    io.NopCloser(strings.NewReader(string(data))),
+			Request: req,
+		}, nil
+	}))
+
+	cer := &components.Cervice{Definition: "reading", FormVersion: "Reading_v1"}
+	f, err := askOneProvider(context.Background(), http.MethodGet, components.NodeInfo{URL: "http://sensor/reading"}, cer, "read", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted != "application/json; version=Reading_v1" {
+		t.Errorf("Accept = %q", accepted)
+	}
+	if got, ok := f.(*reading1); !ok || got.Value != 21.5 {
+		t.Errorf("read %#v", f)
+	}
+}
@@ -0,0 +1,194 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// A container orchestrator asks two different questions of a system, and
+// answering both with one status is how a cloud restarts itself into the ground.
+//
+// /health is liveness: is this process worth keeping? It is, for as long as it
+// answers and has not been told to stop. A registrar that is down, a CA that has
+// not enrolled it yet, a provider that went quiet — none of that is mended by a
+// restart, and a system restarted for it loses its certificate and starts
+// waiting all over again.
+//
+// /ready is readiness: should traffic be sent here now? Only once the system is
+// serving, holds what its configuration says it should hold, has registered
+// every service with the registrar, where one is configured, and is hearing
+// from every provider it follows.
+//
+// Both return the same report, so whoever is looking at a refusal can read why.
+
+// Health is what a system reports about itself to a probe.
+type Health struct {
+	Status string `json:"status"` // HealthLive, HealthReady or HealthNotReady
+
+	Serving  map[string]int    `json:"serving"`           // protocols bound, by port
+	Sockets  map[string]string `json:"sockets,omitempty"` // protocols bound, by Unix domain socket
+	Security SecurityPosture   `json:"security"`
+
+	// Registrations is the last attempt for each service, keyed asset/subpath.
+	Registrations map[string]components.Registration `json:"registrations"`
+	// Following is, for each cervice whose provider is being followed, whether
+	// it is still delivering. Keyed asset/cervice; a polled cervice is absent.
+	Following map[string]bool `json:"following,omitempty"`
+
+	// Problems says why the system is not ready, one line each.
+	Problems []string `json:"problems,omitempty"`
+}
+
+const (
+	HealthLive     = "live"      // answering, and not shutting down
+	HealthReady    = "ready"     // live, and nothing in Problems
+	HealthNotReady = "not ready" // live, with the reasons in Problems
+	HealthStopping = "stopping"  // shutting down, or the context is canceled
+)
+
+// CheckHealth gathers the system's report. Status is HealthReady or
+// HealthNotReady; the liveness endpoint reports HealthLive in its place.
+func CheckHealth(sys *components.System) Health {
+	h := Health{
+		Serving:       sys.Husk.Bound.Serving(),
+		Sockets:       sys.Husk.Bound.Sockets(),
+		Security:      Posture(sys),
+		Registrations: sys.Husk.Registered.Outcomes(),
+		Following:     make(map[string]bool),
+	}
+	if h.Serving == nil {
+		h.Serving = map[string]int{} // "serving": {} reads as nothing, null as unknown
+	}
+	if h.Registrations == nil {
+		h.Registrations = map[string]components.Registration{}
+	}
+
+	if !sys.Husk.Bound.Any() {
+		h.Problems = append(h.Problems, "nothing is bound yet")
+	}
+
+	// The two posture states in which the system is not what it was configured
+	// to be: waiting for a certificate, and naming an authorizer whose key it
+	// does not hold — in which state every request for a service is refused.
+	if h.Security.Level == PostureEnrolling {
+		h.Problems = append(h.Problems, "a certificate authority is configured and no certificate is held yet")
+	}
+	if h.Security.NamesAuthorizer && !h.Security.VerifiesTokens {
+		h.Problems = append(h.Problems, "an authorizer is configured and its key is not held yet")
+	}
+
+	// Registration is waited for only where there is a registrar to register
+	// with. A system configured without one never registers, and would
+	// otherwise never be ready either.
+	awaitRegistration := namesRegistrar(sys)
+	for _, ua := range sys.UAssets {
+		assetName := (*ua).GetName()
+		if awaitRegistration {
+			for _, serv := range (*ua).GetServices() {
+				service := assetName + "/" + serv.SubPath
+				outcome, attempted := h.Registrations[service]
+				switch {
+				case !attempted:
+					h.Problems = append(h.Problems, service+": not registered yet")
+				case !outcome.Registered:
+					h.Problems = append(h.Problems, service+": registration failed: "+outcome.Error)
+				}
+			}
+		}
+		for name, cer := range (*ua).GetCervices() {
+			if cer == nil || !cer.Following() {
+				continue
+			}
+			_, _, live := cer.Recall()
+			h.Following[assetName+"/"+name] = live
+			if !live {
+				h.Problems = append(h.Problems, assetName+"/"+name+": the followed provider has gone quiet")
+			}
+		}
+	}
+	sort.Strings(h.Problems) // map order otherwise, and a probe's output is diffed by people
+
+	h.Status = HealthReady
+	if len(h.Problems) > 0 {
+		h.Status = HealthNotReady
+	}
+	return h
+}
+
+// namesRegistrar reports whether a registrar is configured, whether or not it
+// is answering: one that is down is something to wait for. An entry with no URL
+// is a slot, as for GetRunningCoreSystemURL, and names nothing.
+func namesRegistrar(sys *components.System) bool {
+	for _, core := range sys.Husk.CoreS {
+		if core.Name == components.ServiceRegistrarName && strings.TrimSpace(core.Url) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Liveness answers /<system>/health: 200 while the system is running, 503 once
+// it is shutting down. The report comes with it, but does not decide the status.
+func Liveness(w http.ResponseWriter, r *http.Request, sys *components.System) {
+	h := CheckHealth(sys)
+	status := http.StatusOK
+	h.Status = HealthLive
+	if sys.Ctx != nil && sys.Ctx.Err() != nil {
+		status = http.StatusServiceUnavailable
+		h.Status = HealthStopping
+	}
+	writeHealth(w, status, h)
+}
+
+// Readiness answers /<system>/ready: 200 when nothing stands in the way of
+// serving, 503 with the reasons otherwise. It fails from the moment an ordered
+// shutdown begins, while the system is still draining: that is when a load
+// balancer must stop sending it anything new.
+func Readiness(w http.ResponseWriter, r *http.Request, sys *components.System) {
+	h := CheckHealth(sys)
+	status := http.StatusOK
+	if (sys.Ctx != nil && sys.Ctx.Err() != nil) || sys.Husk.Stopping.Begun() {
+		h.Status = HealthStopping
+	}
+	if h.Status != HealthReady {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, h)
+}
+
+// writeHealth sends a report with its status. Never cached: a probe that reads
+// yesterday's answer is not probing anything.
+func writeHealth(w http.ResponseWriter, status int, h Health) {
+	body, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		http.Error(w, "Error marshaling the health report", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		log.Println("Failed to write the health report: ", err)
+	}
+}
@@ -0,0 +1,114 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// healthSystem builds a system serving HTTP with one asset that provides one
+// service and follows one cervice, none of it registered yet.
+func healthSystem(ctx context.Context) (*components.System, *components.Cervice) {
+	sys := components.NewSystem("thermostat", ctx)
+	sys.Husk = &components.Husk{
+		ProtoPort: map[string]int{"http": 20150},
+		Host:      &components.HostingDevice{Name: "testhost"},
+		CoreS:     []*components.CoreSystem{{Name: components.ServiceRegistrarName, Url: "http://localhost:20102/serviceregistrar/registry"}},
+	}
+	sys.Husk.Bound.Bind("http", 20150)
+	temperature := &components.Cervice{Definition: "temperature"}
+	sys.UAssets["controller"] = &components.UnitAsset{
+		Name:        "controller",
+		ServicesMap: components.Services{"setpoint": {Definition: "setpoint", SubPath: "setpoint"}},
+		CervicesMap: components.Cervices{"temperature": temperature},
+	}
+	return &sys, temperature
+}
+
+func probe(t *testing.T, sys *components.System, path string) (int, Health) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handleThreeParts(w, httptest.NewRequest(http.MethodGet, "/thermostat/"+path, nil), path, sys)
+	var h Health
+	if err := json.Unmarshal(w.Body.Bytes(), &h); err != nil {
+		t.Fatalf("%s did not return a JSON report: %v\n%s", path, err, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s Content-Type = %q", path, ct)
+	}
+	return w.Code, h
+}
+
+// A system is live from the moment it answers, and ready only once every
+// service is registered and every followed provider is delivering.
+func TestReadinessWaitsForRegistrationAndFollowedProviders(t *testing.T) {
+	sys, temperature := healthSystem(context.Background())
+	temperature.StartFollowing()
+
+	if code, h := probe(t, sys, "health"); code != http.StatusOK || h.Status != HealthLive {
+		t.Errorf("health = %d %q, want 200 %q", code, h.Status, HealthLive)
+	}
+	code, h := probe(t, sys, "ready")
+	if code != http.StatusServiceUnavailable || h.Status != HealthNotReady {
+		t.Fatalf("ready before registering = %d %q, want 503", code, h.Status)
+	}
+	if len(h.Problems) != 2 {
+		t.Errorf("problems = %q, want the unregistered service and the quiet provider", h.Problems)
+	}
+
+	sys.Husk.Registered.Record("controller/setpoint", errors.New("bad registration response: 500"))
+	_, h = probe(t, sys, "ready")
+	if h.Registrations["controller/setpoint"].Registered || !strings.Contains(strings.Join(h.Problems, "\n"), "500") {
+		t.Errorf("a failed registration is not reported: %+v", h)
+	}
+
+	sys.Husk.Registered.Record("controller/setpoint", nil)
+	temperature.Remember([]byte(`{"value":21}`), "application/json", 0)
+	code, h = probe(t, sys, "ready")
+	if code != http.StatusOK || h.Status != HealthReady {
+		t.Errorf("ready = %d %q %q, want 200", code, h.Status, h.Problems)
+	}
+	if !h.Following["controller/temperature"] || h.Serving["http"] != 20150 {
+		t.Errorf("report = %+v", h)
+	}
+}
+
+// A system configured without a registrar has nothing to register with, and
+// is ready without registering; a registrar entry left blank is no registrar.
+func TestASystemWithoutARegistrarIsReadyUnregistered(t *testing.T) {
+	sys, _ := healthSystem(context.Background())
+	sys.Husk.CoreS = []*components.CoreSystem{{Name: components.ServiceRegistrarName}}
+	if code, h := probe(t, sys, "ready"); code != http.StatusOK || h.Status != HealthReady {
+		t.Errorf("ready = %d %q %q, want 200", code, h.Status, h.Problems)
+	}
+	sys.Husk.CoreS = nil
+	if code, h := probe(t, sys, "ready"); code != http.StatusOK {
+		t.Errorf("ready with no core systems = %d %q", code, h.Problems)
+	}
+}
+
+// Restarting a system for waiting on its registrar or its CA mends nothing, so
+// liveness does not depend on them; only shutting down fails it.
+func TestLivenessFailsOnlyWhenStopping(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sys, _ := healthSystem(ctx)
+	sys.Husk.CoreS = []*components.CoreSystem{{Name: "ca", Url: "http://localhost:20100/ca"}}
+
+	if code, h := probe(t, sys, "health"); code != http.StatusOK {
+		t.Errorf("health while enrolling = %d, want 200 (%q)", code, h.Problems)
+	}
+	if _, h := probe(t, sys, "ready"); h.Security.Level != PostureEnrolling {
+		t.Errorf("security level = %q, want %q", h.Security.Level, PostureEnrolling)
+	}
+
+	cancel()
+	if code, h := probe(t, sys, "health"); code != http.StatusServiceUnavailable || h.Status != HealthStopping {
+		t.Errorf("health after cancel = %d %q, want 503 %q", code, h.Status, HealthStopping)
+	}
+}
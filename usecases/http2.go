@@ -0,0 +1,274 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// HTTP/2 between systems.
+//
+// The same two systems talk to each other all day: a consumer polling a
+// provider, a subscriber holding a stream open, the registrar renewing every
+// service. Over HTTP/1.1 each of those in flight at once is its own connection,
+// and a subscriber's stream holds one for as long as it follows. Over HTTP/2
+// they share one connection, multiplexed.
+//
+// Turned on by "http2": true in the configuration, which sets Husk.HTTP2. Off,
+// a system speaks HTTP/1.1 only, on both ports, as it always has. On, the HTTPS
+// server and the client offer h2 in the TLS handshake, and the plaintext server
+// also accepts HTTP/2 with prior knowledge (h2c) beside HTTP/1.1.
+//
+// Plaintext is the delicate half. TLS negotiates the protocol, so a peer that
+// does not speak HTTP/2 simply says so; over plaintext the client has to guess,
+// and a provider that only speaks HTTP/1.1 answers the HTTP/2 preface with an
+// HTTP/1.1 error, or hangs up. So the client tries h2c once per host and
+// remembers the answer: a host that refuses the preface is spoken to over
+// HTTP/1.1, and asked again only after h2cRecheck.
+//
+// Only a refusal is an answer. A host that cannot be reached, or one that
+// accepted the preface and then failed, has said nothing about its protocol,
+// and is neither marked down to HTTP/1.1 for ten minutes nor sent the request a
+// second time over it: a POST sent twice is an invocation made twice.
+
+// http2Wanted is whether this process's client speaks HTTP/2. The client is
+// shared by every system in the process, so it does from the moment any of them
+// is configured to.
+var http2Wanted atomic.Bool
+
+// h2cRecheck is how long a host that would not speak h2c is left on HTTP/1.1
+// before it is tried again — long enough that a provider that never will is
+// asked rarely, short enough that one upgraded in place is noticed.
+const h2cRecheck = 10 * time.Minute
+
+// installHTTP2 turns HTTP/2 on in the framework's client if the system asks
+// for it. It never turns it off: another system in the process may want it.
+func installHTTP2(sys *components.System) {
+	if sys.Husk.HTTP2 {
+		http2Wanted.Store(true)
+	}
+}
+
+// serverProtocols is what a server of this system speaks. secure says whether
+// it is the HTTPS server, whose HTTP/2 is negotiated, or the plaintext one,
+// whose HTTP/2 is h2c.
+func serverProtocols(sys *components.System, secure bool) *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	if sys.Husk.HTTP2 {
+		if secure {
+			p.SetHTTP2(true)
+		} else {
+			p.SetUnencryptedHTTP2(true)
+		}
+	}
+	return p
+}
+
+// offerHTTP2 adds h2 to what a TLS client proposes, when HTTP/2 is wanted. The
+// transport uses HTTP/2 on any connection whose handshake settled on it, and
+// HTTP/1.1 on any other. cfg may be nil, as it is before enrollment.
+func offerHTTP2(cfg *tls.Config) *tls.Config {
+	if !http2Wanted.Load() {
+		return cfg
+	}
+	if cfg == nil {
+		cfg = &tls.Config{}
+	} else {
+		cfg = cfg.Clone()
+	}
+	cfg.NextProtos = []string{"h2", "http/1.1"}
+	return cfg
+}
+
+// h2cRoundTripper carries the framework client's plaintext requests over h2c,
+// to the hosts that speak it. Registered on the client's transport for "http",
+// and hands a request back to that transport, as HTTP/1.1, by answering
+// http.ErrSkipAltProtocol.
+type h2cRoundTripper struct {
+	h2c   *http.Transport
+	proxy func(*http.Request) (*url.URL, error)
+
+	mu    sync.Mutex
+	hosts map[string]h2cVerdict
+}
+
+// h2cVerdict is what a host made of the last h2c attempt.
+type h2cVerdict struct {
+	speaks  bool
+	refusal h2cRefusal // how it refused, where it did not speak
+	at      time.Time
+}
+
+// h2cRefusal is how a host that does not speak h2c said so.
+type h2cRefusal int
+
+const (
+	notRefused    h2cRefusal = iota
+	answeredHTTP1            // it read the preface as an HTTP/1.x request line, and refused that
+	hungUp                   // it closed the connection without a word
+)
+
+// newH2CRoundTripper makes the h2c half of transport. It must be called before
+// the round tripper is registered on it: the clone would otherwise carry the
+// registration too, and hand every request back to itself.
+func newH2CRoundTripper(transport *http.Transport) *h2cRoundTripper {
+	rt := &h2cRoundTripper{proxy: transport.Proxy, hosts: make(map[string]h2cVerdict)}
+	h2c := transport.Clone()
+	h2c.Proxy = nil // h2c with prior knowledge is for hosts reached directly
+	h2c.Protocols = new(http.Protocols)
+	h2c.Protocols.SetUnencryptedHTTP2(true)
+	dial := h2c.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	h2c.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &h2cConn{Conn: conn, rt: rt, host: addr}, nil
+	}
+	rt.h2c = h2c
+	return rt
+}
+
+func (rt *h2cRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !http2Wanted.Load() || !rt.worthTrying(req) {
+		return nil, http.ErrSkipAltProtocol
+	}
+
+	// The attempt gets its own copy of the body, so that the one the transport
+	// sends after a refused attempt has not been read.
+	attempt := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, http.ErrSkipAltProtocol
+		}
+		attempt.Body = body
+	}
+
+	start := time.Now()
+	resp, err := rt.h2c.RoundTrip(attempt)
+	if err == nil {
+		rt.remember(dialedAddr(req.URL), h2cVerdict{speaks: true})
+		return resp, nil
+	}
+	// An HTTP/1.x answer to the preface is a refusal before any request was
+	// read, so the request goes again over HTTP/1.1 whatever it is. A host
+	// that hung up may have done so for another reason after reading it, so
+	// only a request that may be made twice goes again; any other fails, and
+	// the next goes over HTTP/1.1.
+	switch rt.refusedSince(dialedAddr(req.URL), start) {
+	case answeredHTTP1:
+		return nil, http.ErrSkipAltProtocol
+	case hungUp:
+		if repeatable(components.RetryPolicy{}, req.Method) {
+			return nil, http.ErrSkipAltProtocol
+		}
+	}
+	return nil, err
+}
+
+// worthTrying says whether req may go over h2c: not through a proxy, not with a
+// body that cannot be sent twice, and not to a host that recently refused.
+func (rt *h2cRoundTripper) worthTrying(req *http.Request) bool {
+	if rt.proxy != nil {
+		if proxy, err := rt.proxy(req); err != nil || proxy != nil {
+			return false
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	verdict, asked := rt.hosts[dialedAddr(req.URL)]
+	return !asked || verdict.speaks || time.Since(verdict.at) > h2cRecheck
+}
+
+// dialedAddr is the address a request to u is dialed at, which is what the
+// hosts are known by: a URL may leave out the port its connection has.
+func dialedAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func (rt *h2cRoundTripper) remember(host string, verdict h2cVerdict) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	verdict.at = time.Now()
+	rt.hosts[host] = verdict
+}
+
+// refusedSince is how host refused h2c, if it has since start.
+func (rt *h2cRoundTripper) refusedSince(host string, start time.Time) h2cRefusal {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	verdict := rt.hosts[host]
+	if verdict.speaks || verdict.at.Before(start) {
+		return notRefused
+	}
+	return verdict.refusal
+}
+
+// h2cConn is a connection an h2c attempt was made on, watched for the host's
+// first answer. A host that speaks HTTP/2 opens with a SETTINGS frame, whose
+// first byte is zero; one that speaks HTTP/1.x only answers "HTTP/1.1 400", or
+// hangs up before saying anything. Only those two are taken as a refusal.
+type h2cConn struct {
+	net.Conn
+	rt       *h2cRoundTripper
+	host     string
+	answered atomic.Bool
+}
+
+func (c *h2cConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n == 0 && err == nil || c.answered.Swap(true) {
+		return n, err
+	}
+	switch {
+	case n > 0 && bytes.HasPrefix([]byte("HTTP/1."), p[:min(n, len("HTTP/1."))]):
+		c.rt.remember(c.host, h2cVerdict{refusal: answeredHTTP1})
+	case n == 0 && !isTimeout(err):
+		c.rt.remember(c.host, h2cVerdict{refusal: hungUp})
+	}
+	return n, err
+}
+
+// isTimeout reports whether err is a deadline passing, which is the client
+// giving up rather than the host refusing.
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
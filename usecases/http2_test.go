@@ -0,0 +1,246 @@
+package usecases
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// wantHTTP2 turns HTTP/2 on in the framework's client for one test.
+func wantHTTP2(t *testing.T) {
+	was := http2Wanted.Load()
+	http2Wanted.Store(true)
+	t.Cleanup(func() { http2Wanted.Store(was) })
+}
+
+// protoServer answers with the protocol it was asked in, and the body it was sent.
+func protoServer(t *testing.T, h2c bool) *httptest.Server {
+	sys := components.NewSystem("provider", context.Background())
+	sys.Husk = &components.Husk{HTTP2: h2c}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, r.Proto+" "+string(body))
+	}))
+	server.Config.Protocols = serverProtocols(&sys, false)
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func ask(t *testing.T, url, body string) string {
+	t.Helper()
+	resp, err := sendHTTPReq(http.MethodPost, url, []byte(body))
+	if err != nil {
+		t.Fatalf("request to %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(resp.Body)
+	return string(answer)
+}
+
+// Two systems that both speak HTTP/2 do so over plaintext too.
+func TestPlaintextHTTP2BetweenSystemsThatSpeakIt(t *testing.T) {
+	wantHTTP2(t)
+	server := protoServer(t, true)
+	for i := 0; i < 2; i++ {
+		if got := ask(t, server.URL, "21.5"); got != "HTTP/2.0 21.5" {
+			t.Fatalf("request %d was answered %q, want HTTP/2.0", i, got)
+		}
+	}
+}
+
+// A provider that speaks only HTTP/1.1 is still reached, body and all, and is
+// not asked in HTTP/2 again on the next call.
+func TestAnHTTP1ProviderIsReachedOverHTTP1(t *testing.T) {
+	wantHTTP2(t)
+	server := protoServer(t, false)
+	for i := 0; i < 2; i++ {
+		if got := ask(t, server.URL, "21.5"); got != "HTTP/1.1 21.5" {
+			t.Fatalf("request %d was answered %q, want HTTP/1.1 with the body", i, got)
+		}
+	}
+}
+
+// A provider that is down when first asked has said nothing about its
+// protocol, and is spoken HTTP/2 to once it is up.
+func TestAProviderDownAtFirstIsNotMarkedHTTP1(t *testing.T) {
+	wantHTTP2(t)
+	addr := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	if _, err := sendHTTPReq(http.MethodGet, "http://"+addr, nil); err == nil {
+		t.Fatal("a provider that is not running answered")
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys := components.NewSystem("provider", context.Background())
+	sys.Husk = &components.Husk{HTTP2: true}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Config.Protocols = serverProtocols(&sys, false)
+	server.Start()
+	defer server.Close()
+	if got := ask(t, server.URL, ""); got != "HTTP/2.0" {
+		t.Errorf("once up, it was asked in %s", got)
+	}
+}
+
+// A host that hangs up on the attempt may have read the request first, so an
+// invocation is not sent again over HTTP/1.1: it fails, and only once.
+func TestAPostIsNotSentTwiceToAHostThatHangsUp(t *testing.T) {
+	wantHTTP2(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var connections atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connections.Add(1)
+			conn.Read(make([]byte, 1024))
+			conn.Close()
+		}
+	}()
+
+	if _, err := sendHTTPReq(http.MethodPost, "http://"+listener.Addr().String(), []byte("open")); err == nil {
+		t.Fatal("a host that hung up answered")
+	}
+	if n := connections.Load(); n != 1 {
+		t.Errorf("the invocation was sent on %d connections", n)
+	}
+}
+
+// Over TLS, two systems that speak HTTP/2 negotiate it in the handshake. The
+// server is built and served as startHTTPSServer does, since it is ServeTLS that
+// offers h2 from the server's protocols; httptest offers only what it is told.
+func TestHTTP2OverTLSBetweenSystemsThatSpeakIt(t *testing.T) {
+	wantHTTP2(t)
+	ca := newSigningCA(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	sys := components.NewSystem("provider", context.Background())
+	sys.Husk = &components.Husk{HTTP2: true}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			io.WriteString(w, r.Proto+" "+string(body))
+		}),
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{ca.sign(t, "provider")},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+			MinVersion:   tls.VersionTLS12,
+		},
+		Protocols: serverProtocols(&sys, true),
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+
+	previous := clientTLS.Load()
+	clientTLS.Store(&tls.Config{
+		Certificates: []tls.Certificate{ca.sign(t, "consumer")},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	})
+	defer clientTLS.Store(previous)
+
+	for i := 0; i < 2; i++ {
+		if got := ask(t, "https://"+listener.Addr().String(), "21.5"); got != "HTTP/2.0 21.5" {
+			t.Fatalf("request %d was answered %q, want HTTP/2.0", i, got)
+		}
+	}
+}
+
+// Without the switch, nothing changes: HTTP/1.1 on both sides.
+func TestHTTP2IsOffUnlessConfigured(t *testing.T) {
+	server := protoServer(t, true)
+	if http2Wanted.Load() {
+		t.Skip("another system in this process turned HTTP/2 on")
+	}
+	if got := ask(t, server.URL, ""); !strings.HasPrefix(got, "HTTP/1.1") {
+		t.Errorf("answered %q without HTTP/2 configured", got)
+	}
+	if cfg := offerHTTP2(nil); cfg != nil {
+		t.Errorf("the TLS dial offers %v without HTTP/2 configured", cfg.NextProtos)
+	}
+}
+
+// The TLS dial offers h2 without touching the configuration it was given,
+// which is shared with every other dial.
+func TestTheTLSDialOffersH2(t *testing.T) {
+	wantHTTP2(t)
+	shared := &tls.Config{ServerName: "ds18b20"}
+	cfg := offerHTTP2(shared)
+	if len(cfg.NextProtos) == 0 || cfg.NextProtos[0] != "h2" || cfg.ServerName != "ds18b20" {
+		t.Errorf("offered %+v", cfg.NextProtos)
+	}
+	if shared.NextProtos != nil {
+		t.Error("the shared TLS configuration was modified")
+	}
+}
+
+// A subscriber's stream over HTTP/2 still has its write deadline lifted, and
+// still flushes each event as it is sent.
+func TestAStreamOverHTTP2(t *testing.T) {
+	wantHTTP2(t)
+	sys := components.NewSystem("provider", context.Background())
+	sys.Husk = &components.Husk{HTTP2: true}
+	publisher := NewPublisher(temperature(0.5))
+	publisher.Sample(sample(21.5))
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := UnlimitStreamWrite(w); err != nil {
+			t.Errorf("over %s: %v", r.Proto, err)
+		}
+		publisher.ServeStream(w, r)
+	}))
+	server.Config.Protocols = serverProtocols(&sys, false)
+	server.Start()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := (&http.Client{Transport: http.DefaultClient.Transport}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("the stream was served over %s", resp.Proto)
+	}
+	buf := make([]byte, 0, 512)
+	for !strings.Contains(string(buf), "21.5") {
+		chunk := make([]byte, 256)
+		n, err := resp.Body.Read(chunk)
+		if err != nil {
+			t.Fatalf("read %q, then %v", buf, err)
+		}
+		buf = append(buf, chunk[:n]...)
+	}
+	if !strings.Contains(string(buf), "event: terms") {
+		t.Errorf("the stream opened with %q", buf)
+	}
+}
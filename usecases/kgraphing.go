@@ -31,14 +31,18 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
 )
 
 // function KGraphing provides a semantic model of a system running on a host and exposing the functionality of asset
 func KGraphing(w http.ResponseWriter, req *http.Request, sys *components.System) {
+	contentType, _, ok := negotiated(w, req, "text/turtle")
+	if !ok {
+		return
+	}
 
 	rdf := prefixes()
 	rdf += modelSystem(sys)
@@ -48,7 +52,7 @@ func KGraphing(w http.ResponseWriter, req *http.Request, sys *components.System)
 	rdf += modelSecurity(sys)
 	rdf += modelUAsset(sys)
 
-	w.Header().Set("Content-Type", "text/turtle")
+	w.Header().Set("Content-Type", contentType)
 	_, err := w.Write([]byte(rdf))
 	if err != nil {
 		log.Println("Failed to write KGraphing information: ", err)
@@ -271,6 +275,7 @@ func modelSecurity(sys *components.System) string {
 		{"verifiesTokens", p.VerifiesTokens},
 		{"offersTLS", p.OffersTLS},
 		{"acceptsPlaintext", p.AcceptsPlaintext},
+		{"limitsPeerRate", p.RateLimited},
 	} {
 		m += fmt.Sprintf("    %s \"%t\"^^xsd:boolean ;\n", predicate(f.predicate), f.value)
 	}
@@ -421,6 +426,45 @@ func modelUAsset(sys *components.System) string {
 	return assetModels
 }
 
+// valueShape is the kind of value a form carries, as the graph and the SysML
+// model write it.
+type valueShape struct {
+	class      string   // the class in the graph, under alc:
+	attributes string   // the SysML attribute def
+	fields     []string // its attributes
+}
+
+// valueShapes are the forms that carry a reading or an instruction, by their Go
+// type name, which is what a service's "Forms" detail lists. A service that
+// names one is described by the shape of what it sends — a consumer looking for
+// a position finds the services sending vectors, not every service that happens
+// to be called "position".
+var valueShapes = map[string]valueShape{
+	"SignalA_v1a":   {"ScalarValue", "ScalarReading", []string{"value : Real", "unit : String"}},
+	"SignalA_v2a":   {"ScalarValue", "QualifiedReading", []string{"value : Real", "unit : String", "quality : String", "source : String[0..1]", "uncertainty : Real[0..1]"}},
+	"SignalB_v1a":   {"BinaryValue", "BinaryReading", []string{"value : Boolean"}},
+	"Vector_v1":     {"VectorValue", "VectorReading", []string{"values : Real[1..*]", "labels : String[0..*]", "unit : String"}},
+	"EnumState_v1":  {"EnumeratedState", "EnumeratedState", []string{"value : String", "allowed : String[1..*]"}},
+	"TextState_v1":  {"TextState", "TextState", []string{"value : String"}},
+	"TimeSeries_v1": {"TimeSeries", "TimeSeriesReading", []string{"values : Real[0..*]", "timestamps : String[0..*]", "unit : String"}},
+	"Command_v1":    {"Command", "Command", []string{"command : String", "arguments : String[0..*]"}},
+	"CommandAck_v1": {"CommandAcknowledgement", "CommandAcknowledgement", []string{"command : String", "status : String"}},
+}
+
+// shapeOf is the shape of the first form in details["Forms"] that has one. A
+// form may be named by its version as well as by its type.
+func shapeOf(details map[string][]string) (valueShape, bool) {
+	for _, name := range details["Forms"] {
+		if t, ok := forms.FormTypeMap[name]; ok {
+			name = t.Name()
+		}
+		if shape, ok := valueShapes[name]; ok {
+			return shape, true
+		}
+	}
+	return valueShape{}, false
+}
+
 // modelCervices creates a knowledge graph of the consumed services of a unit asset
 func modelCervices(sName string, ua *components.UnitAsset) string {
 	var cervicesModel string
@@ -449,6 +493,10 @@ func modelCervices(sName string, ua *components.UnitAsset) string {
 			}
 		}
 
+		if shape, ok := shapeOf(details); ok {
+			cerviceModel += fmt.Sprintf("    "+predicate("hasValueShape")+" alc:%s ;\n", shape.class)
+		}
+
 		for pName, nodes := range cervice.Nodes {
 			cerviceModel += fmt.Sprintf("    afo:consumes alc:%s ;\n", pName)
 			for _, ni := range nodes {
@@ -489,8 +537,8 @@ func modelServices(sName string, ua *components.UnitAsset, sys *components.Syste
 			eName := endpointLocalName(sys, protocol, port)
 			serviceModel += fmt.Sprintf("    afo:hostedOnEndpoint alc:%s ;\n", eName)
 
-			addr := protocol + "://" + sys.Husk.Host.IPAddresses[0] + ":" +
-				strconv.Itoa(port) + "/" + sys.Name + "/" + assetName + "/" + service.SubPath
+			addr := protocol + "://" + components.HostPort(servingAddress(sys), port) +
+				"/" + sys.Name + "/" + assetName + "/" + service.SubPath
 			serviceModel += fmt.Sprintf("    afo:hasUrl <%s> ;\n", addr)
 		}
 
@@ -507,6 +555,10 @@ func modelServices(sName string, ua *components.UnitAsset, sys *components.Syste
 			}
 		}
 
+		if shape, ok := shapeOf(details); ok {
+			serviceModel += fmt.Sprintf("    "+predicate("hasValueShape")+" alc:%s ;\n", shape.class)
+		}
+
 		serviceModel += fmt.Sprintf("    afo:isSubscribable \"%t\"^^xsd:boolean ;\n", service.SubscribeAble)
 		if service.CFootprint != 0 {
 			serviceModel += fmt.Sprintf("    "+predicate("hasCarbonFootprint")+" \"%.6f\"^^xsd:decimal ;\n", service.CFootprint)
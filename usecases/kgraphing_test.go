@@ -527,3 +527,36 @@ func TestEveryAFOTermEmittedIsOntologyVocabulary(t *testing.T) {
 		}
 	}
 }
+
+func TestModelServicesBracketsAnIPv6Host(t *testing.T) {
+	sys := newKGTestSystem()
+	sys.Husk.Host.IPAddresses = []string{"192.0.2.1", "2001:db8::1"}
+	sys.Husk.Host.IPPreference = components.PreferIPv6
+	ua := addTestAsset(sys)
+
+	out := modelServices("testhost_mysys", ua, sys)
+	if !strings.Contains(out, "<http://[2001:db8::1]:8080/mysys/sensor1/temp>") {
+		t.Errorf("modelServices: want the IPv6 URL bracketed, got\n%s", out)
+	}
+}
+
+// A service that names its form is described by the shape of what it sends,
+// whether the form is named by its type or by its version.
+func TestModelServicesGivesTheValueShape(t *testing.T) {
+	sys := newKGTestSystem()
+	ua := addTestAsset(sys)
+	ua.ServicesMap["temp"].Details["Forms"] = []string{"Vector_v1"}
+	ua.CervicesMap["humidity"].Details["Forms"] = []string{"SignalA_v1.0"}
+
+	if out := modelServices("testhost_mysys", ua, sys); !strings.Contains(out, "alc:hasValueShape alc:VectorValue ;") {
+		t.Errorf("the service's shape is missing:\n%s", out)
+	}
+	if out := modelCervices("testhost_mysys", ua); !strings.Contains(out, "alc:hasValueShape alc:ScalarValue") {
+		t.Errorf("the cervice's shape is missing:\n%s", out)
+	}
+
+	ua.ServicesMap["temp"].Details["Forms"] = []string{"application/json"}
+	if out := modelServices("testhost_mysys", ua, sys); strings.Contains(out, "hasValueShape") {
+		t.Errorf("a shape was given to a service that names no form:\n%s", out)
+	}
+}
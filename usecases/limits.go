@@ -0,0 +1,213 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// A system runs on a gateway beside a control loop, and every handler that
+// reads a body used to read all of it: one caller sending a gigabyte, or a
+// thousand requests a second, took the memory and the CPU the control loop
+// needed. Authorization does not help — an identified, authorized peer with a
+// bug in its polling loop is the usual culprit, not an attacker.
+//
+// So every request is held to two limits before anything else looks at it: a
+// body no larger than its service accepts, and a rate no higher than the
+// system's allowance for one peer.
+
+// DefaultMaxBody is the largest request body a service accepts unless it or
+// its husk says otherwise: a thousand times the largest form, and far short of
+// what would trouble a gateway.
+const DefaultMaxBody int64 = 1 << 20
+
+// metricLimited counts the requests refused by either limit.
+const metricLimited = "mbaigo_limited_requests_total"
+
+// limiting is the stage of the request chain that enforces both limits. It
+// stands in front of the system's own middleware and of authorization, so that
+// a peer over its allowance costs the system as little as possible, and behind
+// metering and tracing, so that what it refuses is still counted and seen.
+//
+// The probes and the metrics are not rate limited: an orchestrator and a
+// scraper call on their own schedule, and a probe refused for calling too often
+// reads as a system that is down.
+func limiting(sys *components.System) components.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !exemptFromRate(r) {
+				if ok, wait := sys.Husk.Limiter.Take(peerKey(r), sys.Husk.RateLimit, time.Now()); !ok {
+					countLimited(sys, "rate")
+					// Whole seconds, rounded up: a client that retries on the
+					// dot of a rounded-down value is refused again.
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+					http.Error(w, "too many requests from this peer", http.StatusTooManyRequests)
+					return
+				}
+			}
+
+			limit := bodyLimit(sys, r)
+			if r.ContentLength > limit {
+				// Refused before a byte is read. No Retry-After: the same body
+				// will be as large next time.
+				countLimited(sys, "body")
+				http.Error(w, "request body too large: at most "+strconv.FormatInt(limit, 10)+" bytes",
+					http.StatusRequestEntityTooLarge)
+				return
+			}
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit)}
+			r.Body = body
+			lw := &limitedWriter{ResponseWriter: w, body: body, sys: sys}
+			next.ServeHTTP(lw, r)
+			if !lw.written && body.exceeded.Load() {
+				// The handler gave up on the body without answering, which
+				// would otherwise go out as an empty 200.
+				lw.WriteHeader(http.StatusRequestEntityTooLarge)
+			}
+		})
+	}
+}
+
+// exemptFromRate says whether r is for a probe or the metrics.
+func exemptFromRate(r *http.Request) bool {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 3 {
+		return false
+	}
+	switch parts[2] {
+	case "health", "ready", "metrics":
+		return true
+	}
+	return false
+}
+
+// peerKey names the caller a rate is kept for: the name in its certificate when
+// it presented one, which is the same however many addresses it calls from, and
+// its address otherwise. Callers over a Unix domain socket share one allowance,
+// being on this host.
+func peerKey(r *http.Request) string {
+	if cn, ok := PeerCN(r); ok {
+		return "cn:" + cn
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || host == "" {
+		return "local"
+	}
+	return "addr:" + host
+}
+
+// bodyLimit is the largest body r may carry: its service's, else the husk's,
+// else DefaultMaxBody. The path is read as dispatch reads it; a request naming
+// no service of this system gets the husk's.
+func bodyLimit(sys *components.System, r *http.Request) int64 {
+	limit := DefaultMaxBody
+	if sys.Husk.MaxBody > 0 {
+		limit = sys.Husk.MaxBody
+	}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 4 {
+		return limit
+	}
+	ua, known := sys.UAssets[parts[2]]
+	if !known {
+		return limit
+	}
+	services := (*ua).GetServices()
+	serv := findServiceByPath(services, parts[3])
+	if serv == nil {
+		serv = findServiceByDefinition(services, parts[3]) // the cost and footprint paths
+	}
+	if serv != nil && serv.MaxBody > 0 {
+		limit = serv.MaxBody
+	}
+	return limit
+}
+
+// BodyTooLarge reports whether err came from reading a body past its limit. A
+// handler that reads the body itself needs nothing more: whatever error status
+// it answers such an error with is sent as 413, and so is no answer at all.
+func BodyTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// limitedBody notes whether the body was read past its limit.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded atomic.Bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && BodyTooLarge(err) {
+		b.exceeded.Store(true)
+	}
+	return n, err
+}
+
+// limitedWriter answers 413 for a handler whose body was too large, in place of
+// whatever error status the handler chose. Handlers report a failed read as the
+// 400 or 500 they would give any other; the caller should still learn that it
+// was the size. A handler that answers nothing — HTTPProcessSetRequest leaves
+// that to its caller — is answered 413 by limiting once it returns, and one
+// that answers a success in spite of the failed read is left to it.
+type limitedWriter struct {
+	http.ResponseWriter
+	body    *limitedBody
+	sys     *components.System
+	written bool
+}
+
+func (l *limitedWriter) WriteHeader(status int) {
+	if !l.written && l.body.exceeded.Load() && status >= http.StatusBadRequest {
+		status = http.StatusRequestEntityTooLarge
+		countLimited(l.sys, "body")
+	}
+	l.written = true
+	l.ResponseWriter.WriteHeader(status)
+}
+
+func (l *limitedWriter) Write(b []byte) (int, error) {
+	if !l.written {
+		l.WriteHeader(http.StatusOK)
+	}
+	return l.ResponseWriter.Write(b)
+}
+
+func (l *limitedWriter) Flush() {
+	if f, ok := l.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (l *limitedWriter) Unwrap() http.ResponseWriter { return l.ResponseWriter }
+
+// countLimited records a request refused by one of the limits.
+func countLimited(sys *components.System, limit string) {
+	metricsOf(sys).Add(metricLimited, "Requests refused for their size or their rate, by limit.",
+		components.Labels{"limit": limit}, 1)
+}
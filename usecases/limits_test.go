@@ -0,0 +1,103 @@
+package usecases
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// limitedSystem serves one asset whose setpoint is written with
+// HTTPProcessSetRequest and answered 400 when that fails, as systems do; its
+// quiet service answers nothing at all.
+func limitedSystem() *components.System {
+	sys := components.NewSystem("thermostat", context.Background())
+	sys.Husk = &components.Husk{
+		Host:      &components.HostingDevice{Name: "testhost"},
+		MaxBody:   64,
+		RateLimit: components.RateLimit{PerSecond: 1, Burst: 2},
+	}
+	sys.UAssets["controller"] = &components.UnitAsset{
+		Name: "controller",
+		ServicesMap: components.Services{
+			"setpoint": {Definition: "setpoint", SubPath: "setpoint"},
+			"model":    {Definition: "model", SubPath: "model", MaxBody: 1024},
+			"quiet":    {Definition: "quiet", SubPath: "quiet"},
+		},
+		ServingFunc: func(w http.ResponseWriter, r *http.Request, servicePath string) {
+			if _, err := io.ReadAll(r.Body); err != nil && servicePath != "quiet" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+			}
+		},
+	}
+	return &sys
+}
+
+func put(sys *components.System, path, body string, chunked bool) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+	if chunked {
+		r.ContentLength = -1
+	}
+	r.RemoteAddr = "192.0.2.7:40000"
+	w := httptest.NewRecorder()
+	ResourceHandler(sys, w, r)
+	return w
+}
+
+// A body past the limit is refused with 413, whether it said its length or not,
+// and a service that declares a larger limit is held to its own.
+func TestBodiesAreLimited(t *testing.T) {
+	sys := limitedSystem()
+	sys.Husk.RateLimit = components.RateLimit{}
+	large := strings.Repeat("x", 100)
+
+	if w := put(sys, "/thermostat/controller/setpoint", large, false); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("a declared body of 100 bytes against 64 = %d, want 413", w.Code)
+	}
+	if w := put(sys, "/thermostat/controller/setpoint", large, true); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("a chunked body of 100 bytes against 64 = %d, want 413 in place of the handler's 400", w.Code)
+	}
+	if w := put(sys, "/thermostat/controller/quiet", large, true); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("a chunked body of 100 bytes to a handler that answers nothing = %d, want 413", w.Code)
+	}
+	if w := put(sys, "/thermostat/controller/model", large, false); w.Code != http.StatusOK {
+		t.Errorf("100 bytes to a service accepting 1024 = %d, want 200", w.Code)
+	}
+	if w := put(sys, "/thermostat/controller/setpoint", "21.5", false); w.Code != http.StatusOK {
+		t.Errorf("a small body = %d, want 200", w.Code)
+	}
+}
+
+// A peer past its allowance is refused with 429 and told when to come back;
+// the probes are answered regardless.
+func TestPeersAreRateLimited(t *testing.T) {
+	sys := limitedSystem()
+	for i := 0; i < 2; i++ {
+		if w := put(sys, "/thermostat/controller/setpoint", "", false); w.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst = %d", i+1, w.Code)
+		}
+	}
+	w := put(sys, "/thermostat/controller/setpoint", "", false)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("past the burst = %d, Retry-After %q; want 429 and 1", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/thermostat/health", nil)
+	r.RemoteAddr = "192.0.2.7:40000"
+	probe := httptest.NewRecorder()
+	ResourceHandler(sys, probe, r)
+	if probe.Code == http.StatusTooManyRequests {
+		t.Error("the liveness probe was rate limited")
+	}
+
+	if p := Posture(sys); !p.RateLimited || p.MaxBodyBytes != 64 {
+		t.Errorf("posture = %+v, want the limits reported", p)
+	}
+	if !strings.Contains(Posture(sys).String(), "rate limited to 1/s") {
+		t.Errorf("the startup line does not state the rate limit: %s", Posture(sys))
+	}
+}
@@ -0,0 +1,240 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// Same-host traffic over Unix domain sockets.
+//
+// On a gateway several systems share one box, and every call between them went
+// out through the network stack to an address on the LAN and came straight back.
+// A socket is faster, and a system that serves only its neighbours can then keep
+// its TCP port on loopback, or close it, and be off the network altogether.
+//
+// Nothing in a URL says "socket". The provider registers the socket beside its
+// address, the orchestrator hands it on with the service point, and a consumer
+// that finds the provider's address is one of its own dials the socket in place
+// of the address — so the URL, the token bound to it and every caller of
+// sendHTTPReqWithToken stay exactly as they are.
+
+// listenUnix opens a Unix domain socket at path.
+//
+// A socket file outlives a process that was killed rather than stopped, and
+// binding over it fails with "address already in use" although nothing is. So a
+// leftover socket is removed — but only once dialing it has shown that nobody is
+// answering, so a second system configured with the same path is refused rather
+// than silently taking the first one's traffic.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already being served", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing the stale socket %s: %w", path, err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// localRoutes maps a provider's host:port, as it appears in a service URL, to
+// the socket that reaches the same server on this host.
+var localRoutes sync.Map
+
+// socketPort is the port in the URL of a provider that serves on its socket
+// alone: one of its own, so that two such providers on a host are two hosts to
+// the client, each with its socket route and its connections.
+//
+// Past the last TCP port, so that the URL can be reached through the socket
+// and no other way. A consumer whose socket route is gone gets "invalid port"
+// rather than whatever happens to listen on a real port of that address, and
+// the address itself is kept so that a TLS peer is verified as it would be
+// over TCP.
+func socketPort(socket string) int {
+	h := fnv.New32a()
+	h.Write([]byte(socket))
+	return 1<<16 + int(h.Sum32()&(1<<30-1)) // within an int on 32-bit gateways too
+}
+
+// routeLocally records, or forgets, the socket that stands in for a discovered
+// service URL.
+//
+// A provider's socket is a path on the provider's host, and the same path on
+// another host is somebody else's socket or none. So it is used only when the
+// URL names this host — loopback, or one of its own addresses — and forgotten
+// when a later discovery no longer offers it.
+//
+// Nor is every socket on this host a provider's. The path comes from a service
+// record, and a record that is wrong, or written by somebody who should not
+// have, could name the container runtime's socket and have the consumer send
+// it requests with its token. So only a socket under the husk's SocketDir is
+// dialed; that directory should be one only the cloud's systems can write to.
+func routeLocally(sys *components.System, location, socket string) {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return
+	}
+	if socket == "" || !ownAddress(sys, u.Hostname()) {
+		localRoutes.Delete(u.Host)
+		return
+	}
+	if !inSocketDir(sys, socket) {
+		log.Printf("%s: %s offers the socket %s, which is not in the socket directory, so it is reached over TCP\n",
+			sys.Name, ForLog(u.Host), ForLog(socket)) //#nosec G706 -- sanitized by ForLog
+		localRoutes.Delete(u.Host)
+		return
+	}
+	localRoutes.Store(u.Host, socket)
+}
+
+// inSocketDir reports whether socket is under one of the directories a
+// provider's socket may be in: the husk's SocketDir or, where it names none,
+// those of the system's own sockets.
+func inSocketDir(sys *components.System, socket string) bool {
+	if sys.Husk == nil || !filepath.IsAbs(socket) {
+		return false
+	}
+	var dirs []string
+	if sys.Husk.SocketDir != "" {
+		dirs = append(dirs, sys.Husk.SocketDir)
+	} else {
+		for _, own := range sys.Husk.UnixSockets {
+			if own != "" {
+				dirs = append(dirs, filepath.Dir(own))
+			}
+		}
+	}
+	for _, dir := range dirs {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, filepath.Clean(socket))
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownAddress reports whether host names the machine this system runs on.
+func ownAddress(sys *components.System, host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	if sys.Husk == nil || sys.Husk.Host == nil {
+		return false
+	}
+	for _, own := range sys.Husk.Host.IPAddresses {
+		if ip.Equal(net.ParseIP(own)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialLocal dials the socket routed for addr. It reports false when there is
+// none, or when it does not answer: the provider may have been restarted
+// without one, and its TCP port is then still the way in.
+func dialLocal(ctx context.Context, addr string) (net.Conn, bool) {
+	path, ok := localRoutes.Load(addr)
+	if !ok {
+		return nil, false
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path.(string))
+	if err != nil {
+		log.Printf("the socket %s for %s does not answer, so it is reached over TCP: %v\n", path, addr, err)
+		localRoutes.Delete(addr)
+		return nil, false
+	}
+	return conn, true
+}
+
+// localFirst wraps the client's plain dial so a routed address is reached
+// through its socket.
+func localFirst(next func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if next == nil {
+		next = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if conn, ok := dialLocal(ctx, addr); ok {
+			return conn, nil
+		}
+		return next(ctx, network, addr)
+	}
+}
+
+// tlsOverSocket runs the client's TLS handshake on a socket connection.
+//
+// The server is verified against the address in the URL, exactly as over TCP:
+// the socket changes the path a byte takes, not whom the consumer believes it
+// is talking to, and the provider still learns the consumer's name from its
+// certificate — which is what every token is bound to.
+func tlsOverSocket(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	cfg := offerHTTP2(clientTLS.Load()).Clone() // nil before enrollment, as for dialTLS
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg.ServerName = host
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// servingAddress is the address this system's own URLs name: the one its TCP
+// servers are bound to when that is a single address, otherwise the one the
+// host advertises.
+func servingAddress(sys *components.System) string {
+	if ip := net.ParseIP(sys.Husk.ListenHost); ip != nil && !ip.IsUnspecified() {
+		return sys.Husk.ListenHost
+	}
+	return sys.Husk.Host.AdvertisedIP()
+}
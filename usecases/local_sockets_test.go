@@ -0,0 +1,310 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// socketDir returns a short directory for sockets: a socket's path is limited
+// to about a hundred bytes, which a test's own temporary directory can exceed.
+func socketDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "ah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// socketOnlyProvider sets out a system serving on socket alone, answering
+// every request with its name, and returns the record it registers.
+func socketOnlyProvider(t *testing.T, ctx context.Context, name, socket string) forms.ServiceRecord_v1 {
+	t.Helper()
+	provider := components.NewSystem(name, ctx)
+	provider.Husk = &components.Husk{
+		ProtoPort:   map[string]int{"http": 0},
+		UnixSockets: map[string]string{"http": socket},
+		Host:        &components.HostingDevice{Name: "gateway", IPAddresses: []string{"127.0.0.1"}},
+	}
+	ua := &components.UnitAsset{
+		Name:        "asset",
+		Mission:     components.MissionMeasurement,
+		ServicesMap: components.Services{"value": {Definition: "value", SubPath: "value"}},
+		ServingFunc: func(w http.ResponseWriter, r *http.Request, servicePath string) {
+			_, _ = w.Write([]byte(name))
+		},
+	}
+	provider.UAssets["asset"] = ua
+	if err := SetoutServers(&provider); err != nil {
+		t.Fatalf("setting out the servers of %s: %v", name, err)
+	}
+	if got := provider.Husk.Bound.Sockets()["http"]; got != socket {
+		t.Fatalf("the socket of %s is not recorded as bound: %q", name, got)
+	}
+
+	payload, err := serviceRegistrationForm(&provider, ua, ua.ServicesMap["value"], "ServiceRecord_v1")
+	if err != nil {
+		t.Fatalf("building the registration of %s: %v", name, err)
+	}
+	var rec forms.ServiceRecord_v1
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		t.Fatalf("unpacking the registration of %s: %v", name, err)
+	}
+	return rec
+}
+
+// A provider serving on its socket alone is reached by a consumer on the same
+// host through the URL it was given, with no TCP port behind that URL at all.
+func TestAConsumerOnTheSameHostReachesTheProviderOverItsSocket(t *testing.T) {
+	socket := filepath.Join(socketDir(t), "p.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// What the orchestrator would hand a consumer for the record the provider
+	// registers: an address on this host with the socket beside it.
+	sp := ConvertToServicePoint(socketOnlyProvider(t, ctx, "provider", socket))
+	if sp.LocalSocket != socket {
+		t.Fatalf("the service point carries socket %q, want %q", sp.LocalSocket, socket)
+	}
+
+	consumer := components.NewSystem("consumer", ctx)
+	consumer.Husk = &components.Husk{
+		Host:      &components.HostingDevice{IPAddresses: []string{"127.0.0.1"}},
+		SocketDir: filepath.Dir(socket),
+	}
+	routeLocally(&consumer, sp.ServLocation, sp.LocalSocket)
+	defer routeLocally(&consumer, sp.ServLocation, "")
+
+	if got := askFor(t, sp.ServLocation); got != "provider" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// Two providers on their sockets alone are two hosts to the consumer: each is
+// reached at its own socket, rather than both at whichever was routed last.
+func TestTwoSocketOnlyProvidersAreReachedEachAtItsOwn(t *testing.T) {
+	dir := socketDir(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	consumer := components.NewSystem("consumer", ctx)
+	consumer.Husk = &components.Husk{
+		Host:      &components.HostingDevice{IPAddresses: []string{"127.0.0.1"}},
+		SocketDir: dir,
+	}
+
+	locations := make(map[string]string)
+	for _, name := range []string{"north", "south"} {
+		sp := ConvertToServicePoint(socketOnlyProvider(t, ctx, name, filepath.Join(dir, name+".sock")))
+		routeLocally(&consumer, sp.ServLocation, sp.LocalSocket)
+		defer routeLocally(&consumer, sp.ServLocation, "")
+		locations[name] = sp.ServLocation
+	}
+	if locations["north"] == locations["south"] {
+		t.Fatalf("both are registered at %s", locations["north"])
+	}
+	for name, location := range locations {
+		if got := askFor(t, location); got != name {
+			t.Errorf("%s at %s: got %q", name, location, got)
+		}
+	}
+}
+
+// A socket that cannot be bound fails the setup without leaving the port
+// beside it bound.
+func TestASocketThatCannotBeBoundReleasesThePort(t *testing.T) {
+	notASocket := filepath.Join(socketDir(t), "p.sock")
+	if err := os.WriteFile(notASocket, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	port := freePort(t)
+	sys := components.NewSystem("provider", ctx)
+	sys.Husk = &components.Husk{
+		ListenHost:  "127.0.0.1",
+		ProtoPort:   map[string]int{"http": port},
+		UnixSockets: map[string]string{"http": notASocket},
+		Host:        &components.HostingDevice{IPAddresses: []string{"127.0.0.1"}},
+	}
+	if err := SetoutServers(&sys); err == nil {
+		t.Fatal("the setup succeeded with a socket path that is a file")
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("the port is still held: %v", err)
+	}
+	listener.Close()
+}
+
+// A socket path means something only on the host it was registered from. The
+// same path on another host is another system's socket or none at all.
+func TestASocketIsUsedOnlyForThisHostsOwnAddresses(t *testing.T) {
+	sys := components.NewSystem("consumer", context.Background())
+	sys.Husk = &components.Husk{
+		Host:      &components.HostingDevice{IPAddresses: []string{"192.168.1.10", "fd00::7", "127.0.0.1"}},
+		SocketDir: "/run/arrowhead",
+	}
+
+	table := map[string]bool{
+		"http://192.168.1.10:20150/p/a/v": true,
+		"http://[fd00::7]:20150/p/a/v":    true,
+		"http://127.0.0.1:20150/p/a/v":    true,
+		"http://[::1]:20150/p/a/v":        true,
+		"http://192.168.1.11:20150/p/a/v": false,
+		"http://[fd00::8]:20150/p/a/v":    false,
+	}
+	for location, local := range table {
+		routeLocally(&sys, location, "/run/arrowhead/p.sock")
+		u, _ := url.Parse(location)
+		_, routed := localRoutes.Load(u.Host)
+		if routed != local {
+			t.Errorf("%s: routed over the socket is %t, want %t", location, routed, local)
+		}
+		// A later discovery without the socket forgets it.
+		routeLocally(&sys, location, "")
+		if _, routed := localRoutes.Load(u.Host); routed {
+			t.Errorf("%s: still routed over the socket after it was withdrawn", location)
+		}
+	}
+}
+
+// A record naming a socket outside the socket directory is not followed into
+// it, however the path is spelled, and a system that names no directory trusts
+// the one its own sockets are in.
+func TestOnlyASocketInTheSocketDirectoryIsDialed(t *testing.T) {
+	sys := components.NewSystem("consumer", context.Background())
+	sys.Husk = &components.Husk{
+		Host:      &components.HostingDevice{IPAddresses: []string{"127.0.0.1"}},
+		SocketDir: "/run/arrowhead",
+	}
+	const location = "http://127.0.0.1:20150/p/a/v"
+	defer routeLocally(&sys, location, "")
+
+	table := map[string]bool{
+		"/run/arrowhead/p.sock":         true,
+		"/run/arrowhead/gateway/p.sock": true,
+		"/var/run/docker.sock":          false,
+		"/run/arrowhead/../docker.sock": false,
+		"/run/arrowhead-other/p.sock":   false,
+		"run/arrowhead/p.sock":          false,
+		"/run/arrowhead":                false,
+	}
+	for socket, dialed := range table {
+		routeLocally(&sys, location, socket)
+		if _, routed := localRoutes.Load("127.0.0.1:20150"); routed != dialed {
+			t.Errorf("%s: routed over the socket is %t, want %t", socket, routed, dialed)
+		}
+	}
+
+	sys.Husk.SocketDir = ""
+	sys.Husk.UnixSockets = map[string]string{"http": "/tmp/ah/consumer.sock"}
+	routeLocally(&sys, location, "/tmp/ah/p.sock")
+	if _, routed := localRoutes.Load("127.0.0.1:20150"); !routed {
+		t.Error("a socket beside the system's own was not dialed")
+	}
+	sys.Husk.UnixSockets = nil
+	routeLocally(&sys, location, "/tmp/ah/p.sock")
+	if _, routed := localRoutes.Load("127.0.0.1:20150"); routed {
+		t.Error("a system with no socket directory dialed a socket")
+	}
+}
+
+// A socket left by a process that was killed is cleared; one still being
+// served is not taken over.
+func TestListenUnixClearsOnlyAStaleSocket(t *testing.T) {
+	path := filepath.Join(socketDir(t), "s.sock")
+
+	first, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("binding a fresh socket: %v", err)
+	}
+	if _, err := listenUnix(path); err == nil {
+		t.Error("a socket being served was taken over")
+	}
+
+	// A killed process leaves the file behind; closing without unlinking is
+	// how to leave one here.
+	first.(*net.UnixListener).SetUnlinkOnClose(false)
+	first.Close()
+	if _, err := os.Lstat(path); err != nil {
+		t.Fatalf("the stale socket was not left behind: %v", err)
+	}
+	second, err := listenUnix(path)
+	if err != nil {
+		t.Fatalf("a stale socket was not cleared: %v", err)
+	}
+	second.Close()
+
+	if err := os.WriteFile(path, []byte("not a socket"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnix(path); err == nil {
+		t.Error("a regular file was removed to make way for a socket")
+	}
+}
+
+// A system bound to loopback registers loopback alone, and registers its
+// sockets beside its ports.
+func TestALoopbackSystemRegistersWhereItListens(t *testing.T) {
+	sys := createTestSystem(false)
+	sys.Husk.Host.IPAddresses = []string{"192.168.1.10", "127.0.0.1"}
+	sys.Husk.ListenHost = "127.0.0.1"
+	sys.Husk.Bound.Bind("http", 20150)
+	sys.Husk.Bound.BindSocket("http", "/run/arrowhead/p.sock")
+
+	var ua *components.UnitAsset
+	for _, asset := range sys.UAssets {
+		ua = asset
+		break
+	}
+	var serv *components.Service
+	for _, s := range (*ua).GetServices() {
+		serv = s
+		break
+	}
+	payload, err := serviceRegistrationForm(&sys, ua, serv, "ServiceRecord_v1")
+	if err != nil {
+		t.Fatalf("building the registration: %v", err)
+	}
+	var sr forms.ServiceRecord_v1
+	if err := json.Unmarshal(payload, &sr); err != nil {
+		t.Fatalf("unpacking the registration: %v", err)
+	}
+	if len(sr.IPAddresses) != 1 || sr.IPAddresses[0] != "127.0.0.1" {
+		t.Errorf("registered %v; a loopback-only system is reachable at loopback alone", sr.IPAddresses)
+	}
+	if sr.LocalSockets["http"] != "/run/arrowhead/p.sock" {
+		t.Errorf("registered sockets %v", sr.LocalSockets)
+	}
+	if sp := ConvertToServicePoint(sr); !strings.HasPrefix(sp.ServLocation, "http://127.0.0.1:20150/") {
+		t.Errorf("a consumer is sent to %q", sp.ServLocation)
+	}
+}
@@ -0,0 +1,206 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// What the framework counts. Every label value comes from a bounded set — the
+// system's own assets and services, the cervices it was configured with, a fixed
+// list of reasons — so that a caller inventing paths cannot invent series.
+const (
+	metricRequests        = "mbaigo_requests_total"
+	metricRequestDuration = "mbaigo_request_duration_seconds"
+	metricRefusals        = "mbaigo_authorization_refusals_total"
+	metricConsumption     = "mbaigo_consumption_duration_seconds"
+	metricConsumptionErrs = "mbaigo_consumption_errors_total"
+	metricRegistrations   = "mbaigo_registration_attempts_total"
+	metricRegistrationErr = "mbaigo_registration_failures_total"
+	metricSubscribers     = "mbaigo_publisher_subscribers"
+	metricDropped         = "mbaigo_publisher_dropped_events_total"
+	metricMessengerErrs   = "mbaigo_messenger_failures_total"
+)
+
+// otherLabel stands for any value outside the bounded set a label is drawn from.
+const otherLabel = "other"
+
+// metricsOf returns the system's metrics, or nil — which records nothing — for
+// a system assembled without a husk.
+func metricsOf(sys *components.System) *components.Metrics {
+	if sys == nil || sys.Husk == nil {
+		return nil
+	}
+	return &sys.Husk.Metrics
+}
+
+// Metering answers /<system>/metrics in the Prometheus text format.
+//
+// Subscriber counts are read from the publishers now rather than kept as they
+// change: a gauge that is only ever the current number has nothing to get out of
+// step with.
+func Metering(w http.ResponseWriter, r *http.Request, sys *components.System) {
+	m := metricsOf(sys)
+	for _, ua := range sys.UAssets {
+		for _, serv := range (*ua).GetServices() {
+			if publisher, ok := serv.Stream.(*Publisher); ok {
+				m.Set(metricSubscribers, "Consumers following a service.",
+					components.Labels{"asset": (*ua).GetName(), "service": serv.Definition},
+					float64(publisher.Subscribers()))
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.WritePrometheus(w); err != nil {
+		log.Println("Failed to write the metrics: ", err)
+	}
+}
+
+// metering is the outermost stage of the request chain: it sees the status the
+// caller was actually sent, refusals and recovered panics included.
+func metering(sys *components.System) components.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			labels := requestLabels(sys, r)
+			metricsOf(sys).Observe(metricRequestDuration, "Time taken to answer a request, in seconds.",
+				labels, time.Since(start).Seconds())
+			labels["status"] = strconv.Itoa(rec.Status())
+			metricsOf(sys).Add(metricRequests, "Requests answered, by asset, service, method and status.",
+				labels, 1)
+		})
+	}
+}
+
+// requestLabels names a request by what it asked for, in terms this system
+// defines. A path naming no asset or service of this system is "other".
+func requestLabels(sys *components.System, r *http.Request) components.Labels {
+	labels := components.Labels{"asset": "", "service": otherLabel, "method": otherLabel}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		labels["method"] = r.Method
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "", "doc", "kgraph", "smodel", "cert", "msg", "health", "ready", "metrics", "forms":
+			labels["service"] = parts[2]
+		}
+		return labels
+	}
+	if len(parts) < 3 {
+		return labels
+	}
+	ua, known := sys.UAssets[parts[2]]
+	if !known && len(parts) == 4 && parts[2] == "forms" {
+		// A form's schema, served as dispatch does unless an asset has the
+		// name; one series for all of them, not one per form version.
+		labels["service"] = "forms"
+		return labels
+	}
+	if !known {
+		labels["asset"] = otherLabel
+		return labels
+	}
+	labels["asset"] = parts[2]
+	if len(parts) > 3 {
+		if parts[3] == "doc" || parts[3] == FileService {
+			labels["service"] = parts[3]
+		} else if serv := findServiceByPath((*ua).GetServices(), parts[3]); serv != nil {
+			labels["service"] = serv.Definition
+		}
+	}
+	return labels
+}
+
+// statusRecorder notes the status a handler sent. It passes Flush and Unwrap
+// through, so that a stream served behind it is still a stream.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// Status is what was sent; a handler that wrote nothing sent 200.
+func (s *statusRecorder) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// countRefusal records why a request for a service was refused.
+func countRefusal(sys *components.System, reason string) {
+	metricsOf(sys).Add(metricRefusals, "Requests refused by authorization, by reason.",
+		components.Labels{"reason": reason}, 1)
+}
+
+// observeConsumption records one request made to a provider on a cervice's
+// behalf, and whether it failed.
+func observeConsumption(sys *components.System, cer *components.Cervice, method string, start time.Time, err error) {
+	labels := components.Labels{"cervice": cer.Definition, "method": method}
+	metricsOf(sys).Observe(metricConsumption, "Time taken by a provider to answer, in seconds.",
+		labels, time.Since(start).Seconds())
+	if err != nil {
+		metricsOf(sys).Add(metricConsumptionErrs, "Requests to a provider that failed, by cervice.",
+			labels, 1)
+	}
+}
+
+// countRegistration records one attempt to register a service with the lead
+// registrar, and whether it failed.
+func countRegistration(sys *components.System, service string, err error) {
+	labels := components.Labels{"service": service}
+	metricsOf(sys).Add(metricRegistrations, "Attempts to register a service with the lead registrar.",
+		labels, 1)
+	if err != nil {
+		metricsOf(sys).Add(metricRegistrationErr, "Attempts to register a service that failed.",
+			labels, 1)
+	}
+}
@@ -0,0 +1,89 @@
+package usecases
+
+import (
+	"crypto/ecdsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// Requests are counted by what this system calls them, refusals by why, and a
+// path nobody defined does not become a series of its own.
+func TestMetricsCountRequestsAndRefusals(t *testing.T) {
+	sys := systemUnderTest(t, &components.CoreSystem{
+		Name: AuthorizerName, Url: "http://localhost:20104/authorizer/authorization",
+	})
+	sys.Husk.Host = &components.HostingDevice{Name: "testhost"}
+	sys.UAssets["sensor_Id"] = &components.UnitAsset{
+		Name:        "sensor_Id",
+		Mission:     components.MissionMeasurement,
+		ServicesMap: components.Services{"temperature": {Definition: "temperature", SubPath: "temperature"}},
+		ServingFunc: func(w http.ResponseWriter, r *http.Request, servicePath string) {},
+	}
+
+	for _, path := range []string{
+		"/ds18b20/sensor_Id/temperature", // refused: the authorizer's key is not held yet
+		"/ds18b20/sensor_Id/doc",
+		"/ds18b20/invented_by_a_scanner/x",
+		"/ds18b20/forms",
+		"/ds18b20/forms/SignalA_v1.0",
+	} {
+		ResourceHandler(sys, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	ResourceHandler(sys, w, httptest.NewRequest(http.MethodGet, "/ds18b20/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	text := w.Body.String()
+	for _, want := range []string{
+		`mbaigo_requests_total{asset="sensor_Id",method="GET",service="temperature",status="503"} 1`,
+		`mbaigo_requests_total{asset="sensor_Id",method="GET",service="doc",status="200"} 1`,
+		`mbaigo_requests_total{asset="other",method="GET",service="other",status="404"} 1`,
+		`mbaigo_requests_total{asset="",method="GET",service="forms",status="200"} 2`,
+		`mbaigo_authorization_refusals_total{reason="key_unavailable"} 1`,
+		`mbaigo_request_duration_seconds_count{asset="sensor_Id",method="GET",service="temperature"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %s in\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "invented_by_a_scanner") {
+		t.Error("a path from the caller became a label value")
+	}
+}
+
+// A refusal is counted by why the token failed, so a cloud whose clocks drift
+// is told apart from one being probed with forged tokens.
+func TestTokenRefusalsAreClassified(t *testing.T) {
+	key, other := signingKey(t), signingKey(t)
+	now := time.Now()
+	mint := func(k *ecdsa.PrivateKey, c forms.AccessToken_v1) string {
+		token, err := MintToken(k, c)
+		if err != nil {
+			t.Fatalf("MintToken: %v", err)
+		}
+		return token
+	}
+	wrongAsset := theRequest()
+	wrongAsset.Asset = "another_sensor"
+
+	cases := map[string]string{
+		"not-a-token": refusedMalformed,
+		mint(other, claimsFor(theRequest(), now, time.Minute)): refusedSignature,
+		mint(key, claimsFor(theRequest(), now, -time.Minute)):  refusedExpired,
+		mint(key, claimsFor(wrongAsset, now, time.Minute)):     refusedClaims,
+	}
+	for token, want := range cases {
+		_, err := VerifyToken(token, &key.PublicKey, theRequest(), now)
+		if got := tokenRefusal(err); got != want {
+			t.Errorf("refused as %q, want %q (%v)", got, want, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+// Content negotiation: which of the representations a resource has the caller
+// would rather have (RFC 7231, section 5.3.2).
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// formTypes are the content types Pack can produce, in the order a caller
+// indifferent between them gets them: a caller that accepts anything is sent
+// JSON, as it always was.
+var formTypes = []string{"application/json", "application/xml", ContentTypeCBOR,
+	ContentTypeSenMLJSON, ContentTypeSenMLCBOR}
+
+// mediaRange is one entry of an Accept header.
+type mediaRange struct {
+	mainType, subType string
+	version           string  // the form version asked for, if any (see Pack)
+	q                 float64 // how much the caller wants it; 0 is not at all
+}
+
+// specificity ranks how closely a range names a type: an exact type beats
+// type/*, which beats */*. Only the most specific range matching a type says
+// how acceptable it is, so "text/*;q=0, text/turtle" refuses everything textual
+// except Turtle.
+func (m mediaRange) specificity() int {
+	switch {
+	case m.mainType == "*":
+		return 1
+	case m.subType == "*":
+		return 2
+	}
+	return 3
+}
+
+// matches reports whether the range covers the media type mediaType, which has
+// no parameters.
+func (m mediaRange) matches(mediaType string) bool {
+	mainType, subType, _ := strings.Cut(mediaType, "/")
+	return (m.mainType == "*" || m.mainType == mainType) && (m.subType == "*" || m.subType == subType)
+}
+
+// parseAccept reads an Accept header. An entry that cannot be read, or whose
+// q-value is not between 0 and 1, is dropped rather than guessed at.
+func parseAccept(header string) (ranges []mediaRange) {
+	for _, entry := range strings.Split(header, ",") {
+		parts := strings.Split(entry, ";")
+		mainType, subType, found := strings.Cut(strings.ToLower(strings.TrimSpace(parts[0])), "/")
+		if !found || mainType == "" || subType == "" || (mainType == "*" && subType != "*") {
+			continue
+		}
+		m := mediaRange{mainType: mainType, subType: subType, q: 1}
+		valid := true
+		for _, param := range parts[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "q":
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil || q < 0 || q > 1 {
+					valid = false
+				}
+				m.q = q
+			case "version":
+				m.version = value
+			}
+		}
+		if valid {
+			ranges = append(ranges, m)
+		}
+	}
+	return
+}
+
+// negotiate picks, among the types a resource is offered in, the one the Accept
+// header prefers: the highest q-value of each offer's most specific matching
+// range, ties going to the range the caller listed first and then to the offer
+// listed first. An offer whose range has q=0 is never chosen. It returns the
+// offer as given with the form version its range asks for, and false if the
+// caller accepts none of them.
+//
+// No header, or one of which nothing can be read, accepts anything, as RFC 7231
+// allows; the first offer is then chosen.
+func negotiate(header string, offers []string) (chosen, version string, ok bool) {
+	ranges := parseAccept(header)
+	if len(ranges) == 0 {
+		if len(offers) == 0 {
+			return "", "", false
+		}
+		return offers[0], "", true
+	}
+	bestQ, bestRank := 0.0, len(ranges)
+	for _, offer := range offers {
+		mediaType, _, err := mime.ParseMediaType(offer)
+		if err != nil {
+			continue
+		}
+		rank := -1
+		for i, m := range ranges {
+			if m.matches(mediaType) && (rank < 0 || m.specificity() > ranges[rank].specificity()) {
+				rank = i
+			}
+		}
+		if rank < 0 || ranges[rank].q == 0 {
+			continue
+		}
+		if q := ranges[rank].q; q > bestQ || (q == bestQ && rank < bestRank) {
+			bestQ, bestRank = q, rank
+			chosen, version, ok = offer, ranges[rank].version, true
+		}
+	}
+	return
+}
+
+// negotiated decides which of offers to answer r in, and says so to caches
+// with Vary: Accept, since the same URL answers differently by the header. If
+// the caller accepts none of them it is answered 406, with the types it could
+// have had, and ok is false.
+func negotiated(w http.ResponseWriter, r *http.Request, offers ...string) (contentType, version string, ok bool) {
+	w.Header().Add("Vary", "Accept")
+	contentType, version, ok = negotiate(r.Header.Get("Accept"), offers)
+	if !ok {
+		notAcceptable(w, offers)
+	}
+	return
+}
+
+// notAcceptable answers 406, naming what the resource is available in.
+func notAcceptable(w http.ResponseWriter, offers []string) {
+	http.Error(w, "Not acceptable; available as "+strings.Join(offers, ", "), http.StatusNotAcceptable)
+}
@@ -0,0 +1,103 @@
+package usecases
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// Wildcards match, the most specific range decides, q=0 refuses and a type
+// nothing can produce is not acceptable rather than answered in JSON.
+func TestNegotiate(t *testing.T) {
+	table := []struct {
+		accept string
+		want   string // "" is not acceptable
+	}{
+		{"*/*", "application/json"},
+		{"application/*", "application/json"},
+		{"application/*;q=0.5, application/cbor", ContentTypeCBOR},
+		{"application/json;q=0, */*", "application/xml"},
+		{"application/*;q=0, application/cbor;q=0.2", ContentTypeCBOR},
+		{"*/*;q=0", ""},
+		{"text/html, image/*", ""},
+		{"text/html;q=0.9, */*;q=0.1", "application/json"},
+		{"APPLICATION/XML", "application/xml"},
+		{"application/xml;q=0.5, application/cbor;q=0.5", "application/xml"},
+		{"nonsense", "application/json"},
+		{"application/json;version=SignalA_v1.0", "application/json; version=SignalA_v1.0"},
+		{"*/*;version=SignalA_v1.0", "application/json; version=SignalA_v1.0"},
+	}
+	for _, c := range table {
+		got, ok := getBestContentType(c.accept)
+		if c.want == "" && ok {
+			t.Errorf("%q chose %s, want not acceptable", c.accept, got)
+		}
+		if c.want != "" && (!ok || got != c.want) {
+			t.Errorf("%q chose %q (%v), want %s", c.accept, got, ok, c.want)
+		}
+	}
+}
+
+// A form nobody asked for in a type it has is answered 406, naming the types
+// it does have, and every answer varies by Accept.
+func TestUnacceptableFormsAreRefused(t *testing.T) {
+	get := func(accept string, f forms.Form) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/thermostat/controller/setpoint", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		HTTPProcessGetRequest(w, r, f)
+		return w
+	}
+
+	w := get("text/html", sample(21.5))
+	if w.Code != http.StatusNotAcceptable || w.Header().Get("Vary") != "Accept" {
+		t.Fatalf("text/html was answered %d, Vary %q", w.Code, w.Header().Get("Vary"))
+	}
+	if got := w.Body.String(); got != "Not acceptable; available as application/json, application/xml, "+
+		ContentTypeCBOR+", "+ContentTypeSenMLJSON+", "+ContentTypeSenMLCBOR+"\n" {
+		t.Errorf("the refusal reads %q", got)
+	}
+
+	w = get("*/*", sample(21.5))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" || w.Header().Get("Vary") != "Accept" {
+		t.Errorf("*/* was answered %d in %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	// SenML has no record for a messenger registration, and nothing else is
+	// acceptable to this caller.
+	other := forms.NewMessengerRegistration_v1("localhost")
+	if w := get(ContentTypeSenMLJSON, &other); w.Code != http.StatusNotAcceptable {
+		t.Errorf("a form without a SenML record, for a SenML-only caller, was answered %d", w.Code)
+	}
+}
+
+// The descriptions are negotiated too, each in its one type.
+func TestDescriptionsAreNegotiated(t *testing.T) {
+	sys := newKGTestSystem()
+	addTestAsset(sys)
+	for _, c := range []struct {
+		path    string
+		handler func(http.ResponseWriter, *http.Request)
+		accepts string
+	}{
+		{"/kgraph", func(w http.ResponseWriter, r *http.Request) { KGraphing(w, r, sys) }, "text/*"},
+		{"/smodel", func(w http.ResponseWriter, r *http.Request) { SModeling(w, r, sys) }, "text/plain"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		r.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		c.handler(w, r)
+		if w.Code != http.StatusNotAcceptable || w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s for JSON was answered %d, Vary %q", c.path, w.Code, w.Header().Get("Vary"))
+		}
+
+		r.Header.Set("Accept", c.accepts)
+		w = httptest.NewRecorder()
+		c.handler(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s for %s was answered %d", c.path, c.accepts, w.Code)
+		}
+	}
+}
@@ -58,16 +58,20 @@ const (
 // says its key was actually obtained. A cloud where those two disagree is one
 // that intends to authorize and currently cannot, which is worth seeing.
 type SecurityPosture struct {
-	Level string
+	Level string `json:"level"`
 
-	NamesCA         bool // a certificate authority is configured
-	NamesAuthorizer bool // an authorizer is configured
-	Identified      bool // this system holds a certificate issued by that CA
-	CanVerifyPeers  bool // it holds the CA certificate, so it can verify callers
-	VerifiesTokens  bool // it holds the authorizer's key, so it can check tokens
+	NamesCA         bool `json:"namesCA"`         // a certificate authority is configured
+	NamesAuthorizer bool `json:"namesAuthorizer"` // an authorizer is configured
+	Identified      bool `json:"identified"`      // this system holds a certificate issued by that CA
+	CanVerifyPeers  bool `json:"canVerifyPeers"`  // it holds the CA certificate, so it can verify callers
+	VerifiesTokens  bool `json:"verifiesTokens"`  // it holds the authorizer's key, so it can check tokens
 
-	OffersTLS        bool // an HTTPS port is configured
-	AcceptsPlaintext bool // an HTTP port is configured, so requests need no TLS
+	OffersTLS        bool `json:"offersTLS"`        // an HTTPS port is configured
+	AcceptsPlaintext bool `json:"acceptsPlaintext"` // an HTTP port is configured, so requests need no TLS
+
+	MaxBodyBytes int64   `json:"maxBodyBytes"`       // the largest body accepted where a service does not say
+	RateLimited  bool    `json:"rateLimited"`        // each peer's calls are rate limited
+	PeerRate     float64 `json:"peerRate,omitempty"` // requests per second allowed to one peer
 }
 
 // Posture reports how this system is currently protected.
@@ -94,6 +98,13 @@ func Posture(sys *components.System) SecurityPosture {
 	p.OffersTLS = sys.Husk.ProtoPort["https"] != 0
 	p.AcceptsPlaintext = sys.Husk.ProtoPort["http"] != 0
 
+	p.MaxBodyBytes = DefaultMaxBody
+	if sys.Husk.MaxBody > 0 {
+		p.MaxBodyBytes = sys.Husk.MaxBody
+	}
+	p.RateLimited = sys.Husk.RateLimit.PerSecond > 0
+	p.PeerRate = sys.Husk.RateLimit.PerSecond
+
 	switch {
 	case !p.NamesCA:
 		p.Level = PostureOpen
@@ -139,5 +150,12 @@ func (p SecurityPosture) String() string {
 		notes = append(notes, "an HTTP port is open, so this system is also reachable without TLS")
 	}
 
+	// Whatever the level: a peer allowed in can still call too often.
+	if p.RateLimited {
+		notes = append(notes, fmt.Sprintf("each peer is rate limited to %g/s", p.PeerRate))
+	} else {
+		notes = append(notes, "no rate limit: any peer may call as often as it likes")
+	}
+
 	return fmt.Sprintf("security: %s — %s", p.Level, strings.Join(notes, "; "))
 }
@@ -0,0 +1,108 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+// Provider errors: what a failed call to a provider says about the provider.
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorClass says what kind of failure a call to a provider was, which decides
+// what the consumer does about it.
+type ErrorClass string
+
+const (
+	// ProviderUnreachable: no answer at all — refused, timed out, no route.
+	// Whatever was discovered is not there now.
+	ProviderUnreachable ErrorClass = "unreachable"
+	// ProviderUnauthorized: 401 or 403. The provider is there and refused the
+	// token, which a new discovery replaces.
+	ProviderUnauthorized ErrorClass = "unauthorized"
+	// ProviderClientError: any other answer that is not a success or a 5xx —
+	// a 400 for this consumer's own payload, a 404, a 406. Nothing about the
+	// provider or its token is wrong.
+	ProviderClientError ErrorClass = "client error"
+	// ProviderBusy: 429 or a 5xx. The provider is there and cannot answer now;
+	// a 503 from one still waiting for the authorizer's key is the usual one.
+	ProviderBusy ErrorClass = "server busy"
+)
+
+// ProviderError is a call to a provider that failed, with the HTTP status it
+// answered and the class of the failure. Its message is the reason the
+// provider gave, or the transport's error where it gave none.
+type ProviderError struct {
+	URL    string
+	Status int // 0 where the provider did not answer
+	Class  ErrorClass
+	Err    error
+	// RetryAfter is how long the provider asked to be left alone, in its
+	// Retry-After header; zero where it did not say.
+	RetryAfter time.Duration
+}
+
+func (e *ProviderError) Error() string { return e.Err.Error() }
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// ClassOf is the class of a failed call to a provider, and false for an error
+// that is not one, such as a caller's canceled context.
+func ClassOf(err error) (ErrorClass, bool) {
+	var failed *ProviderError
+	if !errors.As(err, &failed) {
+		return "", false
+	}
+	return failed.Class, true
+}
+
+// statusClass classifies a provider's answer that was not a success.
+func statusClass(status int) ErrorClass {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ProviderUnauthorized
+	case status == http.StatusTooManyRequests || status >= 500:
+		return ProviderBusy
+	}
+	return ProviderClientError
+}
+
+// costsTheToken reports whether a failure of this class is worth forgetting the
+// provider's token over: it could not be reached, or it refused the token. A
+// provider that is busy, or that refused what this consumer sent, is still the
+// provider it was discovered as, with a token it accepts.
+func (c ErrorClass) costsTheToken() bool {
+	return c == ProviderUnreachable || c == ProviderUnauthorized
+}
+
+// tripsTheBreaker reports whether a failure of this class counts against the
+// provider's circuit: it is not answering, or not able to. A refusal is an
+// answer.
+func (c ErrorClass) tripsTheBreaker() bool {
+	return c == ProviderUnreachable || c == ProviderBusy
+}
+
+// retryAfterHeader reads a Retry-After header given in seconds, the form this
+// framework's own rate limit answers with; a date, or nothing, is zero.
+func retryAfterHeader(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
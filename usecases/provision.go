@@ -20,11 +20,13 @@
 package usecases
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
-	"strings"
+	"slices"
 
 	"github.com/sdoque/mbaigo/components"
 	"github.com/sdoque/mbaigo/forms"
@@ -43,10 +45,34 @@ func HTTPProcessGetRequest(w http.ResponseWriter, r *http.Request, f forms.Form)
 		return
 	}
 
-	acceptHeader := r.Header.Get("Accept")
-	bestContentType := getBestContentType(acceptHeader)
+	bestContentType, ok := withVersion(negotiated(w, r, formTypes...))
+	if !ok {
+		return
+	}
 
-	responseData, err := Pack(f, bestContentType)
+	var responseData []byte
+	var err error
+	if isSenML(bestContentType) {
+		responseData, err = packSenML(f, senmlName(r), bestContentType)
+		if errors.Is(err, errNotSenML) {
+			// Only the signals are SenML; any other form goes in whichever of
+			// the other types the caller accepts, and is not acceptable if none.
+			nonSenML := slices.DeleteFunc(slices.Clone(formTypes), isSenML)
+			bestContentType, ok = withVersion(negotiate(r.Header.Get("Accept"), nonSenML))
+			if !ok {
+				notAcceptable(w, nonSenML)
+				return
+			}
+			responseData, err = Pack(f, bestContentType)
+		}
+	} else {
+		responseData, err = Pack(f, bestContentType)
+	}
+	if errors.Is(err, forms.ErrNoMigration) {
+		// Asked for a version of the form this build cannot make.
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return
+	}
 	if err != nil {
 		log.Printf("Error packing response: %v", err)
 		http.Error(w, "Error packing response.", http.StatusInternalServerError)
@@ -62,65 +88,60 @@ func HTTPProcessGetRequest(w http.ResponseWriter, r *http.Request, f forms.Form)
 	}
 }
 
-// HTTPProcessSetRequest processes a SET request
+// HTTPProcessSetRequest processes a SET request. A body larger than the
+// service accepts is an error for which BodyTooLarge is true, and is answered
+// 413 whatever error status the caller gives it, or if it gives none. In a
+// system that validates forms, a payload that fails is answered 400 with its
+// problems, and is an error for which errors.As finds a *ValidationError; what
+// the caller then writes is dropped.
+//
+// It takes only SignalA_v1a; DecodeRequest is the same for any form.
 func HTTPProcessSetRequest(w http.ResponseWriter, req *http.Request) (sig forms.SignalA_v1a, err error) {
-	bodyBytes, err := io.ReadAll(req.Body) // Use io.ReadAll instead of ioutil.ReadAll
+	temp, err := DecodeRequest[*forms.SignalA_v1a](w, req)
 	if err != nil {
-		err = fmt.Errorf("reading request body: %w", err)
-		return
-	}
-	defer req.Body.Close()
-	headerContentType := req.Header.Get("Content-Type")
-	f, err := Unpack(bodyBytes, headerContentType)
-	if err != nil {
-		return
-	}
-	temp, ok := f.(*forms.SignalA_v1a)
-	if !ok {
-		err = fmt.Errorf("form is not of type SignalA_v1a")
 		return
 	}
 	sig = *temp // Stupid type conversion because return type was picked incorrectly
 	return
 }
 
-// getBestContentType parses the Accept header and returns the best content type based on q-values
-func getBestContentType(acceptHeader string) string {
-	if acceptHeader == "" {
-		return "application/json" // Default to JSON if no Accept header is provided
+// decodeRequest reads and unpacks a request's body, validating it where the
+// system is configured to; see HTTPProcessSetRequest.
+func decodeRequest(w http.ResponseWriter, req *http.Request) (f forms.Form, err error) {
+	bodyBytes, err := io.ReadAll(req.Body) // Use io.ReadAll instead of ioutil.ReadAll
+	if err != nil {
+		err = fmt.Errorf("reading request body: %w", err)
+		return
 	}
-
-	// Split the header by commas to get individual MIME types
-	mimeTypes := strings.Split(acceptHeader, ",")
-	bestType := ""
-	bestQValue := 0.0
-
-	for _, mimeType := range mimeTypes {
-		parts := strings.Split(strings.TrimSpace(mimeType), ";")
-		contentType := parts[0]
-		qValue := 1.0 // Default q-value is 1.0
-
-		// Check for q-value in the MIME type
-		if len(parts) > 1 && strings.HasPrefix(parts[1], "q=") {
-			_, err := fmt.Sscanf(parts[1], "q=%f", &qValue)
-			if err != nil {
-				continue
+	defer req.Body.Close()
+	headerContentType := req.Header.Get("Content-Type")
+	if check := formCheckOf(req); check != nil {
+		// Answered here, with every problem, rather than left to the caller,
+		// which would answer with only the first.
+		if err = Validate(bodyBytes, headerContentType); err != nil {
+			if invalid, ok := asValidationError(err); ok {
+				answerInvalid(w, check, invalid)
 			}
-		}
-
-		// Update the best content type if this one has a higher q-value
-		if qValue > bestQValue {
-			bestQValue = qValue
-			bestType = contentType
+			return
 		}
 	}
+	return Unpack(bodyBytes, headerContentType)
+}
 
-	// Default to JSON if no valid MIME type is found
-	if bestType == "" {
-		return "application/json"
-	}
+// getBestContentType parses the Accept header and returns the best of the
+// content types Pack can produce (see negotiate), with the form version asked
+// for as its parameter; false if the caller accepts none of them.
+func getBestContentType(acceptHeader string) (string, bool) {
+	return withVersion(negotiate(acceptHeader, formTypes))
+}
 
-	return bestType
+// withVersion puts the form version a caller asked for (see Pack) on the
+// content type negotiated for it.
+func withVersion(contentType, version string, ok bool) (string, bool) {
+	if ok && version != "" {
+		contentType = mime.FormatMediaType(contentType, map[string]string{"version": version})
+	}
+	return contentType, ok
 }
 
 func RegisterMessenger(resp http.ResponseWriter, req *http.Request, sys *components.System) {
@@ -130,6 +151,10 @@ func RegisterMessenger(resp http.ResponseWriter, req *http.Request, sys *compone
 	}
 
 	body, err := io.ReadAll(req.Body)
+	if BodyTooLarge(err) {
+		http.Error(resp, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
 		http.Error(resp, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -168,7 +168,7 @@ var getBestContentTypeParams = []getBestContentTypeStruct{
 
 func TestGetBestContentType(t *testing.T) {
 	for _, testCase := range getBestContentTypeParams {
-		res := getBestContentType(testCase.acceptHeaderInput)
+		res, _ := getBestContentType(testCase.acceptHeaderInput)
 
 		if res != testCase.bestContentTypeOutput {
 			t.Errorf("Expected %v, got: %v in test case: %s", testCase.bestContentTypeOutput, res, testCase.testName)
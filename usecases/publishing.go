@@ -27,6 +27,7 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -71,6 +72,11 @@ const (
 type Publisher struct {
 	service *components.Service
 
+	// metrics and asset are where a dropped event is counted, and under what.
+	// Set by PreparePublishers; a publisher made directly counts nothing.
+	metrics *components.Metrics
+	asset   string
+
 	mu sync.Mutex
 	// latest is the most recent sample, whatever it was. A heartbeat carries it,
 	// so a subscriber always sees the present state on every event whatever the
@@ -81,10 +87,22 @@ type Publisher struct {
 	// from. Kept apart from latest because they are different questions: a value
 	// drifting by less than the threshold moves latest on every sample and must
 	// leave baseline alone, or the drift is never reported however far it goes.
-	baseline    float64
+	baseline    sampled
 	hasBaseline bool
 	subscribers map[int]*subscription
 	nextID      int
+
+	// history is the latest readings, oldest first, for a service that keeps
+	// them (Service.History), all in historyUnit; see keep.
+	history     []forms.TimedValue
+	historyUnit string
+
+	// refusing is set when the system begins to shut down, and closed when the
+	// subscribers are to be let go. Apart because the first comes before
+	// deregistration and the second after it: the subscribers already following
+	// keep hearing the value while the registrar is told the service is going.
+	refusing bool
+	closed   chan struct{}
 }
 
 // subscription is one consumer listening.
@@ -118,8 +136,10 @@ func PreparePublishers(sys *components.System) {
 	for _, ua := range sys.UAssets {
 		asset := *ua
 		for _, serv := range asset.GetServices() {
-			if serv.SubscribeAble && serv.Stream == nil {
-				serv.Stream = NewPublisher(serv)
+			if (serv.SubscribeAble || serv.History > 0) && serv.Stream == nil {
+				publisher := NewPublisher(serv)
+				publisher.metrics, publisher.asset = metricsOf(sys), asset.GetName()
+				serv.Stream = publisher
 			}
 		}
 	}
@@ -160,7 +180,8 @@ func (p *Publisher) Sample(value forms.Form) {
 	if p == nil {
 		return
 	}
-	reading, ok := value.(forms.UnitBearer)
+	p.keep(value)
+	current, ok := sampledOf(value)
 	if !ok {
 		// A form with no value to compare cannot be thresholded, so every sample
 		// is a change. That is the honest reading of "I cannot tell whether this
@@ -170,8 +191,7 @@ func (p *Publisher) Sample(value forms.Form) {
 	}
 
 	p.mu.Lock()
-	current := reading.GetValue()
-	worth := !p.hasBaseline || moved(p.baseline, current, p.service.Threshold)
+	worth := !p.hasBaseline || p.baseline.movedTo(current, p.service.Threshold)
 	p.latest, p.hasLatest = value, true
 	if worth {
 		// The baseline moves only when the value is broadcast. Moving it on every
@@ -185,6 +205,54 @@ func (p *Publisher) Sample(value forms.Form) {
 	p.broadcast(value, worth)
 }
 
+// sampled is what a sample is compared by: its numbers, or the state it names.
+// A copy rather than the form, because a system that reuses one form for every
+// sample would otherwise compare each sample with itself.
+type sampled struct {
+	values  []float64
+	state   string
+	quality string
+}
+
+// sampledOf is the comparable part of a form, and whether it has one: the value
+// of a UnitBearer, the components of a VectorBearer, the state of a
+// StateBearer.
+func sampledOf(f forms.Form) (s sampled, ok bool) {
+	switch v := f.(type) {
+	case forms.UnitBearer:
+		s, ok = sampled{values: []float64{v.GetValue()}}, true
+	case forms.VectorBearer:
+		s, ok = sampled{values: slices.Clone(v.GetValues())}, true
+	case forms.StateBearer:
+		s, ok = sampled{state: v.GetState()}, true
+	}
+	if q, carries := f.(forms.QualityBearer); ok && carries {
+		s.quality = q.GetQuality() + "/" + q.GetCondition()
+	}
+	return s, ok
+}
+
+// movedTo reports whether a sample has changed enough to be worth sending. A
+// state has changed when it names another one; numbers when they have moved by
+// the threshold, a vector by its distance from where it was. A change of
+// quality is always worth sending, however little the value moved: a reading
+// that has gone stale, or become a substitute, is not the reading subscribers
+// were last told about even when its number is.
+func (r sampled) movedTo(to sampled, threshold float64) bool {
+	if r.state != to.state || r.quality != to.quality || len(r.values) != len(to.values) {
+		return true
+	}
+	if len(r.values) == 1 {
+		return moved(r.values[0], to.values[0], threshold)
+	}
+	var squares float64
+	for i := range r.values {
+		d := to.values[i] - r.values[i]
+		squares += d * d
+	}
+	return moved(0, math.Sqrt(squares), threshold)
+}
+
 // moved reports whether a reading has changed enough to be worth sending.
 func moved(from, to, threshold float64) bool {
 	if threshold <= 0 {
@@ -213,6 +281,8 @@ func (p *Publisher) broadcast(value forms.Form, changed bool) {
 		select {
 		case sub.events <- value:
 		default:
+			p.metrics.Add(metricDropped, "Events not sent to a subscriber that was not keeping up.",
+				components.Labels{"asset": p.asset, "service": p.service.Definition}, 1)
 		}
 	}
 }
@@ -282,11 +352,16 @@ func (p *Publisher) ServeStream(w http.ResponseWriter, r *http.Request) {
 
 	sub, remove := p.addSubscriber(agreed)
 	if sub == nil {
+		if p.Refusing() {
+			http.Error(w, "the system is shutting down", http.StatusServiceUnavailable)
+			return
+		}
 		log.Printf("%s: refusing a subscription; %d are already open\n",
 			p.service.Definition, maxSubscribers)
 		http.Error(w, "too many subscriptions are open on this service", http.StatusServiceUnavailable)
 		return
 	}
+	closed := p.closing()
 	defer remove()
 
 	if err := UnlimitStreamWrite(w); err != nil {
@@ -295,7 +370,10 @@ func (p *Publisher) ServeStream(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
+	if r.ProtoMajor == 1 {
+		// A connection header has no meaning in HTTP/2, whose streams share one.
+		w.Header().Set("Connection", "keep-alive")
+	}
 
 	send := func(name string, payload any) bool {
 		body, err := json.Marshal(payload)
@@ -333,6 +411,12 @@ func (p *Publisher) ServeStream(w http.ResponseWriter, r *http.Request) {
 		select {
 		case <-r.Context().Done():
 			return
+		case <-closed:
+			// Said rather than just hung up on, so that the subscriber knows the
+			// stream ended on purpose and goes back to discovery instead of
+			// reconnecting to a system that is about to be gone.
+			send("closing", map[string]any{"reason": "the provider is shutting down"})
+			return
 		case value := <-sub.events:
 			if !send("value", value) {
 				return
@@ -370,7 +454,7 @@ func proposed(r *http.Request) terms {
 func (p *Publisher) addSubscriber(agreed terms) (*subscription, func()) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if len(p.subscribers) >= maxSubscribers {
+	if p.refusing || len(p.subscribers) >= maxSubscribers {
 		return nil, nil
 	}
 	p.nextID++
@@ -384,6 +468,46 @@ func (p *Publisher) addSubscriber(agreed terms) (*subscription, func()) {
 	}
 }
 
+// StopAccepting turns new subscribers away, leaving those already following
+// alone. The first step of an ordered shutdown.
+func (p *Publisher) StopAccepting() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refusing = true
+}
+
+// Refusing reports whether StopAccepting has been called.
+func (p *Publisher) Refusing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.refusing
+}
+
+// Close sends every subscriber a final "closing" event and ends its stream. It
+// also refuses new subscribers, as StopAccepting does. Closing twice is harmless.
+func (p *Publisher) Close() {
+	closed := p.closing()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refusing = true
+	select {
+	case <-closed:
+	default:
+		close(closed)
+	}
+}
+
+// closing is the channel Close closes, made on first use so that a publisher
+// made as a literal can be closed too.
+func (p *Publisher) closing() chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed == nil {
+		p.closed = make(chan struct{})
+	}
+	return p.closed
+}
+
 // Subscribers reports how many consumers are following, which a system may want
 // to log and a test needs.
 func (p *Publisher) Subscribers() int {
@@ -472,9 +596,7 @@ func followUntilDone(cer *components.Cervice, sys *components.System) {
 // followBackoff spaces out reconnections, and spreads consumers apart so a
 // provider restarting is not met by all of them at once.
 func followBackoff(attempt int) time.Duration {
-	wait := 5 * time.Second << min(attempt, 4)
-	jitter := time.Duration(time.Now().UnixNano() % int64(wait/2))
-	return wait/2 + jitter
+	return jittered(5 * time.Second << min(attempt, 4))
 }
 
 // followOnce opens one subscription and reads it until it ends.
@@ -514,17 +636,32 @@ func followOnce(cer *components.Cervice, sys *components.System) error {
 	return readValues(cer, resp)
 }
 
-// followable returns a provider that publishes this cervice's value.
+// followable returns the provider to follow this cervice's value at: among
+// those that publish it, the one the cervice's Selector would call next.
+//
+// Peeked at, not selected. The Selector chooses where each call goes and learns
+// from how each went, and a subscription is not a call: selecting here would
+// spend a round-robin turn, or move a sticky choice, on every attempt to
+// follow, and the calls it is there for would go somewhere else. But it is
+// still the Selector's choice, so a consumer that prefers the providers in its
+// own building follows one of those.
+//
+// A provider whose circuit is open is not followed. With no other, there is
+// nothing to follow for now; the value is asked for, and the next read tries
+// again.
 func followable(cer *components.Cervice) (url, token string, ok bool) {
-	action := ActionForMethod(http.MethodGet)
+	var candidates []components.NodeInfo
 	for _, ni := range cer.Providers() {
-		if !ni.SubscribeAble || ni.URL == "" {
-			continue
+		if ni.SubscribeAble && ni.URL != "" && !breakerFor(cer, ni.URL).isOpen() {
+			candidates = append(candidates, ni)
 		}
-		tok, _ := ni.TokenFor(action)
-		return ni.URL, tok, true
 	}
-	return "", "", false
+	if len(candidates) == 0 {
+		return "", "", false
+	}
+	ni := candidates[peekAmong(cer.Selector, candidates)]
+	token, _ = ni.TokenFor(ActionForMethod(http.MethodGet))
+	return ni.URL, token, true
 }
 
 // readValues consumes the stream, keeping the cervice's value current.
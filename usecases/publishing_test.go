@@ -280,7 +280,7 @@ func TestAFollowedValueIsAnsweredWithoutARequest(t *testing.T) {
 	cer.StartFollowing()
 
 	sys := createTestSystem(false)
-	form, err := stateHandler(http.MethodGet, cer, &sys, nil)
+	form, err := stateHandler(context.Background(), http.MethodGet, cer, &sys, nil)
 	if err != nil {
 		t.Fatalf("reading a followed value: %v", err)
 	}
@@ -406,7 +406,7 @@ func TestAPublisherAndAConsumerMeet(t *testing.T) {
 		t.Fatal("the consumer subscribed and no value ever arrived")
 	}
 
-	form, err := stateHandler(http.MethodGet, cer, &sys, nil)
+	form, err := stateHandler(context.Background(), http.MethodGet, cer, &sys, nil)
 	if err != nil {
 		t.Fatalf("reading the followed value: %v", err)
 	}
@@ -418,7 +418,7 @@ func TestAPublisherAndAConsumerMeet(t *testing.T) {
 	publisher.Sample(sample(21.0))
 	deadline = time.Now().Add(3 * time.Second)
 	for time.Now().Before(deadline) {
-		form, err = stateHandler(http.MethodGet, cer, &sys, nil)
+		form, err = stateHandler(context.Background(), http.MethodGet, cer, &sys, nil)
 		if err == nil && form.(*forms.SignalA_v1a).Value == 21.0 {
 			return
 		}
@@ -654,3 +654,74 @@ func (d *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
 	d.deadline, d.set = deadline, true
 	return nil
 }
+
+// A vector is reported when it has moved the threshold's distance, and a state
+// when it names another one, however often either is sampled.
+func TestVectorsAndStatesAreReportedWhenTheyChange(t *testing.T) {
+	position := func(x, y float64) *forms.Vector_v1 {
+		v := &forms.Vector_v1{Values: []float64{x, y}, Unit: "<http://qudt.org/vocab/unit/M>"}
+		v.NewForm()
+		return v
+	}
+	publisher := NewPublisher(temperature(0.5))
+	sub, done := publisher.addSubscriber(terms{})
+	defer done()
+	for _, p := range [][2]float64{{0, 0}, {0.3, 0.3}, {0.3, 0.4}, {0.4, 0.4}} {
+		publisher.Sample(position(p[0], p[1]))
+	}
+	var sent [][]float64
+	for len(sub.events) > 0 {
+		sent = append(sent, (<-sub.events).(*forms.Vector_v1).Values)
+	}
+	if fmt.Sprint(sent) != "[[0 0] [0.3 0.4]]" {
+		t.Errorf("positions sent: %v; want the first and the first 0.5 from it", sent)
+	}
+
+	mode := func(value string) *forms.EnumState_v1 {
+		s := &forms.EnumState_v1{Value: value, Allowed: []string{"heating", "cooling", "idle"}}
+		s.NewForm()
+		return s
+	}
+	publisher = NewPublisher(temperature(0.5))
+	sub, done = publisher.addSubscriber(terms{})
+	defer done()
+	for _, m := range []string{"idle", "idle", "heating", "heating", "idle"} {
+		publisher.Sample(mode(m))
+	}
+	var modes []string
+	for len(sub.events) > 0 {
+		modes = append(modes, (<-sub.events).(*forms.EnumState_v1).Value)
+	}
+	if fmt.Sprint(modes) != "[idle heating idle]" {
+		t.Errorf("modes sent: %v", modes)
+	}
+}
+
+// A reading whose quality changes is sent even when its value has not moved
+// past the threshold, and one whose quality holds is not.
+func TestAChangeOfQualityIsBroadcast(t *testing.T) {
+	reading := func(value float64, quality, condition string) *forms.SignalA_v2a {
+		sig := &forms.SignalA_v2a{Value: value, Unit: "<http://qudt.org/vocab/unit/DEG_C>", Quality: quality, Condition: condition}
+		sig.NewForm()
+		return sig
+	}
+	publisher := NewPublisher(temperature(0.5))
+	sub, done := publisher.addSubscriber(terms{})
+	defer done()
+	publisher.Sample(reading(20.0, "", ""))
+	publisher.Sample(reading(20.0, forms.QualityGood, "")) // absent is good: no change
+	publisher.Sample(reading(20.1, forms.QualityUncertain, forms.ConditionStale))
+	publisher.Sample(reading(20.1, forms.QualityUncertain, forms.ConditionStale))
+	publisher.Sample(reading(20.1, forms.QualityUncertain, forms.ConditionSubstituted))
+	publisher.Sample(reading(20.2, forms.QualityGood, ""))
+
+	var sent []string
+	for len(sub.events) > 0 {
+		sig := (<-sub.events).(*forms.SignalA_v2a)
+		sent = append(sent, fmt.Sprintf("%v %s", sig.Value, sig.GetQuality()+"/"+sig.Condition))
+	}
+	want := "[20 good/ 20.1 uncertain/stale 20.1 uncertain/substituted 20.2 good/]"
+	if fmt.Sprint(sent) != want {
+		t.Errorf("sent %v\nwant %s", sent, want)
+	}
+}
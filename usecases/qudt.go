@@ -57,6 +57,12 @@ type UnitDef struct {
 	// units can print the same symbol and mean different things.
 	Symbol string
 
+	// SenML is the unit's name in the SenML units registry (RFC 8428 and RFC
+	// 8798), where it has one: "Cel" for degrees Celsius, where the symbol is
+	// "°C". Empty for a unit SenML does not name, which is sent as the SI unit
+	// of its kind instead (senml.go).
+	SenML string
+
 	// Dimension is QUDT's dimension vector local name. Two units can be
 	// converted only if these match, which is what stops a temperature being
 	// read as a length.
@@ -140,37 +146,37 @@ const (
 // release the generator pins.
 var units = map[string]UnitDef{
 	// Temperature. The only entries here with a non-zero offset.
-	qudtUnit + "K":     {Symbol: "K", QuantityKind: KindTemperature, Dimension: DimTemperature, Num: 1, Den: 1, Add: 0, HasFactor: true},
-	qudtUnit + "DEG_C": {Symbol: "°C", QuantityKind: KindTemperature, Dimension: DimTemperature, Num: 1, Den: 1, Add: 273.15, HasFactor: true},
+	qudtUnit + "K":     {Symbol: "K", QuantityKind: KindTemperature, Dimension: DimTemperature, Num: 1, Den: 1, Add: 0, HasFactor: true, SenML: "K"},
+	qudtUnit + "DEG_C": {Symbol: "°C", QuantityKind: KindTemperature, Dimension: DimTemperature, Num: 1, Den: 1, Add: 273.15, HasFactor: true, SenML: "Cel"},
 	qudtUnit + "DEG_F": {Symbol: "°F", QuantityKind: KindTemperature, Dimension: DimTemperature, Num: 5, Den: 9, Add: 2298.35, HasFactor: true},
 	qudtUnit + "DEG_R": {Symbol: "°R", QuantityKind: KindTemperature, Dimension: DimTemperature, Num: 5, Den: 9, Add: 0, HasFactor: true},
 
 	// Pressure.
-	qudtUnit + "PA":       {Symbol: "Pa", QuantityKind: KindPressure, Dimension: DimPressure, Num: 1, Den: 1, Add: 0, HasFactor: true},
+	qudtUnit + "PA":       {Symbol: "Pa", QuantityKind: KindPressure, Dimension: DimPressure, Num: 1, Den: 1, Add: 0, HasFactor: true, SenML: "Pa"},
 	qudtUnit + "KiloPA":   {Symbol: "kPa", QuantityKind: KindPressure, Dimension: DimPressure, Num: 1000, Den: 1, Add: 0, HasFactor: true},
-	qudtUnit + "BAR":      {Symbol: "bar", QuantityKind: KindPressure, Dimension: DimPressure, Num: 100000, Den: 1, Add: 0, HasFactor: true},
+	qudtUnit + "BAR":      {Symbol: "bar", QuantityKind: KindPressure, Dimension: DimPressure, Num: 100000, Den: 1, Add: 0, HasFactor: true, SenML: "bar"},
 	qudtUnit + "MilliBAR": {Symbol: "mbar", QuantityKind: KindPressure, Dimension: DimPressure, Num: 100, Den: 1, Add: 0, HasFactor: true},
 
 	// Time.
-	qudtUnit + "SEC":      {Symbol: "s", QuantityKind: KindTime, Dimension: DimTime, Num: 1, Den: 1, Add: 0, HasFactor: true},
-	qudtUnit + "MilliSEC": {Symbol: "ms", QuantityKind: KindTime, Dimension: DimTime, Num: 1, Den: 1000, Add: 0, HasFactor: true},
-	qudtUnit + "MIN":      {Symbol: "min", QuantityKind: KindTime, Dimension: DimTime, Num: 60, Den: 1, Add: 0, HasFactor: true},
-	qudtUnit + "HR":       {Symbol: "h", QuantityKind: KindTime, Dimension: DimTime, Num: 3600, Den: 1, Add: 0, HasFactor: true},
+	qudtUnit + "SEC":      {Symbol: "s", QuantityKind: KindTime, Dimension: DimTime, Num: 1, Den: 1, Add: 0, HasFactor: true, SenML: "s"},
+	qudtUnit + "MilliSEC": {Symbol: "ms", QuantityKind: KindTime, Dimension: DimTime, Num: 1, Den: 1000, Add: 0, HasFactor: true, SenML: "ms"},
+	qudtUnit + "MIN":      {Symbol: "min", QuantityKind: KindTime, Dimension: DimTime, Num: 60, Den: 1, Add: 0, HasFactor: true, SenML: "min"},
+	qudtUnit + "HR":       {Symbol: "h", QuantityKind: KindTime, Dimension: DimTime, Num: 3600, Den: 1, Add: 0, HasFactor: true, SenML: "h"},
 
 	// Length and mass, to show the pattern is general.
-	qudtUnit + "M":  {Symbol: "m", QuantityKind: KindLength, Dimension: DimLength, Num: 1, Den: 1, Add: 0, HasFactor: true},
+	qudtUnit + "M":  {Symbol: "m", QuantityKind: KindLength, Dimension: DimLength, Num: 1, Den: 1, Add: 0, HasFactor: true, SenML: "m"},
 	qudtUnit + "FT": {Symbol: "ft", QuantityKind: KindLength, Dimension: DimLength, Num: 3048, Den: 10000, Add: 0, HasFactor: true},
-	qudtUnit + "KG": {Symbol: "kg", QuantityKind: KindMass, Dimension: DimMass, Num: 1, Den: 1, Add: 0, HasFactor: true},
+	qudtUnit + "KG": {Symbol: "kg", QuantityKind: KindMass, Dimension: DimMass, Num: 1, Den: 1, Add: 0, HasFactor: true, SenML: "kg"},
 	qudtUnit + "LB": {Symbol: "lb", QuantityKind: KindMass, Dimension: DimMass, Num: 45359237, Den: 100000000, Add: 0, HasFactor: true},
 
 	// Plane angle. Dimensionless in SI, exactly like a ratio — which is why the
 	// quantity kind, not the dimension, is what keeps them apart.
-	qudtUnit + "RAD": {Symbol: "rad", QuantityKind: KindAngle, Dimension: DimDimensionless, Num: 1, Den: 1, Add: 0, HasFactor: true},
+	qudtUnit + "RAD": {Symbol: "rad", QuantityKind: KindAngle, Dimension: DimDimensionless, Num: 1, Den: 1, Add: 0, HasFactor: true, SenML: "rad"},
 	qudtUnit + "DEG": {Symbol: "°", QuantityKind: KindAngle, Dimension: DimDimensionless, Num: math.Pi, Den: 180, Add: 0, HasFactor: true},
 
 	// Dimensionless ratios.
-	qudtUnit + "PERCENT": {Symbol: "%", QuantityKind: KindRatio, Dimension: DimDimensionless, Num: 1, Den: 100, Add: 0, HasFactor: true},
-	qudtUnit + "NUM":     {Symbol: "", QuantityKind: KindRatio, Dimension: DimDimensionless, Num: 1, Den: 1, Add: 0, HasFactor: true},
+	qudtUnit + "PERCENT": {Symbol: "%", QuantityKind: KindRatio, Dimension: DimDimensionless, Num: 1, Den: 100, Add: 0, HasFactor: true, SenML: "%"},
+	qudtUnit + "NUM":     {Symbol: "", QuantityKind: KindRatio, Dimension: DimDimensionless, Num: 1, Den: 1, Add: 0, HasFactor: true, SenML: "/"},
 
 	// Logarithmic: present so that a request to convert one is refused with a
 	// reason rather than failing to resolve.
@@ -435,17 +441,40 @@ func NormalizeUnits(cer *components.Cervice, f forms.Form) (forms.Form, error) {
 		return f, nil // the consumer expressed no preference
 	}
 
+	if vector, ok := f.(forms.VectorBearer); ok {
+		if err := adoptVectorUnit(vector, want, isInterval(cer.Details)); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
 	bearer, ok := f.(forms.UnitBearer)
 	if !ok {
 		return f, nil // not a form whose value carries a unit
 	}
-	if err := AdoptUnit(bearer, want, isInterval(cer.Details)); err != nil {
+	// The value and its uncertainty are converted aside, and written to the
+	// form only once both have been, so a reading is converted whole or not at
+	// all, as a vector is.
+	value := &scalar{value: bearer.GetValue(), unit: bearer.GetUnit()}
+	if err := AdoptUnit(value, want, isInterval(cer.Details)); err != nil {
 		// No form alongside the error. Returning the unconverted one handed any
 		// caller that logs and continues a valid-looking reading in the wrong
 		// unit — the exact thing this function exists to prevent. Every caller
 		// already returns on error, so nothing loses anything it was using.
 		return nil, err
 	}
+	uncertain, hasUncertainty := f.(forms.UncertaintyBearer)
+	var uncertainty float64
+	if hasUncertainty {
+		var err error
+		if uncertainty, hasUncertainty, err = uncertaintyIn(uncertain, bearer.GetUnit(), want); err != nil {
+			return nil, err
+		}
+	}
+	bearer.SetValue(value.value)
+	bearer.SetUnit(value.unit)
+	if hasUncertainty {
+		uncertain.SetUncertainty(uncertainty)
+	}
 	return f, nil
 }
 
@@ -506,6 +535,60 @@ func AdoptUnit(bearer forms.UnitBearer, want string, interval bool) error {
 	return nil
 }
 
+// adoptVectorUnit is AdoptUnit for every component of a vector, which changes
+// nothing unless all of them convert: half a position in one unit and half in
+// another is worse than none.
+func adoptVectorUnit(vector forms.VectorBearer, want string, interval bool) error {
+	values := vector.GetValues()
+	converted := make([]float64, len(values))
+	unit := vector.GetUnit()
+	for i, v := range values {
+		component := &scalar{value: v, unit: vector.GetUnit()}
+		if err := AdoptUnit(component, want, interval); err != nil {
+			return err
+		}
+		converted[i], unit = component.value, component.unit
+	}
+	if len(values) == 0 {
+		// Nothing to convert, but the label still changes, or is refused.
+		component := &scalar{unit: unit}
+		if err := AdoptUnit(component, want, interval); err != nil {
+			return err
+		}
+		unit = component.unit
+	}
+	vector.SetValues(converted)
+	vector.SetUnit(unit)
+	return nil
+}
+
+// uncertaintyIn is a reading's uncertainty converted from the unit its value is
+// in to the one the caller works in, and whether it has one; the reading is
+// left as it is. An uncertainty is a difference, so it never carries the
+// offset: ±0.5 °C is ±0.9 °F, whatever the reading.
+func uncertaintyIn(uncertain forms.UncertaintyBearer, got, want string) (float64, bool, error) {
+	u, present := uncertain.GetUncertainty()
+	if !present {
+		return 0, false, nil
+	}
+	component := &scalar{value: u, unit: got}
+	if err := AdoptUnit(component, want, true); err != nil {
+		return 0, false, err
+	}
+	return component.value, true, nil
+}
+
+// scalar is one number and its unit, for converting what is not a form.
+type scalar struct {
+	value float64
+	unit  string
+}
+
+func (s *scalar) GetUnit() string    { return s.unit }
+func (s *scalar) SetUnit(u string)   { s.unit = u }
+func (s *scalar) GetValue() float64  { return s.value }
+func (s *scalar) SetValue(v float64) { s.value = v }
+
 // isInterval reports whether a cervice consumes differences rather than points
 // on a scale. It matters only where a unit has an offset, and there it decides
 // whether 5 degrees of control error becomes 9 or 41.
@@ -631,3 +631,59 @@ func TestAUnitIsSpelledOneWay(t *testing.T) {
 		t.Errorf("UnitIRI(\"\") = %q, want the empty string", got)
 	}
 }
+
+// A position in feet reaches a consumer working in metres as a position in
+// metres, every component of it; one that cannot be converted changes nothing.
+func TestNormalizeUnitsConvertsEveryComponentOfAVector(t *testing.T) {
+	position := &forms.Vector_v1{Values: []float64{1, 10, 100}, Unit: foot}
+	position.NewForm()
+	got, err := NormalizeUnits(cervice(map[string][]string{"Unit": {metre}}), position)
+	if err != nil {
+		t.Fatalf("NormalizeUnits: %v", err)
+	}
+	v := got.(*forms.Vector_v1)
+	closeTo(t, v.Values[0], 0.3048)
+	closeTo(t, v.Values[1], 3.048)
+	closeTo(t, v.Values[2], 30.48)
+	if v.Unit != metre {
+		t.Errorf("unit = %q; want %q", v.Unit, metre)
+	}
+
+	position = &forms.Vector_v1{Values: []float64{1, 2}, Unit: pound}
+	if _, err := NormalizeUnits(cervice(map[string][]string{"Unit": {metre}}), position); err == nil {
+		t.Error("a vector of masses was read as a position")
+	}
+	if position.Values[0] != 1 || position.Unit != pound {
+		t.Errorf("a refused vector was changed to %v %s", position.Values, position.Unit)
+	}
+}
+
+// An uncertainty converts with its reading, as the difference it is: ±0.5 °C
+// is ±0.9 °F, not ±32.9.
+func TestNormalizeUnitsConvertsTheUncertainty(t *testing.T) {
+	sig := &forms.SignalA_v2a{Value: 20, Unit: degC}
+	sig.NewForm()
+	sig.SetUncertainty(0.5)
+	got, err := NormalizeUnits(cervice(map[string][]string{"Unit": {degF}}), sig)
+	if err != nil {
+		t.Fatalf("NormalizeUnits: %v", err)
+	}
+	closeTo(t, got.(*forms.SignalA_v2a).Value, 68)
+	u, _ := got.(*forms.SignalA_v2a).GetUncertainty()
+	closeTo(t, u, 0.9)
+
+	plain := &forms.SignalA_v2a{Value: 20, Unit: degC}
+	if _, err := NormalizeUnits(cervice(map[string][]string{"Unit": {degF}}), plain); err != nil || plain.Uncertainty != nil {
+		t.Errorf("a reading without an uncertainty was given one: %v, %v", plain.Uncertainty, err)
+	}
+
+	// A reading that cannot be converted is left exactly as it came.
+	refused := &forms.SignalA_v2a{Value: 20, Unit: degC}
+	refused.SetUncertainty(0.5)
+	if _, err := NormalizeUnits(cervice(map[string][]string{"Unit": {pound}}), refused); err == nil {
+		t.Fatal("degrees were converted into pounds")
+	}
+	if u, _ := refused.GetUncertainty(); refused.Value != 20 || refused.Unit != degC || u != 0.5 {
+		t.Errorf("a refused reading was changed to %v %s ± %v", refused.Value, refused.Unit, u)
+	}
+}
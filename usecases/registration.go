@@ -91,12 +91,18 @@ func RegisterServices(sys *components.System) {
 		}
 	}()
 
-	// Run registration loops for each services
+	// Run registration loops for each services. Each holds the shutdown until it
+	// has deregistered its service: a consumer sent by the registrar to a system
+	// that has already stopped answering is the failure an ordered shutdown is
+	// there to prevent.
+	stopping := sys.Husk.Stopping.Signal()
 	assetList := &sys.UAssets
 	for _, aResource := range *assetList {
 		servs := (*aResource).GetServices()
 		for _, service := range servs {
+			release := sys.Husk.Stopping.Hold()
 			go func(theUnitAsset *components.UnitAsset, theService *components.Service) {
+				defer release()
 				delay := 1 * time.Second
 				var err error
 				for {
@@ -106,6 +112,12 @@ func RegisterServices(sys *components.System) {
 						if err != nil {
 							log.Println("registering service:", err)
 						}
+					case <-stopping:
+						err = unregisterService(registrar.get(), theService)
+						if err != nil {
+							log.Println("unregistering service:", err)
+						}
+						return
 					case <-sys.Ctx.Done():
 						err = unregisterService(registrar.get(), theService)
 						if err != nil {
@@ -119,6 +131,10 @@ func RegisterServices(sys *components.System) {
 	}
 }
 
+// errNoRegistrar is the outcome recorded for a service while no lead registrar
+// has been found to register it with.
+var errNoRegistrar = errors.New("no lead registrar has been found")
+
 // registerService makes a POST or PUT request to register or register individual services
 func registerService(sys *components.System, registrar string, ua *components.UnitAsset, serv *components.Service) (delay time.Duration, err error) {
 	delay = 15 * time.Second
@@ -130,12 +146,18 @@ func registerService(sys *components.System, registrar string, ua *components.Un
 		return 2 * time.Second, nil
 	}
 
+	service := (*ua).GetName() + "/" + serv.SubPath
 	if registrar == "" {
 		if serv.ID != 0 {
 			serv.ID = 0 // reset the service ID, so that a new registration (POST) will be made when the registrar is back
 		}
+		sys.Husk.Registered.Record(service, errNoRegistrar)
 		return
 	}
+	defer func() {
+		sys.Husk.Registered.Record(service, err)
+		countRegistration(sys, service, err)
+	}()
 
 	// Prepare request
 	reqPayload, err := serviceRegistrationForm(sys, ua, serv, "ServiceRecord_v1")
@@ -217,8 +239,8 @@ func registerService(sys *components.System, registrar string, ua *components.Un
 
 // unregisterService deletes a service from the database based on its service id
 func unregisterService(registrar string, serv *components.Service) error {
-	if registrar == "" {
-		return nil // there is no need to deregister if there is no leading registrar
+	if registrar == "" || serv.ID == 0 {
+		return nil // there is no need to deregister if there is no leading registrar, or nothing registered with it
 	}
 	u := registrar + "/unregister/" + strconv.Itoa(serv.ID)
 	req, err := http.NewRequest("DELETE", u, nil)
@@ -248,13 +270,21 @@ func serviceRegistrationForm(sys *components.System, ua *components.UnitAsset, s
 		sr.ServiceDefinition = serv.Definition
 		sr.SystemName = sys.Name
 		sr.ServiceNode = sys.Husk.Host.Name + "_" + sys.Name + "_" + resName + "_" + serv.Definition
-		sr.IPAddresses = sys.Husk.Host.IPAddresses
+		// In the order the host prefers them, since a consumer is sent to the
+		// first. On an IPv6-only segment that has to be the IPv6 address. A
+		// system bound to one address, loopback most often, registers that one
+		// alone: the others would send consumers where nothing is listening.
+		sr.IPAddresses = sys.Husk.Host.Advertised()
+		if addr := servingAddress(sys); addr != sys.Husk.Host.AdvertisedIP() {
+			sr.IPAddresses = []string{addr}
+		}
 		// What this system is serving, not what its configuration names. An
 		// HTTPS port binds only after enrollment, and a consumer is handed the
 		// HTTPS endpoint in preference to the HTTP one — so advertising it early
 		// sent every consumer to a port nothing was listening on, for as long as
 		// enrollment took, while the HTTP port beside it worked the whole time.
 		sr.ProtoPort = sys.Husk.Bound.Serving()
+		sr.LocalSockets = sys.Husk.Bound.Sockets()
 		// The mission travels on the record because the authorizer evaluates
 		// policy along it and reads from the registrar, not from each system's
 		// local configuration file. Registration copies only Details otherwise,
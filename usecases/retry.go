@@ -0,0 +1,105 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+// Retries: a call that failed for a reason that may pass is tried again.
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// The waits of a retry policy that sets none: short, since the caller is
+// usually a control loop that will ask again on its next tick anyway.
+const (
+	defaultFirstWait = 100 * time.Millisecond
+	defaultMaxWait   = 2 * time.Second
+)
+
+// retryableStatuses are the answers worth another attempt where the policy
+// names none: the provider or something in front of it is busy or briefly
+// away, and the same request may well succeed in a moment.
+var retryableStatuses = []int{http.StatusRequestTimeout, http.StatusTooManyRequests,
+	http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// retryAfter decides whether a call that has failed on its attempt-th attempt
+// is made again, and how long to wait first. Not where the policy's attempts
+// are spent, where the method cannot be repeated safely, or where the failure
+// will not pass by itself: a refusal of what was sent, an answer that could not
+// be read, a circuit left open or a caller that stopped waiting.
+//
+// The wait doubles from the policy's first up to its longest, as nextRetry's
+// does, jittered as followBackoff's is. A provider that says how long to leave
+// it alone is left alone that long; where it asks for longer than the policy's
+// longest wait, the call is not retried at all rather than retried early.
+func retryAfter(policy components.RetryPolicy, method string, attempt int, err error) (time.Duration, bool) {
+	if err == nil || attempt >= policy.Attempts || !repeatable(policy, method) {
+		return 0, false
+	}
+	var failed *ProviderError
+	if !errors.As(err, &failed) || errors.Is(err, ErrCircuitOpen) {
+		return 0, false
+	}
+	statuses := policy.Statuses
+	if statuses == nil {
+		statuses = retryableStatuses
+	}
+	if failed.Class != ProviderUnreachable && !slices.Contains(statuses, failed.Status) {
+		return 0, false
+	}
+
+	first, most := policy.FirstWait, policy.MaxWait
+	if first <= 0 {
+		first = defaultFirstWait
+	}
+	if most <= 0 {
+		most = max(defaultMaxWait, first)
+	}
+	if failed.RetryAfter > most {
+		return 0, false
+	}
+	wait := first
+	for range attempt - 1 {
+		wait = min(2*wait, most)
+	}
+	return max(jittered(wait), failed.RetryAfter), true
+}
+
+// repeatable reports whether a request by this method may be made twice. GET,
+// HEAD, PUT and DELETE are idempotent by definition; a POST is an invocation,
+// and is repeatable only where the policy says so.
+func repeatable(policy components.RetryPolicy, method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return policy.Idempotent
+}
+
+// jittered is a wait between half of wait and all of it, so that consumers
+// that failed together come back apart.
+func jittered(wait time.Duration) time.Duration {
+	if wait < 2 {
+		return wait
+	}
+	return wait/2 + rand.N(wait/2) //#nosec G404 -- spreading retries, not a secret
+}
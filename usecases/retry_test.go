@@ -0,0 +1,117 @@
+package usecases
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// answers is a transport that gives the statuses in turn, the last one for
+// every request after, and records which host each request went to.
+func answers(t *testing.T, statuses ...int) *[]string {
+	t.Helper()
+	var asked []string
+	fakeProvider(t, func(req *http.Request) int {
+		asked = append(asked, req.URL.Host)
+		return statuses[min(len(asked), len(statuses))-1]
+	})
+	return &asked
+}
+
+var quickly = components.RetryPolicy{Attempts: 3, FirstWait: time.Millisecond}
+
+// A read or a write that fails for a reason that passes is tried again; one
+// refused for what it sent is not, and the zero policy tries once.
+func TestStateCallsAreRetried(t *testing.T) {
+	sys := createTestSystem(false)
+	for _, c := range []struct {
+		name     string
+		policy   components.RetryPolicy
+		statuses []int
+		call     func(*components.Cervice) error
+		asked    int
+		ok       bool
+	}{
+		{"a read through two 503s", quickly, []int{503, 503, 200}, get(&sys), 3, true},
+		{"a write through a 502", quickly, []int{502, 200}, set(&sys), 2, true},
+		{"a write refused", quickly, []int{400, 200}, set(&sys), 1, false},
+		{"a read past its attempts", quickly, []int{503}, get(&sys), 3, false},
+		{"no policy", components.RetryPolicy{}, []int{503, 200}, get(&sys), 1, false},
+		{"a status the policy names", components.RetryPolicy{Attempts: 2, FirstWait: time.Millisecond, Statuses: []int{409}},
+			[]int{409, 200}, get(&sys), 2, true},
+		{"a status it does not", components.RetryPolicy{Attempts: 2, FirstWait: time.Millisecond, Statuses: []int{409}},
+			[]int{503, 200}, get(&sys), 1, false},
+		{"an invocation", quickly, []int{503, 200}, invoke(&sys), 1, false},
+		{"an idempotent invocation", components.RetryPolicy{Attempts: 2, FirstWait: time.Millisecond, Idempotent: true},
+			[]int{503, 200}, invoke(&sys), 2, true},
+	} {
+		asked := answers(t, c.statuses...)
+		cer := newTestCerviceWithNodes()
+		cer.Nodes["test"][0].Tokens["invoke"] = "" // discovered for invoking as well
+		cer.Retry = c.policy
+		err := c.call(cer)
+		if len(*asked) != c.asked || (err == nil) != c.ok {
+			t.Errorf("%s: asked %d times, err %v", c.name, len(*asked), err)
+		}
+	}
+}
+
+func get(sys *components.System) func(*components.Cervice) error {
+	return func(cer *components.Cervice) error { _, err := GetState(cer, sys); return err }
+}
+
+func set(sys *components.System) func(*components.Cervice) error {
+	return func(cer *components.Cervice) error { _, err := SetState(cer, sys, []byte(`{}`)); return err }
+}
+
+func invoke(sys *components.System) func(*components.Cervice) error {
+	return func(cer *components.Cervice) error { _, err := Invoke(cer, sys, []byte(`{}`)); return err }
+}
+
+// A provider that cannot be reached is passed over, and the retry goes to
+// another.
+func TestARetryGoesToAnotherProvider(t *testing.T) {
+	sys := createTestSystem(false)
+	asked := answers(t, 0, 200)
+	cer := &components.Cervice{
+		Definition: "temperature",
+		Nodes:      map[string][]components.NodeInfo{"sensors": candidates("http://down/t", "http://up/t")},
+		Retry:      quickly,
+	}
+	if _, err := GetState(cer, &sys); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(*asked, " "); got != "down up" {
+		t.Errorf("asked %s", got)
+	}
+}
+
+// The wait doubles up to the longest, within its jitter, and a provider's
+// Retry-After is honoured or, past the longest wait, not retried against.
+func TestRetryWaits(t *testing.T) {
+	policy := components.RetryPolicy{Attempts: 10, FirstWait: 100 * time.Millisecond, MaxWait: time.Second}
+	busy := &ProviderError{Class: ProviderBusy, Status: 503, Err: errors.New("503")}
+	for attempt, most := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond, 6: time.Second} {
+		wait, again := retryAfter(policy, http.MethodGet, attempt, busy)
+		if !again || wait < most/2 || wait > most {
+			t.Errorf("attempt %d: waits %v (%v), want up to %v", attempt, wait, again, most)
+		}
+	}
+
+	busy.RetryAfter = 700 * time.Millisecond
+	if wait, again := retryAfter(policy, http.MethodGet, 1, busy); !again || wait != 700*time.Millisecond {
+		t.Errorf("with Retry-After: waits %v (%v)", wait, again)
+	}
+	busy.RetryAfter = 5 * time.Second
+	if _, again := retryAfter(policy, http.MethodGet, 1, busy); again {
+		t.Error("retried sooner than the provider asked")
+	}
+	if _, again := retryAfter(policy, http.MethodGet, 1, circuitOpenError("http://a/t")); again {
+		t.Error("retried against an open circuit")
+	}
+}
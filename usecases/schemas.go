@@ -0,0 +1,334 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	goencoding "encoding"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// JSON Schema for the forms, so that a team writing a client in another
+// language can validate what it sends and generate the types it reads, rather
+// than transcribing Go structs by hand.
+//
+// The schemas are made from the forms themselves — their fields and their json
+// tags, read by reflection — and not written beside them: a schema kept by hand
+// is a second description of the form, and the first time the two disagree the
+// schema is the one that is wrong. What a schema says is what Pack sends. A
+// field is required when its form always carries it, which is every field not
+// tagged omitempty; a slice, a map or a pointer may be null, since that is what
+// Pack sends for one that is unset.
+//
+// Every system serves the schema of every form registered in its build, at
+// /<system>/forms/<version>, and lists them at /<system>/forms and on its /doc
+// page.
+
+// SchemaDialect is the JSON Schema draft the schemas are written in.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// ContentTypeSchema is the media type of a JSON Schema.
+const ContentTypeSchema = "application/schema+json"
+
+// FormSchema is the JSON Schema of the form registered under version.
+func FormSchema(version string) (map[string]any, error) {
+	formType, known := forms.FormTypeMap[version]
+	if !known {
+		return nil, fmt.Errorf("unsupported form version: %s", version)
+	}
+	s := schemaWriter{defs: map[string]any{}, named: map[reflect.Type]string{}}
+	schema := s.object(formType)
+	// The version is the one field whose value a schema can pin: a payload
+	// claiming another is another form.
+	if props, ok := schema["properties"].(map[string]any); ok {
+		if _, has := props["version"]; has {
+			props["version"] = map[string]any{"type": "string", "const": version}
+		}
+	}
+	schema["$schema"] = SchemaDialect
+	schema["title"] = version
+	schema["description"] = "mbaigo form " + version + ", Go type " + formType.String()
+	if len(s.defs) > 0 {
+		schema["$defs"] = s.defs
+	}
+	return schema, nil
+}
+
+// formVersions lists the registered forms, sorted.
+func formVersions() []string {
+	versions := make([]string, 0, len(forms.FormTypeMap))
+	for version := range forms.FormTypeMap {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// schemaWriter writes the schema of one form. A struct other than the form
+// itself goes into $defs once, under its type's name, and is referred to from
+// wherever it appears — which is also what lets a type that contains itself be
+// described at all.
+type schemaWriter struct {
+	defs  map[string]any
+	named map[reflect.Type]string
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*goencoding.TextMarshaler)(nil)).Elem()
+)
+
+// of is the schema of a value of type t, as encoding/json writes one.
+func (s *schemaWriter) of(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]any{"type": "integer", "description": "nanoseconds"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface &&
+		(t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType)):
+		return map[string]any{} // it writes itself, and says nothing of how
+	case t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface &&
+		(t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		bits := t.Bits() - 1
+		return map[string]any{"type": "integer", "minimum": -(int64(1) << bits), "maximum": int64(1)<<bits - 1}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": uint64(math.MaxUint64) >> (64 - t.Bits())}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": []any{"string", "null"}, "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": []any{"array", "null"}, "items": s.of(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": s.of(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		schema := map[string]any{"type": []any{"object", "null"}, "additionalProperties": s.of(t.Elem())}
+		if t.Key().Kind() != reflect.String {
+			schema["propertyNames"] = map[string]any{"pattern": "^-?[0-9]+$"}
+		}
+		return schema
+	case reflect.Pointer:
+		return nullable(s.of(t.Elem()))
+	case reflect.Struct:
+		return s.ref(t)
+	}
+	return map[string]any{} // an interface: anything at all
+}
+
+// ref refers to the definition of a named struct, writing it the first time.
+// An anonymous struct has no name to be defined under and is written in place.
+func (s *schemaWriter) ref(t reflect.Type) map[string]any {
+	if t.Name() == "" {
+		return s.object(t)
+	}
+	name, defined := s.named[t]
+	if !defined {
+		name = t.Name()
+		for i := 2; s.defs[name] != nil; i++ {
+			name = fmt.Sprintf("%s%d", t.Name(), i) // the same name from two packages
+		}
+		s.named[t] = name
+		s.defs[name] = true // held, so that a type containing itself stops here
+		s.defs[name] = s.object(t)
+	}
+	return map[string]any{"$ref": "#/$defs/" + name}
+}
+
+// object is the schema of a struct's fields.
+func (s *schemaWriter) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	s.fields(t, properties, &required)
+	// Closed: a field the form does not have is a sender writing to another
+	// version of it, or a typo, and either way nothing reads it.
+	schema := map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields adds t's fields to properties, following encoding/json: a field tagged
+// "-" is left out, an untagged embedded struct gives its fields to the one
+// embedding it, and a field tagged omitempty or omitzero may be absent. What the
+// type cannot say, a jsonschema tag does (see constrain).
+func (s *schemaWriter) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		embedded := f.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if f.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			s.fields(embedded, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema := s.of(f.Type)
+		if hasOption(options, "string") {
+			schema = map[string]any{"type": "string"} // a number or a boolean, quoted
+		}
+		constrain(schema, f.Tag.Get("jsonschema"))
+		properties[name] = schema
+		if !hasOption(options, "omitempty") && !hasOption(options, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// constrain adds to a field's schema what its jsonschema tag says beyond its
+// type, in the convention other Go schema generators read too:
+//
+//	Change    string `json:"change" jsonschema:"enum=registered,enum=deregistered"`
+//	Timestamp string `json:"timestamp" jsonschema:"format=date-time"`
+//	Name      string `json:"name" jsonschema:"minLength=1"`
+//
+// Only the keywords Validate checks are read: enum, format, minLength, minimum
+// and maximum.
+func constrain(schema map[string]any, tag string) {
+	if tag == "" {
+		return
+	}
+	for _, clause := range strings.Split(tag, ",") {
+		keyword, value, _ := strings.Cut(clause, "=")
+		switch keyword {
+		case "enum":
+			enum, _ := schema["enum"].([]any)
+			schema["enum"] = append(enum, value)
+		case "format":
+			schema["format"] = value
+		case "minLength", "minimum", "maximum":
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				schema[keyword] = n
+			}
+		}
+	}
+}
+
+// hasOption reports whether a json tag's options include option.
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// nullable lets a schema also be null, as a nil pointer is sent.
+func nullable(schema map[string]any) map[string]any {
+	switch kind := schema["type"].(type) {
+	case string:
+		schema["type"] = []any{kind, "null"}
+		return schema
+	case []any:
+		return schema // a slice or a map, already nullable
+	}
+	if len(schema) == 0 {
+		return schema // already anything, null included
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+// FormSchemas answers /<system>/forms with the list of the forms this system
+// knows, and /<system>/forms/<version> with one form's schema.
+func FormSchemas(w http.ResponseWriter, r *http.Request, sys *components.System, version string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var body any
+	if version == "" {
+		index := map[string]string{}
+		for _, v := range formVersions() {
+			index[v] = "/" + sys.Name + "/forms/" + v
+		}
+		body = index
+	} else {
+		schema, err := FormSchema(version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		schema["$id"] = schemaID(r)
+		body = schema
+	}
+	data, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		http.Error(w, "Error encoding the schema.", http.StatusInternalServerError)
+		return
+	}
+	if version == "" {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", ContentTypeSchema)
+	}
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error while writing a form schema: %v", err)
+	}
+}
+
+// schemaID is the URL a schema was fetched from, which is what it is known by.
+func schemaID(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
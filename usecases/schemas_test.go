@@ -0,0 +1,143 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// Every registered form has a schema, and what Pack sends for it matches the
+// schema's top level: no field the schema does not describe, and every field it
+// requires.
+func TestEveryFormHasASchemaMatchingWhatIsSent(t *testing.T) {
+	for version, formType := range forms.FormTypeMap {
+		schema, err := FormSchema(version)
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		if _, err := json.Marshal(schema); err != nil {
+			t.Fatalf("%s: the schema does not encode: %v", version, err)
+		}
+		if schema["$schema"] != SchemaDialect || schema["type"] != "object" {
+			t.Errorf("%s: not a 2020-12 object schema: %v", version, schema)
+		}
+
+		v := reflect.New(formType)
+		fill(v.Elem(), 0)
+		f := v.Interface().(forms.Form)
+		f.NewForm()
+		data, err := Pack(f, "application/json")
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		var sent map[string]any
+		if err := json.Unmarshal(data, &sent); err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		properties := schema["properties"].(map[string]any)
+		for name := range sent {
+			if _, described := properties[name]; !described {
+				t.Errorf("%s sends %q, which its schema does not describe", version, name)
+			}
+		}
+		required, _ := schema["required"].([]string)
+		for _, name := range required {
+			if _, present := sent[name]; !present {
+				t.Errorf("%s requires %q, which Pack did not send", version, name)
+			}
+		}
+	}
+	if _, err := FormSchema("NoSuchForm_v9"); err == nil {
+		t.Error("an unregistered version has a schema")
+	}
+}
+
+// The schema of a signal, spelled out.
+func TestSignalASchema(t *testing.T) {
+	schema, err := FormSchema("SignalA_v1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(schema["properties"])
+	want := `{"timestamp":{"format":"date-time","type":"string"},"unit":{"type":"string"},` +
+		`"value":{"type":"number"},"version":{"const":"SignalA_v1.0","type":"string"}}`
+	if string(data) != want {
+		t.Errorf("properties = %s\nwant         %s", data, want)
+	}
+	if got := schema["required"]; !reflect.DeepEqual(got, []string{"timestamp", "unit", "value", "version"}) {
+		t.Errorf("required = %v", got)
+	}
+}
+
+// Structs beside the form are defined once and referred to, a pointer may be
+// null, and an omitempty field is not required.
+func TestSchemaDefinesNestedStructsOnce(t *testing.T) {
+	type leaf struct {
+		Name string `json:"name"`
+	}
+	type node struct {
+		Left    leaf            `json:"left"`
+		Right   *leaf           `json:"right,omitempty"`
+		Next    *node           `json:"next"`
+		Tags    map[string]leaf `json:"tags"`
+		Skipped string          `json:"-"`
+		Count   uint8           `json:"count,string"`
+	}
+	s := schemaWriter{defs: map[string]any{}, named: map[reflect.Type]string{}}
+	got := s.object(reflect.TypeOf(node{}))
+	data, _ := json.Marshal(got)
+	want := `{"additionalProperties":false,"properties":{"count":{"type":"string"},"left":{"$ref":"#/$defs/leaf"},` +
+		`"next":{"anyOf":[{"$ref":"#/$defs/node"},{"type":"null"}]},` +
+		`"right":{"anyOf":[{"$ref":"#/$defs/leaf"},{"type":"null"}]},` +
+		`"tags":{"additionalProperties":{"$ref":"#/$defs/leaf"},"type":["object","null"]}},` +
+		`"required":["count","left","next","tags"],"type":"object"}`
+	if string(data) != want {
+		t.Errorf("schema = %s\nwant     %s", data, want)
+	}
+	if len(s.defs) != 2 {
+		t.Errorf("defined %d types, want leaf and node", len(s.defs))
+	}
+}
+
+// A system serves the schemas, lists them, and shows them on its page.
+func TestSchemasAreServed(t *testing.T) {
+	sys, _ := healthSystem(context.Background())
+
+	w := httptest.NewRecorder()
+	dispatch(sys, w, httptest.NewRequest(http.MethodGet, "/thermostat/forms/SignalA_v1.0", nil))
+	var schema map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &schema); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("%d %v\n%s", w.Code, err, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentTypeSchema {
+		t.Errorf("Content-Type = %s", ct)
+	}
+	if schema["$id"] != "http://example.com/thermostat/forms/SignalA_v1.0" || schema["title"] != "SignalA_v1.0" {
+		t.Errorf("$id %v, title %v", schema["$id"], schema["title"])
+	}
+
+	w = httptest.NewRecorder()
+	dispatch(sys, w, httptest.NewRequest(http.MethodGet, "/thermostat/forms/NoSuchForm_v9", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("an unknown form answered %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	dispatch(sys, w, httptest.NewRequest(http.MethodGet, "/thermostat/forms", nil))
+	var index map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &index); err != nil || index["SignalB_v1.0"] != "/thermostat/forms/SignalB_v1.0" {
+		t.Errorf("index = %v, %v", index, err)
+	}
+
+	w = httptest.NewRecorder()
+	dispatch(sys, w, httptest.NewRequest(http.MethodGet, "/thermostat/doc", nil))
+	if !strings.Contains(w.Body.String(), `<a href="/thermostat/forms/SignalA_v1.0">SignalA_v1.0</a>`) {
+		t.Error("the system's page does not list the forms")
+	}
+}
@@ -0,0 +1,264 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+// Provider selection: which of a cervice's providers a call goes to, where
+// several could answer it.
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+// RoundRobin takes the candidates in turn, spreading the calls evenly across
+// redundant providers.
+func RoundRobin() components.ProviderSelector { return &roundRobin{} }
+
+type roundRobin struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (r *roundRobin) Select(candidates []components.NodeInfo) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.next % len(candidates)
+	r.next = i + 1
+	return i
+}
+
+func (r *roundRobin) Peek(candidates []components.NodeInfo) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.next % len(candidates)
+}
+
+func (r *roundRobin) Observe(string, time.Duration, error) {}
+
+// RandomProvider takes any of the candidates. Where several consumers start
+// together, they do not all begin at the same provider, as they would in turn.
+func RandomProvider() components.ProviderSelector { return randomProvider{} }
+
+type randomProvider struct{}
+
+func (randomProvider) Select(candidates []components.NodeInfo) int {
+	return rand.IntN(len(candidates)) //#nosec G404 -- spreading load, not a secret
+}
+
+func (p randomProvider) Peek(candidates []components.NodeInfo) int { return p.Select(candidates) }
+
+func (randomProvider) Observe(string, time.Duration, error) {}
+
+// LowestLatency takes the candidate that has lately answered fastest. One not
+// yet called is tried first, so that every provider is measured, and one whose
+// last call failed is taken only if every other one's did too.
+func LowestLatency() components.ProviderSelector {
+	return &lowestLatency{latency: make(map[string]time.Duration), failed: make(map[string]bool)}
+}
+
+type lowestLatency struct {
+	mu      sync.Mutex
+	latency map[string]time.Duration // a moving average, so one slow answer does not condemn a provider
+	failed  map[string]bool
+}
+
+func (l *lowestLatency) Select(candidates []components.NodeInfo) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	best := 0
+	for i, ni := range candidates {
+		if l.better(ni.URL, candidates[best].URL) {
+			best = i
+		}
+	}
+	return best
+}
+
+// Peek is Select: choosing the fastest takes no turn.
+func (l *lowestLatency) Peek(candidates []components.NodeInfo) int { return l.Select(candidates) }
+
+// better reports whether a is to be preferred to b. The caller holds the lock.
+func (l *lowestLatency) better(a, b string) bool {
+	if l.failed[a] != l.failed[b] {
+		return l.failed[b]
+	}
+	la, measuredA := l.latency[a]
+	lb, measuredB := l.latency[b]
+	if measuredA != measuredB {
+		return !measuredA
+	}
+	return la < lb
+}
+
+func (l *lowestLatency) Observe(url string, took time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		l.failed[url] = true
+		return
+	}
+	previous, measured := l.latency[url]
+	if measured && !l.failed[url] {
+		took = (3*previous + took) / 4
+	}
+	l.latency[url] = took
+	delete(l.failed, url)
+}
+
+// Sticky keeps to one provider for as long as it answers, and moves to another
+// only when it fails or is no longer a candidate. A provider that keeps state
+// between calls — a session, a warmed cache — sees every call, and the others
+// stand by.
+func Sticky() components.ProviderSelector { return &sticky{} }
+
+type sticky struct {
+	mu     sync.Mutex
+	url    string // the provider kept to
+	failed string // the one last given up on, passed over while another remains
+}
+
+func (s *sticky) Select(candidates []components.NodeInfo) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.choice(candidates)
+	s.url = candidates[i].URL
+	return i
+}
+
+func (s *sticky) Peek(candidates []components.NodeInfo) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.choice(candidates)
+}
+
+// choice is the provider kept to, or the first not given up on where that is
+// no longer a candidate. The caller holds the lock.
+func (s *sticky) choice(candidates []components.NodeInfo) int {
+	if i := slices.IndexFunc(candidates, func(ni components.NodeInfo) bool { return ni.URL == s.url }); i >= 0 && s.url != "" {
+		return i
+	}
+	if i := slices.IndexFunc(candidates, func(ni components.NodeInfo) bool { return ni.URL != s.failed }); i >= 0 {
+		return i
+	}
+	return 0
+}
+
+func (s *sticky) Observe(url string, _ time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil && url == s.url {
+		s.url, s.failed = "", url
+	}
+}
+
+// PreferDetails takes the candidates whose details match want, and leaves the
+// choice among them to then; the rest are called only when none of them is a
+// candidate. A detail matches where the provider has any of the values wanted
+// for its key, so a consumer keeps its traffic in its own building with
+//
+//	cer.Selector = usecases.PreferDetails(
+//		map[string][]string{"Location": ua.Details["Location"]}, usecases.RoundRobin())
+//
+// A key with no values wanted is not held against anyone, and a nil then takes
+// the first.
+func PreferDetails(want map[string][]string, then components.ProviderSelector) components.ProviderSelector {
+	return &preferDetails{want: want, then: then}
+}
+
+type preferDetails struct {
+	want map[string][]string
+	then components.ProviderSelector
+}
+
+func (p *preferDetails) Select(candidates []components.NodeInfo) int {
+	return p.among(candidates, chooseAmong)
+}
+
+func (p *preferDetails) Peek(candidates []components.NodeInfo) int {
+	return p.among(candidates, peekAmong)
+}
+
+// among narrows candidates to those preferred, and has choose pick with then.
+func (p *preferDetails) among(candidates []components.NodeInfo,
+	choose func(components.ProviderSelector, []components.NodeInfo) int) int {
+	var preferred []int
+	for i, ni := range candidates {
+		if p.matches(ni.Details) {
+			preferred = append(preferred, i)
+		}
+	}
+	if len(preferred) == 0 {
+		return choose(p.then, candidates)
+	}
+	subset := make([]components.NodeInfo, len(preferred))
+	for j, i := range preferred {
+		subset[j] = candidates[i]
+	}
+	return preferred[choose(p.then, subset)]
+}
+
+func (p *preferDetails) matches(details map[string][]string) bool {
+	for key, values := range p.want {
+		if len(values) == 0 {
+			continue
+		}
+		if !slices.ContainsFunc(details[key], func(v string) bool { return slices.Contains(values, v) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *preferDetails) Observe(url string, took time.Duration, err error) {
+	if p.then != nil {
+		p.then.Observe(url, took, err)
+	}
+}
+
+// chooseAmong is the index selector picks in candidates: the first where there
+// is no selector, or where it names none of them.
+func chooseAmong(selector components.ProviderSelector, candidates []components.NodeInfo) int {
+	if selector == nil || len(candidates) == 0 {
+		return 0
+	}
+	if i := selector.Select(candidates); i >= 0 && i < len(candidates) {
+		return i
+	}
+	return 0
+}
+
+// peekAmong is chooseAmong without taking the selector's turn.
+func peekAmong(selector components.ProviderSelector, candidates []components.NodeInfo) int {
+	if selector == nil || len(candidates) == 0 {
+		return 0
+	}
+	if i := selector.Peek(candidates); i >= 0 && i < len(candidates) {
+		return i
+	}
+	return 0
+}
+
+// observeProvider tells the cervice's selector how a call to a provider went.
+func observeProvider(cer *components.Cervice, url string, start time.Time, err error) {
+	if cer.Selector != nil {
+		cer.Selector.Observe(url, time.Since(start), err)
+	}
+}
@@ -0,0 +1,178 @@
+package usecases
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+)
+
+func candidates(urls ...string) (nodes []components.NodeInfo) {
+	for _, url := range urls {
+		nodes = append(nodes, readNode(url))
+	}
+	return nodes
+}
+
+// picks is the URLs a selector chooses over n calls.
+func picks(selector components.ProviderSelector, nodes []components.NodeInfo, n int) (chosen []string) {
+	for range n {
+		chosen = append(chosen, nodes[selector.Select(nodes)].URL)
+	}
+	return chosen
+}
+
+func TestRoundRobinTakesEachInTurn(t *testing.T) {
+	got := picks(RoundRobin(), candidates("a", "b", "c"), 4)
+	if !equalStrings(got, []string{"a", "b", "c", "a"}) {
+		t.Errorf("picks = %q", got)
+	}
+	if i := RandomProvider().Select(candidates("a", "b")); i < 0 || i > 1 {
+		t.Errorf("random picked %d", i)
+	}
+}
+
+// Finding a provider to follow leaves the selector where it was: peeked at,
+// a round robin still starts at the first provider.
+func TestFollowingDoesNotSpendATurn(t *testing.T) {
+	nodes := candidates("http://a/t", "http://b/t")
+	for i := range nodes {
+		nodes[i].SubscribeAble = true
+	}
+	cer := &components.Cervice{Definition: "temperature", Selector: RoundRobin(),
+		Nodes: map[string][]components.NodeInfo{"sensors": nodes}}
+	for range 3 {
+		if url, _, ok := followable(cer); !ok || url != "http://a/t" {
+			t.Fatalf("followable = %s, %v", url, ok)
+		}
+	}
+	if url, _, _ := pickNode(cer, "read"); url != "http://a/t" {
+		t.Errorf("the first call went to %s", url)
+	}
+}
+
+// The followed provider is the selector's choice: a consumer preferring its
+// own building follows the sensor there. One whose circuit is open is passed
+// over, and with none other left nothing is followed.
+func TestTheSelectorChoosesWhatIsFollowed(t *testing.T) {
+	nodes := candidates("http://north/t", "http://south/t")
+	for i, location := range []string{"north", "south"} {
+		nodes[i].SubscribeAble = true
+		nodes[i].Details = map[string][]string{"Location": {location}}
+	}
+	cer := &components.Cervice{Definition: "temperature", BreakerThreshold: 1, BreakerCooldown: time.Minute,
+		Selector: PreferDetails(map[string][]string{"Location": {"south"}}, Sticky()),
+		Nodes:    map[string][]components.NodeInfo{"sensors": nodes}}
+	if url, _, ok := followable(cer); !ok || url != "http://south/t" {
+		t.Fatalf("followable = %s, %v", url, ok)
+	}
+
+	busy := &ProviderError{Class: ProviderBusy, Status: 503, Err: errors.New("503")}
+	for _, url := range []string{"http://south/t", "http://north/t"} {
+		b := breakerFor(cer, url)
+		b.admit()
+		b.record(cer, url, busy)
+		if url == "http://south/t" {
+			if got, _, ok := followable(cer); !ok || got != "http://north/t" {
+				t.Errorf("with south's circuit open, followable = %s, %v", got, ok)
+			}
+		}
+	}
+	if url, _, ok := followable(cer); ok {
+		t.Errorf("with every circuit open, %s is followed", url)
+	}
+}
+
+// Every provider is measured before the fastest is preferred, and one that
+// failed is taken last.
+func TestLowestLatencyPrefersTheFastest(t *testing.T) {
+	nodes := candidates("a", "b", "c")
+	selector := LowestLatency()
+	selector.Observe("a", 30*time.Millisecond, nil)
+	if got := picks(selector, nodes, 1); got[0] != "b" {
+		t.Errorf("chose %s before measuring b", got[0])
+	}
+	selector.Observe("b", 10*time.Millisecond, nil)
+	selector.Observe("c", 20*time.Millisecond, nil)
+	if got := picks(selector, nodes, 1); got[0] != "b" {
+		t.Errorf("chose %s, want the fastest", got[0])
+	}
+	selector.Observe("b", 0, errors.New("unreachable"))
+	if got := picks(selector, nodes, 1); got[0] != "c" {
+		t.Errorf("chose %s, want the fastest that did not fail", got[0])
+	}
+}
+
+func TestStickyFailsOver(t *testing.T) {
+	nodes := candidates("a", "b")
+	selector := Sticky()
+	if got := picks(selector, nodes, 3); !equalStrings(got, []string{"a", "a", "a"}) {
+		t.Errorf("picks = %q", got)
+	}
+	selector.Observe("a", 0, errors.New("unreachable"))
+	if got := picks(selector, nodes, 2); !equalStrings(got, []string{"b", "b"}) {
+		t.Errorf("after a failed: %q", got)
+	}
+	if got := picks(selector, candidates("a"), 1); got[0] != "a" {
+		t.Errorf("with only a left: %q", got)
+	}
+}
+
+// Providers in the consumer's location are taken while there are any, in turn;
+// the others only when none is.
+func TestPreferDetailsKeepsToTheLocation(t *testing.T) {
+	nodes := candidates("a", "b", "c")
+	nodes[0].Details = map[string][]string{"Location": {"hall"}}
+	nodes[1].Details = map[string][]string{"Location": {"lab"}}
+	nodes[2].Details = map[string][]string{"Location": {"lab", "hall"}}
+	selector := PreferDetails(map[string][]string{"Location": {"lab"}}, RoundRobin())
+	if got := picks(selector, nodes, 3); !equalStrings(got, []string{"b", "c", "b"}) {
+		t.Errorf("picks = %q", got)
+	}
+	if got := picks(selector, nodes[:1], 1); got[0] != "a" {
+		t.Errorf("with no provider in the lab: %q", got)
+	}
+	if got := picks(PreferDetails(map[string][]string{"Location": {"roof"}}, nil), nodes, 1); got[0] != "a" {
+		t.Errorf("with nothing preferred and no selector: %q", got)
+	}
+}
+
+// GetState spreads its calls as the selector says, and a provider that cannot
+// be reached is passed over for the next call rather than the registrar asked
+// again.
+func TestGetStateSelectsAndFailsOver(t *testing.T) {
+	sys := createTestSystem(false)
+	var asked []string
+	answer := createWorkingHttpResp()
+	useTransport(t, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		asked = append(asked, req.URL.Host)
+		if req.URL.Host == "down" {
+			return nil, errors.New("connection refused")
+		}
+		resp := answer()
+		resp.Request = req
+		return resp, nil
+	}))
+	cer := &components.Cervice{
+		Definition: "temperature",
+		Nodes:      map[string][]components.NodeInfo{"sensors": candidates("http://a/t", "http://b/t", "http://down/t")},
+		Selector:   RoundRobin(),
+	}
+	for range 4 {
+		GetState(cer, &sys)
+	}
+	if got := strings.Join(asked, " "); got != "a b down b" {
+		t.Errorf("asked %s", got)
+	}
+	if cer.ProviderCount() != 3 {
+		t.Errorf("%d providers are left", cer.ProviderCount())
+	}
+	for _, ni := range cer.Providers() {
+		if _, kept := ni.TokenFor("read"); kept == (ni.URL == "http://down/t") {
+			t.Errorf("%s: token kept %v", ni.URL, kept)
+		}
+	}
+}
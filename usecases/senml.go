@@ -0,0 +1,339 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// SenML (RFC 8428), for the tools outside the cloud that read sensor data in
+// it rather than in forms.
+//
+// Only the signals have a SenML representation: a SignalA_v1a is a record with
+// a number, a SignalB_v1a one with a boolean. Both carry the reading's time in
+// seconds since the epoch, and SignalA its unit — not the QUDT IRI but the
+// unit's name in the SenML registry, taken from the units table. A unit SenML
+// does not name (degrees Fahrenheit, kilopascals) is sent converted to the SI
+// unit of its kind, which SenML does name; one that cannot be converted, or that
+// the table does not know, is refused, since a SenML record with a unit the
+// receiver cannot look up is the mislabelled number the table exists to prevent.
+// A TimeSeries_v1 is a pack of SignalA records, one per reading.
+//
+// A provider that answers in SenML is read the same way back: the pack is
+// resolved as RFC 8428 section 4.6 says, and its most recent record becomes the
+// form its value calls for. That holds for a pack of several readings too,
+// including one written from a TimeSeries_v1: it reads back as its latest
+// reading, not as a series. A SenML device that batches its readings is still
+// read by a consumer that asked for a signal, which a series could not be
+// migrated into; a consumer that wants the series asks in a form, as GetSeries
+// does.
+
+// The SenML media types, in JSON and in CBOR.
+const (
+	ContentTypeSenMLJSON = "application/senml+json"
+	ContentTypeSenMLCBOR = "application/senml+cbor"
+)
+
+// errNotSenML is what packing a form SenML has no record for returns.
+var errNotSenML = errors.New("form has no SenML representation")
+
+// senmlCBORLabels are the integer labels SenML's CBOR representation uses for
+// its fields (RFC 8428 section 6).
+var senmlCBORLabels = cborLabels{
+	"bver": -1, "bn": -2, "bt": -3, "bu": -4, "bv": -5, "bs": -6,
+	"n": 0, "u": 1, "v": 2, "vs": 3, "vb": 4, "s": 5, "t": 6, "ut": 7, "vd": 8,
+}
+
+// senmlRelative is where SenML times stop being relative to now: a time below
+// 2**28 seconds is an offset, one above it a date (RFC 8428 section 4.5.3).
+const senmlRelative = 1 << 28
+
+// senmlRecord is one SenML record, as sent; the base fields apply to it and to
+// every record after it.
+type senmlRecord struct {
+	BaseVersion int      `json:"bver,omitempty"`
+	BaseName    string   `json:"bn,omitempty"`
+	BaseTime    float64  `json:"bt,omitempty"`
+	BaseUnit    string   `json:"bu,omitempty"`
+	BaseValue   float64  `json:"bv,omitempty"`
+	BaseSum     float64  `json:"bs,omitempty"`
+	Name        string   `json:"n,omitempty"`
+	Unit        string   `json:"u,omitempty"`
+	Value       *float64 `json:"v,omitempty"`
+	StringValue *string  `json:"vs,omitempty"`
+	BoolValue   *bool    `json:"vb,omitempty"`
+	DataValue   *string  `json:"vd,omitempty"`
+	Sum         *float64 `json:"s,omitempty"`
+	Time        float64  `json:"t,omitempty"`
+	UpdateTime  float64  `json:"ut,omitempty"`
+}
+
+// isSenML reports whether contentType is one of the SenML media types.
+func isSenML(contentType string) bool {
+	return strings.Contains(contentType, "application/senml+")
+}
+
+// packSenML renders a signal as a SenML pack of one record named name, in CBOR
+// if contentType asks for it and in JSON otherwise.
+func packSenML(f forms.Form, name, contentType string) ([]byte, error) {
+	record := senmlRecord{Name: name}
+	var at time.Time
+	switch sig := f.(type) {
+	case *forms.SignalA_v1a:
+		value, unit, err := senmlUnit(sig.Value, sig.Unit)
+		if err != nil {
+			return nil, err
+		}
+		record.Value, record.Unit, at = &value, unit, sig.Timestamp
+	case *forms.SignalA_v2a:
+		// SenML has no field for quality or provenance; the reading goes
+		// without them, as it would to a peer that asked for SignalA_v1.0.
+		value, unit, err := senmlUnit(sig.Value, sig.Unit)
+		if err != nil {
+			return nil, err
+		}
+		record.Value, record.Unit, at = &value, unit, sig.Timestamp
+	case *forms.SignalB_v1a:
+		value := sig.Value
+		record.BoolValue, at = &value, sig.Timestamp
+	case *forms.TimeSeries_v1:
+		return packSenMLSeries(sig, name, contentType)
+	default:
+		return nil, fmt.Errorf("%w: %s", errNotSenML, f.FormVersion())
+	}
+	if !at.IsZero() {
+		// A zero time is "now" to a SenML reader, which is what an untimed
+		// reading means here too.
+		record.Time = float64(at.UnixNano()) / 1e9
+	}
+	pack := []senmlRecord{record}
+	if strings.Contains(contentType, "cbor") {
+		return packLabelledCBOR(pack, senmlCBORLabels)
+	}
+	return json.MarshalIndent(pack, "", "  ")
+}
+
+// packSenMLSeries renders a series as a pack of one record per reading, all
+// named name, the name and unit said once in the first record's base fields.
+// Quality has no place in a SenML record and is left out. It is for readers
+// outside the cloud: Unpack reads such a pack as its latest reading.
+func packSenMLSeries(series *forms.TimeSeries_v1, name, contentType string) ([]byte, error) {
+	pack := make([]senmlRecord, 0, len(series.Samples))
+	for i, sample := range series.Samples {
+		value, unit, err := senmlUnit(sample.Value, series.Unit)
+		if err != nil {
+			return nil, err
+		}
+		record := senmlRecord{Value: &value, Time: float64(sample.Timestamp.UnixNano()) / 1e9}
+		if i == 0 {
+			record.BaseName, record.BaseUnit = name, unit
+		}
+		pack = append(pack, record)
+	}
+	if strings.Contains(contentType, "cbor") {
+		return packLabelledCBOR(pack, senmlCBORLabels)
+	}
+	return json.MarshalIndent(pack, "", "  ")
+}
+
+// senmlUnit is a reading in a unit SenML names, with that name. A reading
+// without a unit is sent without one.
+func senmlUnit(value float64, iri string) (float64, string, error) {
+	if iri == "" {
+		return value, "", nil
+	}
+	def, known := LookupUnit(iri)
+	if !known {
+		return 0, "", fmt.Errorf("unit %q is not in the units table, so has no SenML name", iri)
+	}
+	if def.SenML != "" {
+		return value, def.SenML, nil
+	}
+	si, found := senmlSI(def)
+	if !found {
+		return 0, "", fmt.Errorf("%s has no SenML name and no SI unit to be sent in", describe(def))
+	}
+	converted, err := Convert(value, def, si, false)
+	if err != nil {
+		return 0, "", err
+	}
+	return converted, si.SenML, nil
+}
+
+// senmlSI is the SI coincident unit of def's kind, if SenML names it.
+func senmlSI(def UnitDef) (UnitDef, bool) {
+	if !def.HasFactor || def.QuantityKind == "" {
+		return UnitDef{}, false
+	}
+	for _, u := range units {
+		if u.QuantityKind == def.QuantityKind && u.SenML != "" && u.HasFactor &&
+			u.Num == 1 && u.Den == 1 && u.Add == 0 {
+			return u, true
+		}
+	}
+	return UnitDef{}, false
+}
+
+// senmlUnitIRI is the QUDT unit for a SenML unit name.
+func senmlUnitIRI(name string) (string, bool) {
+	for iri, u := range units {
+		if u.SenML == name {
+			return iri, true
+		}
+	}
+	return "", false
+}
+
+// unpackSenML reads a SenML pack into the form its most recent record calls for.
+func unpackSenML(data []byte, contentType string) (forms.Form, error) {
+	if strings.Contains(contentType, "cbor") {
+		asJSON, _, err := unpackLabelledCBOR(data, senmlCBORLabels)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling SenML CBOR: %w", err)
+		}
+		data = asJSON
+	}
+
+	// Read twice: once loosely, for the fields RFC 8428 says a reader must
+	// refuse a pack over if it does not understand them (their names end in an
+	// underscore), and once into the records.
+	var loose []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &loose); err != nil {
+		return nil, fmt.Errorf("error unmarshalling SenML: %w", err)
+	}
+	for _, fields := range loose {
+		for name := range fields {
+			if strings.HasSuffix(name, "_") {
+				return nil, fmt.Errorf("SenML field %q must be understood, and is not", name)
+			}
+		}
+	}
+	var pack []senmlRecord
+	if err := json.Unmarshal(data, &pack); err != nil {
+		return nil, fmt.Errorf("error unmarshalling SenML: %w", err)
+	}
+	return senmlForm(pack, time.Now())
+}
+
+// senmlForm resolves pack's records against their base fields and makes a form
+// of the most recent, or of the last of those equally recent; the others are
+// dropped, even where they are a series of one name. now is what a relative
+// time is relative to.
+func senmlForm(pack []senmlRecord, now time.Time) (forms.Form, error) {
+	var (
+		base   senmlRecord
+		latest *senmlRecord
+		at     time.Time
+	)
+	for i := range pack {
+		r := pack[i]
+		if r.BaseVersion > 10 {
+			return nil, fmt.Errorf("SenML version %d is newer than this reader", r.BaseVersion)
+		}
+		// A base field holds until a later record sets it again.
+		if r.BaseName != "" {
+			base.BaseName = r.BaseName
+		}
+		if r.BaseTime != 0 {
+			base.BaseTime = r.BaseTime
+		}
+		if r.BaseUnit != "" {
+			base.BaseUnit = r.BaseUnit
+		}
+		if r.BaseValue != 0 {
+			base.BaseValue = r.BaseValue
+		}
+		if r.Value == nil && r.BoolValue == nil && r.StringValue == nil && r.DataValue == nil {
+			continue // a record of base fields, or of a sum alone
+		}
+
+		r.Name = base.BaseName + r.Name
+		if r.Unit == "" {
+			r.Unit = base.BaseUnit
+		}
+		if r.Value != nil {
+			value := base.BaseValue + *r.Value
+			r.Value = &value
+		}
+		t := senmlTime(base.BaseTime+r.Time, now)
+		if latest == nil || !t.Before(at) {
+			latest, at = &r, t
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("SenML pack has no record with a value")
+	}
+
+	switch {
+	case latest.Value != nil:
+		if math.IsNaN(*latest.Value) || math.IsInf(*latest.Value, 0) {
+			return nil, fmt.Errorf("SenML value of %q is not a number", latest.Name)
+		}
+		unit := ""
+		if latest.Unit != "" {
+			iri, known := senmlUnitIRI(latest.Unit)
+			if !known {
+				return nil, fmt.Errorf("SenML unit %q has no QUDT unit in the units table", latest.Unit)
+			}
+			unit = iri
+		}
+		var sig forms.SignalA_v1a
+		sig.NewForm()
+		sig.Value, sig.Unit, sig.Timestamp = *latest.Value, unit, at
+		return &sig, nil
+	case latest.BoolValue != nil:
+		var sig forms.SignalB_v1a
+		sig.NewForm()
+		sig.Value, sig.Timestamp = *latest.BoolValue, at
+		return &sig, nil
+	}
+	return nil, fmt.Errorf("SenML %q carries a string or data value, which no form holds", latest.Name)
+}
+
+// senmlTime is a resolved SenML time as a date: seconds since the epoch, or, for
+// a small value, seconds from now.
+func senmlTime(seconds float64, now time.Time) time.Time {
+	if seconds < senmlRelative {
+		return now.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	whole, frac := math.Modf(seconds)
+	// A float64 of today's date holds it to a few hundred nanoseconds; rounding
+	// to the microsecond keeps that noise out of the timestamp.
+	return time.Unix(int64(whole), int64(frac*1e9)).Round(time.Microsecond)
+}
+
+// senmlName is the name a resource's reading is given in SenML: its path, which
+// names the system, the asset and the service, without the leading slash that
+// SenML does not allow a name to start with.
+func senmlName(r *http.Request) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == ':', c == '.', c == '/', c == '_':
+			return c
+		}
+		return '_'
+	}, strings.TrimLeft(r.URL.Path, "/"))
+}
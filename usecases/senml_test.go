@@ -0,0 +1,165 @@
+package usecases
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// Both signals go out as one SenML record and come back as the form they were,
+// in JSON and in CBOR; the unit travels under its SenML name.
+func TestSignalsRoundTripThroughSenML(t *testing.T) {
+	at := time.Date(2026, 3, 14, 15, 9, 26, 535000000, time.UTC)
+	a := sample(21.5)
+	a.Timestamp = at
+	var b forms.SignalB_v1a
+	b.NewForm()
+	b.Value, b.Timestamp = true, at
+
+	for _, ct := range []string{ContentTypeSenMLJSON, ContentTypeSenMLCBOR} {
+		data, err := Pack(a, ct)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		if ct == ContentTypeSenMLJSON && !bytes.Contains(data, []byte(`"u": "Cel"`)) {
+			t.Errorf("the unit was not sent as Cel: %s", data)
+		}
+		f, err := Unpack(data, ct)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		got, ok := f.(*forms.SignalA_v1a)
+		if !ok || got.Value != 21.5 || got.Unit != qudtUnit+"DEG_C" || !got.Timestamp.Equal(at) ||
+			got.Version != "SignalA_v1.0" {
+			t.Errorf("%s: SignalA came back as %+v", ct, f)
+		}
+
+		data, err = Pack(&b, ct)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		f, err = Unpack(data, ct)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		if got, ok := f.(*forms.SignalB_v1a); !ok || !got.Value || !got.Timestamp.Equal(at) {
+			t.Errorf("%s: SignalB came back as %+v", ct, f)
+		}
+	}
+
+	// CBOR keys are SenML's integer labels: n is 0 and v is 2.
+	data, err := packLabelledCBOR([]senmlRecord{{Name: "x", Value: new(1.0)}}, senmlCBORLabels)
+	if want := []byte{0x81, 0xa2, 0x00, 0x61, 0x78, 0x02, 0x01}; err != nil || !bytes.Equal(data, want) {
+		t.Errorf("encoded as % x, %v; want % x", data, err, want)
+	}
+}
+
+// A unit SenML does not name goes as the SI unit of its kind; one that cannot be
+// converted, or is unknown, is refused.
+func TestSenMLSendsUnnamedUnitsInSI(t *testing.T) {
+	f := sample(212)
+	f.Unit = qudtUnit + "DEG_F"
+	data, err := Pack(f, ContentTypeSenMLJSON)
+	if err != nil || !bytes.Contains(data, []byte(`"u": "K"`)) || !bytes.Contains(data, []byte(`"v": 373.15`)) {
+		t.Errorf("212 °F went as %s, %v", data, err)
+	}
+	for _, unit := range []string{qudtUnit + "DeciB", "mm/h"} {
+		f.Unit = unit
+		if _, err := Pack(f, ContentTypeSenMLJSON); err == nil {
+			t.Errorf("%s was sent", unit)
+		}
+	}
+}
+
+// A pack from another tool is resolved against its base fields, and its most
+// recent record becomes the form.
+func TestSenMLPacksAreResolved(t *testing.T) {
+	pack := `[
+	  {"bn": "urn:dev:ow:10e2073a01080063:", "bt": 1.320067464e+09, "bu": "%", "bver": 5, "n": "humidity", "v": 20},
+	  {"n": "humidity", "v": 24.30621, "t": 60},
+	  {"n": "humidity", "v": 22, "t": 30}
+	]`
+	f, err := Unpack([]byte(pack), ContentTypeSenMLJSON+"; charset=utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := f.(*forms.SignalA_v1a)
+	if sig.Value != 24.30621 || sig.Unit != qudtUnit+"PERCENT" || !sig.Timestamp.Equal(time.Unix(1320067524, 0)) {
+		t.Errorf("resolved to %+v", sig)
+	}
+
+	// A time below 2**28 is relative to now.
+	before := time.Now()
+	f, err = Unpack([]byte(`[{"n": "valve", "vb": false, "t": -5}]`), ContentTypeSenMLJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := f.(*forms.SignalB_v1a).Timestamp; ts.After(before) || ts.Before(before.Add(-6*time.Second)) {
+		t.Errorf("a reading five seconds ago is stamped %v, at %v", ts, before)
+	}
+
+	for name, pack := range map[string]string{
+		"an unknown unit":      `[{"n": "power", "u": "W", "v": 3}]`,
+		"a string value":       `[{"n": "state", "vs": "open"}]`,
+		"no value at all":      `[{"bn": "empty:"}]`,
+		"a must-understand":    `[{"n": "x", "v": 1, "foo_": 2}]`,
+		"a newer version":      `[{"bver": 11, "n": "x", "v": 1}]`,
+		"an object, not array": `{"n": "x", "v": 1}`,
+	} {
+		if _, err := Unpack([]byte(pack), ContentTypeSenMLJSON); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+}
+
+// A series goes out as a pack of one record per reading, and is read back as
+// its latest reading: a SenML pack becomes the form of its most recent record,
+// whoever wrote it.
+func TestASeriesInSenMLReadsBackAsItsLatestReading(t *testing.T) {
+	at := time.Date(2026, 3, 14, 15, 0, 0, 0, time.UTC)
+	series := &forms.TimeSeries_v1{Unit: degC, Samples: []forms.TimedValue{
+		{Value: 20, Timestamp: at}, {Value: 22, Timestamp: at.Add(2 * time.Minute)}, {Value: 21, Timestamp: at.Add(time.Minute)},
+	}}
+	series.NewForm()
+	for _, ct := range []string{ContentTypeSenMLJSON, ContentTypeSenMLCBOR} {
+		data, err := packSenMLSeries(series, "thermostat/sensor/temperature", ct)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		f, err := Unpack(data, ct)
+		if err != nil {
+			t.Fatalf("%s: %v", ct, err)
+		}
+		sig, ok := f.(*forms.SignalA_v1a)
+		if !ok || sig.Value != 22 || sig.Unit != degC || !sig.Timestamp.Equal(at.Add(2*time.Minute)) {
+			t.Errorf("%s: the series came back as %+v", ct, f)
+		}
+	}
+}
+
+// A provider answers a SenML Accept with a record named for the resource, and
+// answers in JSON for a form SenML has no record for.
+func TestSenMLIsNegotiated(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/ds18b20/sensor_Id/temperature", nil)
+	r.Header.Set("Accept", ContentTypeSenMLJSON+", application/json;q=0.5")
+	w := httptest.NewRecorder()
+	HTTPProcessGetRequest(w, r, sample(21.5))
+	if ct := w.Header().Get("Content-Type"); ct != ContentTypeSenMLJSON {
+		t.Fatalf("answered in %s", ct)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"n": "ds18b20/sensor_Id/temperature"`) {
+		t.Errorf("the record is not named for the resource: %s", body)
+	}
+
+	other := forms.NewMessengerRegistration_v1("localhost")
+	w = httptest.NewRecorder()
+	HTTPProcessGetRequest(w, r, &other)
+	if ct := w.Header().Get("Content-Type"); w.Code != http.StatusOK || ct != "application/json" {
+		t.Errorf("a form without a SenML record was answered %d in %s", w.Code, ct)
+	}
+}
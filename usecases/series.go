@@ -0,0 +1,188 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+// Series: the readings a publisher has kept, answered as a TimeSeries_v1 to a
+// GET that asks for a range of them.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// SeriesQuery is the range of readings a caller asks for: from and to, both
+// included, and at most Limit of them, the latest. A zero field does not limit;
+// limit=0 in a query asks for everything kept.
+type SeriesQuery struct {
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// ParseSeriesQuery reads a series query from a request's from, to and limit,
+// and reports whether it asked for a series at all. The times are RFC 3339; a
+// query that cannot be read is an error, for the caller to answer 400.
+func ParseSeriesQuery(r *http.Request) (q SeriesQuery, asked bool, err error) {
+	query := r.URL.Query()
+	if !query.Has("from") && !query.Has("to") && !query.Has("limit") {
+		return q, false, nil
+	}
+	for name, t := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if v := query.Get(name); v != "" {
+			if *t, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				return q, true, fmt.Errorf("%s is not an RFC 3339 time: %q", name, v)
+			}
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			return q, true, fmt.Errorf("limit is not a number of readings: %q", v)
+		}
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return q, true, fmt.Errorf("to (%s) is before from (%s)", q.To.Format(time.RFC3339), q.From.Format(time.RFC3339))
+	}
+	return q, true, nil
+}
+
+// Values is the query as a URL query, the other way round from ParseSeriesQuery.
+func (q SeriesQuery) Values() url.Values {
+	values := url.Values{}
+	if !q.From.IsZero() {
+		values.Set("from", q.From.Format(time.RFC3339Nano))
+	}
+	if !q.To.IsZero() {
+		values.Set("to", q.To.Format(time.RFC3339Nano))
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if len(values) == 0 {
+		values.Set("limit", "0") // still a series, of everything kept
+	}
+	return values
+}
+
+// Select is the readings, oldest first, that fall in the range, and whether
+// some were left out for the limit.
+func (q SeriesQuery) Select(samples []forms.TimedValue) (selected []forms.TimedValue, truncated bool) {
+	for _, s := range samples {
+		if (!q.From.IsZero() && s.Timestamp.Before(q.From)) || (!q.To.IsZero() && s.Timestamp.After(q.To)) {
+			continue
+		}
+		selected = append(selected, s)
+	}
+	if q.Limit > 0 && len(selected) > q.Limit {
+		return selected[len(selected)-q.Limit:], true
+	}
+	return selected, false
+}
+
+// wantsSeries reports whether the caller asked for a range of readings rather
+// than the present one.
+func wantsSeries(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	query := r.URL.Query()
+	return query.Has("from") || query.Has("to") || query.Has("limit")
+}
+
+// keep adds a sample to the publisher's history, if the service keeps one.
+//
+// Only readings with a value and a unit are kept. The history is in one unit,
+// the unit of the readings as they arrive; a service whose unit changes starts
+// its history again rather than hand out a series of numbers in two units under
+// one label.
+func (p *Publisher) keep(value forms.Form) {
+	if p.service.History <= 0 {
+		return
+	}
+	reading, ok := value.(forms.UnitBearer)
+	if !ok {
+		return
+	}
+	at := time.Now()
+	if stamped, ok := value.(forms.Timestamped); ok && !stamped.GetTimestamp().IsZero() {
+		at = stamped.GetTimestamp()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if reading.GetUnit() != p.historyUnit {
+		p.history, p.historyUnit = nil, reading.GetUnit()
+	}
+	kept := forms.TimedValue{Value: reading.GetValue(), Timestamp: at}
+	if q, ok := value.(forms.QualityBearer); ok && q.GetQuality() != forms.QualityGood {
+		kept.Quality = q.GetQuality()
+	}
+	p.history = append(p.history, kept)
+	if over := len(p.history) - p.service.History; over > 0 {
+		p.history = p.history[over:]
+	}
+}
+
+// Series is the kept readings the query selects.
+func (p *Publisher) Series(q SeriesQuery) *forms.TimeSeries_v1 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	series := &forms.TimeSeries_v1{Unit: p.historyUnit}
+	series.NewForm()
+	selected, truncated := q.Select(p.history)
+	series.Samples = append([]forms.TimedValue{}, selected...) // not the history's own array
+	series.Truncated = truncated
+	return series
+}
+
+// ServeSeries answers a GET that asks for a range of readings, in whichever
+// encoding the caller accepts.
+func (p *Publisher) ServeSeries(w http.ResponseWriter, r *http.Request) {
+	q, _, err := ParseSeriesQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	HTTPProcessGetRequest(w, r, p.Series(q))
+}
+
+// keepsHistory reports whether a service's readings are kept to be asked for.
+func keepsHistory(serv *components.Service) (*Publisher, bool) {
+	p, ok := serv.Stream.(*Publisher)
+	return p, ok && serv.History > 0
+}
+
+// GetSeries asks a provider for a range of its readings, in the unit the
+// cervice asks for. Never answered from a followed value: what is followed is
+// the present reading, and a series is the readings before it.
+func GetSeries(cer *components.Cervice, sys *components.System, q SeriesQuery) (*forms.TimeSeries_v1, error) {
+	return GetSeriesCtx(systemContext(sys), cer, sys, q)
+}
+
+// GetSeriesCtx is GetSeries bounded by ctx; see GetStateCtx.
+func GetSeriesCtx(ctx context.Context, cer *components.Cervice, sys *components.System, q SeriesQuery) (*forms.TimeSeries_v1, error) {
+	f, err := stateRequest(ctx, http.MethodGet, cer, sys, nil, q.Values())
+	if err != nil {
+		return nil, err
+	}
+	return formAs[*forms.TimeSeries_v1](f)
+}
@@ -0,0 +1,153 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+func TestParseSeriesQuery(t *testing.T) {
+	parse := func(query string) (SeriesQuery, bool, error) {
+		return ParseSeriesQuery(httptest.NewRequest(http.MethodGet, "/thermostat/controller/temperature"+query, nil))
+	}
+	if _, asked, err := parse(""); asked || err != nil {
+		t.Errorf("no query: asked %t, %v", asked, err)
+	}
+	q, asked, err := parse("?from=2026-10-16T06:00:00Z&to=2026-10-16T07:00:00%2B01:00&limit=10")
+	if !asked || err != nil || q.Limit != 10 || !q.From.Equal(q.To) {
+		t.Errorf("got %+v, %t, %v", q, asked, err)
+	}
+	for _, bad := range []string{"?from=yesterday", "?limit=-1", "?limit=ten",
+		"?from=2026-10-16T07:00:00Z&to=2026-10-16T06:00:00Z"} {
+		if _, asked, err := parse(bad); !asked || err == nil {
+			t.Errorf("%s was accepted", bad)
+		}
+	}
+	back, _, err := parse("?" + q.Values().Encode())
+	if err != nil || back != q {
+		t.Errorf("the query did not survive its own encoding: %+v, %v", back, err)
+	}
+	if _, asked, _ := parse("?" + (SeriesQuery{}).Values().Encode()); !asked {
+		t.Error("an empty query no longer asks for a series")
+	}
+}
+
+// reading at a minute past six, plus minutes.
+func readingAt(minutes int, value float64, unit string) *forms.SignalA_v1a {
+	sig := &forms.SignalA_v1a{Value: value, Unit: unit,
+		Timestamp: time.Date(2026, 10, 16, 6, minutes, 0, 0, time.UTC)}
+	sig.NewForm()
+	return sig
+}
+
+// A publisher keeps the latest readings, as many as the service says, and
+// hands out the range asked for.
+func TestAPublisherKeepsItsHistory(t *testing.T) {
+	service := temperature(0)
+	service.History = 3
+	publisher := NewPublisher(service)
+	for i := range 5 {
+		publisher.Sample(readingAt(i, float64(20+i), degC))
+	}
+
+	series := publisher.Series(SeriesQuery{})
+	if len(series.Samples) != 3 || series.Samples[0].Value != 22 || series.Unit != degC || series.Truncated {
+		t.Errorf("kept %+v", series)
+	}
+	series = publisher.Series(SeriesQuery{Limit: 2})
+	if len(series.Samples) != 2 || series.Samples[0].Value != 23 || !series.Truncated {
+		t.Errorf("the latest two: %+v", series)
+	}
+	series = publisher.Series(SeriesQuery{To: readingAt(3, 0, "").Timestamp})
+	if len(series.Samples) != 2 || series.Samples[1].Value != 23 {
+		t.Errorf("up to 06:03: %+v", series)
+	}
+
+	publisher.Sample(readingAt(5, 77, degF))
+	if series := publisher.Series(SeriesQuery{}); len(series.Samples) != 1 || series.Unit != degF {
+		t.Errorf("after the unit changed: %+v", series)
+	}
+}
+
+// A GET with a range is answered from the history, in JSON or SenML; one on a
+// service that keeps none is the service's own, as before.
+func TestASeriesIsServed(t *testing.T) {
+	sys, _ := healthSystem(context.Background())
+	asset := sys.UAssets["controller"]
+	asset.ServicesMap["temperature"] = &components.Service{Definition: "temperature", SubPath: "temperature", History: 10}
+	served := 0
+	asset.ServingFunc = func(w http.ResponseWriter, r *http.Request, servicePath string) { served++ }
+	PreparePublishers(sys)
+	for i := range 3 {
+		Publish(asset, "temperature", readingAt(i, float64(20+i), degC))
+	}
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		dispatch(sys, w, r)
+		return w
+	}
+	w := get("/thermostat/controller/temperature?from=2026-10-16T06:01:00Z", "application/json")
+	f, err := Unpack(w.Body.Bytes(), w.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("%d %v\n%s", w.Code, err, w.Body.String())
+	}
+	if series, ok := f.(*forms.TimeSeries_v1); !ok || len(series.Samples) != 2 || series.Samples[1].Value != 22 {
+		t.Errorf("got %+v", f)
+	}
+
+	w = get("/thermostat/controller/temperature?limit=0", ContentTypeSenMLJSON)
+	var pack []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &pack); err != nil || len(pack) != 3 || pack[0]["bu"] != "Cel" {
+		t.Errorf("SenML: %v\n%s", err, w.Body.String())
+	}
+
+	if w := get("/thermostat/controller/temperature?limit=ten", "application/json"); w.Code != http.StatusBadRequest {
+		t.Errorf("an unreadable range answered %d", w.Code)
+	}
+	get("/thermostat/controller/setpoint?limit=2", "application/json")
+	get("/thermostat/controller/temperature", "application/json")
+	if served != 2 {
+		t.Errorf("the service answered %d requests itself, want the two that were not for its history", served)
+	}
+}
+
+// A consumer asks for the range in the query, and reads the series in its own
+// unit.
+func TestGetSeries(t *testing.T) {
+	var asked string
+	useTransport(t, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		asked = req.URL.RawQuery
+		series := &forms.TimeSeries_v1{Unit: degF, Samples: []forms.TimedValue{{Value: 32}, {Value: 212}}}
+		data, _ := json.Marshal(series.NewForm())
+		return &http.Response{Status: "200 OK", StatusCode: 200,
+			Header: http.Header{"Content-Type": []string{"application/json"}},
+			Body:   io.NopCloser(strings.NewReader(string(data))), Request: req}, nil
+	}))
+	sys := createTestSystem(false)
+	cer := newTestCerviceWithNodes()
+	cer.Details["Unit"] = []string{degC}
+
+	series, err := GetSeries(cer, &sys, SeriesQuery{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asked != "limit=2" {
+		t.Errorf("asked for %q", asked)
+	}
+	closeTo(t, series.Samples[0].Value, 0)
+	closeTo(t, series.Samples[1].Value, 100)
+	if series.Unit != degC {
+		t.Errorf("unit = %s", series.Unit)
+	}
+}
@@ -45,18 +45,26 @@ func SetoutServers(sys *components.System) error {
 	// get the servers port number (from configuration file)
 	httpPort := sys.Husk.ProtoPort["http"]
 	httpsPort := sys.Husk.ProtoPort["https"]
+	httpSocket := sys.Husk.UnixSockets["http"]
+	httpsSocket := sys.Husk.UnixSockets["https"]
 
-	if httpPort == 0 && httpsPort == 0 {
-		return fmt.Errorf("missing http(s) port in configuration")
+	if httpPort == 0 && httpsPort == 0 && httpSocket == "" && httpsSocket == "" {
+		return fmt.Errorf("missing http(s) port or socket in configuration")
 	}
 
-	// how to handle requests to the servers
-	http.HandleFunc("/"+sys.Name+"/", createResourceHandler(sys))
+	// how to handle requests to the servers: on this system's own router, so
+	// another system in the same process keeps its own
+	mux := EnsureServeMux(sys)
+	mux.HandleFunc("/"+sys.Name+"/", createResourceHandler(sys))
 
 	// Obtain the key incoming access tokens are verified against. Started here
 	// rather than in each system's main so that every provider enforces alike.
 	AcquireAuthorizerKey(sys)
 
+	// Whether this system's calls offer HTTP/2, which its servers below speak
+	// by the same setting.
+	installHTTP2(sys)
+
 	// Said once, plainly, at the point the system starts serving. An adopter
 	// running a cloud for the first time should learn what protection is in
 	// force from the terminal rather than by reading the configuration back.
@@ -70,7 +78,7 @@ func SetoutServers(sys *components.System) error {
 	// below is unaffected — it starts immediately, so the system is
 	// reachable on its plain-HTTP services even while cert acquisition is
 	// still in progress.
-	if httpsPort != 0 {
+	if httpsPort != 0 || httpsSocket != "" {
 		certReady := EnsureCertReady(sys)
 		go func() {
 			select {
@@ -79,61 +87,117 @@ func SetoutServers(sys *components.System) error {
 			case <-sys.Ctx.Done():
 				return
 			}
-			if err := startHTTPSServer(sys, httpsPort); err != nil {
+			if err := startHTTPSServer(sys, httpsPort, httpsSocket); err != nil {
 				log.Printf("HTTPS server failed to start: %v", err)
 			}
 		}()
 	}
 
 	// if an HTTP server is required (configuration file) set it up and start it
-	if httpPort != 0 {
+	if httpPort != 0 || httpSocket != "" {
 		// Create a HTTP server
 		httpServer := &http.Server{
-			Addr:         ":" + strconv.Itoa(httpPort),
+			Addr:         net.JoinHostPort(sys.Husk.ListenHost, strconv.Itoa(httpPort)),
 			ReadTimeout:  30 * time.Second,
 			WriteTimeout: 60 * time.Second,
-			Handler:      nil,
+			Handler:      mux,
+			Protocols:    serverProtocols(sys, false),
 		}
 
-		// Initiate graceful shutdown on signal reception
+		// Drained by Shutdown, in its turn. Should the context be canceled
+		// without it, the server is stopped all the same, within the deadline.
+		sys.Husk.Servers.Add(httpServer)
 		go func() {
 			<-sys.Ctx.Done()
-			if err := httpServer.Shutdown(context.Background()); err != nil {
-				log.Printf("Error during shutdown: %v", err)
-			}
+			stopServer(sys, httpServer)
 		}()
 
 		// Bind before announcing. ListenAndServe does both at once, so the
 		// system reported it was up and then, on a port already in use, failed
 		// immediately afterwards — two lines that contradict each other, in
 		// that order.
-		listener, err := net.Listen("tcp", httpServer.Addr)
-		if err != nil {
-			return fmt.Errorf("binding the HTTP port: %w", err)
+		//
+		// And both before either serves, as for HTTPS: a socket that cannot be
+		// bound otherwise left the port answering for a system whose setup had
+		// failed, and holding the port against its restart.
+		var tcpListener, socketListener net.Listener
+		if httpPort != 0 {
+			listener, err := net.Listen("tcp", httpServer.Addr)
+			if err != nil {
+				return fmt.Errorf("binding the HTTP port: %w", err)
+			}
+			tcpListener = listener
+		}
+		if httpSocket != "" {
+			listener, err := listenUnix(httpSocket)
+			if err != nil {
+				if tcpListener != nil {
+					tcpListener.Close()
+				}
+				return fmt.Errorf("binding the HTTP socket: %w", err)
+			}
+			socketListener = listener
 		}
-		sys.Husk.Bound.Bind("http", httpPort)
 
-		// Inform the user how to access the system's web server (black box documentation)
-		httpURL := "http://" + sys.Husk.Host.IPAddresses[0] + ":" + strconv.Itoa(httpPort) + "/" + sys.Name
-		log.Printf("The system %s is up with its web server available at %s\n", sys.Name, httpURL)
+		if tcpListener != nil {
+			sys.Husk.Bound.Bind("http", httpPort)
+
+			// Inform the user how to access the system's web server (black box documentation)
+			httpURL := "http://" + components.HostPort(servingAddress(sys), httpPort) + "/" + sys.Name
+			log.Printf("The system %s is up with its web server available at %s\n", sys.Name, httpURL)
+		}
+		if socketListener != nil {
+			sys.Husk.Bound.BindSocket("http", httpSocket)
+			log.Printf("The system %s is up with its web server available on the socket %s\n", sys.Name, httpSocket)
+		}
 
 		// Start and monitor the server
-		go func() {
-			err := httpServer.Serve(listener)
-			if err != nil && err != http.ErrServerClosed {
-				log.Fatalf("Error from web server: %v\n", err)
+		for _, listener := range []net.Listener{tcpListener, socketListener} {
+			if listener == nil {
+				continue
 			}
-		}()
+			go func() {
+				err := httpServer.Serve(listener)
+				if err != nil && err != http.ErrServerClosed {
+					log.Fatalf("Error from web server: %v\n", err)
+				}
+			}()
+		}
 	}
 
 	return nil
 }
 
+// stopServer shuts a server down when the context ends, within the drain
+// deadline. It once waited with no deadline at all, and a subscriber's stream,
+// which is never idle, held the process open until it was killed.
+func stopServer(sys *components.System, srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout(sys))
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Error during shutdown: %v", err)
+		srv.Close()
+	}
+}
+
+// EnsureServeMux returns the system's router, creating it on first call. Safe
+// for concurrent use, like EnsureCertReady: a system registering a path of its
+// own and SetoutServers may each be the first to need it.
+func EnsureServeMux(sys *components.System) *http.ServeMux {
+	sys.Mutex.Lock()
+	defer sys.Mutex.Unlock()
+	if sys.Husk.Mux == nil {
+		sys.Husk.Mux = http.NewServeMux()
+	}
+	return sys.Husk.Mux
+}
+
 // startHTTPSServer builds the TLS configuration from the system's now-ready
-// certificate and binds the HTTPS server on the given port. Called by
+// certificate and binds the HTTPS server on the given port and socket, either of
+// which may be absent. Called by
 // SetoutServers from a goroutine that waited on CertReady, so the cert is
 // guaranteed to be in place by the time we reach here.
-func startHTTPSServer(sys *components.System, httpsPort int) error {
+func startHTTPSServer(sys *components.System, httpsPort int, httpsSocket string) error {
 	privateKeyPEM, err := encodeECDSAPrivateKeyToPEM(sys.Husk.Pkey)
 	if err != nil {
 		return fmt.Errorf("encoding private key: %w", err)
@@ -164,38 +228,68 @@ func startHTTPSServer(sys *components.System, httpsPort int) error {
 	}
 
 	httpsServer := &http.Server{
-		Addr:         ":" + strconv.Itoa(httpsPort),
+		Addr:         net.JoinHostPort(sys.Husk.ListenHost, strconv.Itoa(httpsPort)),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 60 * time.Second,
 		TLSConfig:    tlsConfig,
-		Handler:      nil,
+		Handler:      EnsureServeMux(sys),
+		Protocols:    serverProtocols(sys, true),
 	}
 
-	// Graceful shutdown on context cancellation.
+	// Graceful shutdown on context cancellation, as for HTTP.
+	sys.Husk.Servers.Add(httpsServer)
 	go func() {
 		<-sys.Ctx.Done()
-		if err := httpsServer.Shutdown(context.Background()); err != nil {
-			log.Printf("Error during HTTPS shutdown: %v", err)
-		}
+		stopServer(sys, httpsServer)
 	}()
 
-	listener, err := net.Listen("tcp", httpsServer.Addr)
-	if err != nil {
-		return fmt.Errorf("binding the HTTPS port: %w", err)
+	// Both listeners are bound before either serves, so an error binding the
+	// second does not leave the first answering on its own.
+	var listeners []net.Listener
+	if httpsPort != 0 {
+		listener, err := net.Listen("tcp", httpsServer.Addr)
+		if err != nil {
+			return fmt.Errorf("binding the HTTPS port: %w", err)
+		}
+		listeners = append(listeners, listener)
 	}
+	if httpsSocket != "" {
+		listener, err := listenUnix(httpsSocket)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("binding the HTTPS socket: %w", err)
+		}
+		listeners = append(listeners, listener)
+	}
+
 	// Recorded only now. Everything before this point — the certificate
 	// request, the enrollment, the wait on CertReady — can take minutes, and for
 	// all of it this port refuses connections. Registering it as though it were
 	// serving is what sent consumers to a dead endpoint while the HTTP one
 	// beside it worked.
-	sys.Husk.Bound.Bind("https", httpsPort)
-	defer sys.Husk.Bound.Release("https")
+	if httpsPort != 0 {
+		sys.Husk.Bound.Bind("https", httpsPort)
+		defer sys.Husk.Bound.Release("https")
 
-	httpsURL := "https://" + sys.Husk.Host.IPAddresses[0] + ":" + strconv.Itoa(httpsPort) + "/" + sys.Name
-	log.Printf("The system %s is up with its web server available at %s\n", sys.Name, httpsURL)
+		httpsURL := "https://" + components.HostPort(servingAddress(sys), httpsPort) + "/" + sys.Name
+		log.Printf("The system %s is up with its web server available at %s\n", sys.Name, httpsURL)
+	}
+	if httpsSocket != "" {
+		sys.Husk.Bound.BindSocket("https", httpsSocket)
+		defer sys.Husk.Bound.ReleaseSocket("https")
+		log.Printf("The system %s is up with its web server available on the socket %s\n", sys.Name, httpsSocket)
+	}
 
-	if err := httpsServer.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("HTTPS server: %w", err)
+	served := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(l net.Listener) { served <- httpsServer.ServeTLS(l, "", "") }(listener)
+	}
+	for range listeners {
+		if err := <-served; err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("HTTPS server: %w", err)
+		}
 	}
 	return nil
 }
@@ -222,12 +316,35 @@ func createResourceHandler(sys *components.System) http.HandlerFunc {
 	}
 }
 
-// ResourceHandler break up the request in parts and finds out what is requested
-// as in http://192.168.1.4:8700/photographer/picam/files/image_20240325-211555.jpg
-// where photographer is part[1], picam is part[2](with len==3), files is part[3] (with len==4)
+// ResourceHandler runs a request through the system's middleware and the
+// authorization stage, and then dispatches it to what it asks for.
+//
+// The order is fixed: the peer is noted first, then the request is metered and
+// its caller's trace continued, then the limits are enforced, then the system's
+// own middleware runs in the order it was added, then authorization, then form
+// validation, then dispatch. See components.System.Use for why authorization
+// comes after all the middleware, and why only validation comes after it.
 func ResourceHandler(sys *components.System, w http.ResponseWriter, r *http.Request) {
 	logPeer(sys, r)
 
+	var chain http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dispatch(sys, w, r)
+	})
+	chain = validating(sys)(chain)
+	chain = authorizing(sys)(chain)
+	for i := len(sys.Middleware) - 1; i >= 0; i-- {
+		chain = sys.Middleware[i](chain)
+	}
+	chain = limiting(sys)(chain)
+	chain = tracing(sys)(chain)
+	chain = metering(sys)(chain)
+	chain.ServeHTTP(w, r)
+}
+
+// dispatch break up the request in parts and finds out what is requested
+// as in http://192.168.1.4:8700/photographer/picam/files/image_20240325-211555.jpg
+// where photographer is part[1], picam is part[2](with len==3), files is part[3] (with len==4)
+func dispatch(sys *components.System, w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.URL.Path, "/")
 
 	if len(parts) < 3 {
@@ -257,6 +374,81 @@ func ResourceHandler(sys *components.System, w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// authorizing is the authorization stage of the request chain: a request for a
+// service is served only if permitted says so, and everything else passes
+// through untouched.
+//
+// A stage of its own rather than a check inside each dispatch branch, so that
+// middleware added with System.Use has a defined place relative to it. It reads
+// the path the way dispatch does and asks about exactly the requests dispatch
+// would hand to a unit asset; what it lets through — the system-level
+// endpoints, an unknown asset, a malformed path — dispatch answers as before.
+func authorizing(sys *components.System) components.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !admitted(sys, w, r) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// admitted decides whether a request may reach a unit asset, writing the
+// refusal itself when it may not.
+func admitted(sys *components.System, w http.ResponseWriter, r *http.Request) bool {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 4 && len(parts) != 5 {
+		return true // a system-level endpoint, or a path dispatch refuses anyway
+	}
+	resourceName, servicePath := parts[2], parts[3]
+	resource, ok := sys.UAssets[resourceName]
+	if !ok {
+		return true // dispatch answers "Resource not found"
+	}
+	services := (*resource).GetServices()
+
+	if len(parts) == 4 {
+		if servicePath == "doc" {
+			return true
+		}
+		return permitted(sys, w, r, resourceName, services, servicePath)
+	}
+
+	// Files are a service's payload, so they are guarded like one. The check has
+	// to precede the transfer: TransferFile writes headers and body, and a
+	// refusal issued afterwards is a superfluous WriteHeader against a response
+	// that has already gone out.
+	//
+	// The guard needs something to call this request, and no unit asset
+	// registers a service whose subpath is "files" — the three systems that
+	// serve files handle the word inside their own dispatch. Guarding it against
+	// whatever findServiceByPath returned meant guarding it against a service
+	// with no definition, and a token can never name one of those: mismatch
+	// requires claimed == actual and claimed != "". So every file request in an
+	// authorized cloud was refused, permanently, by a check that was meant to
+	// let the authorized ones through.
+	//
+	// FileService is what such a request is called now. A policy can name it, a
+	// consumer can be granted it, and an asset that registers a service by that
+	// subpath — which makes it discoverable — is judged by its own record
+	// instead.
+	if servicePath == FileService {
+		serv := findServiceByPath(services, servicePath)
+		if serv == nil {
+			serv = &components.Service{Definition: FileService, SubPath: FileService}
+		}
+		return permittedAs(sys, w, r, resourceName, serv)
+	}
+
+	switch parts[4] {
+	case "doc", "subs", "cansel", "cost", "cfootprint":
+		return true // about the service rather than the service itself
+	default:
+		return permitted(sys, w, r, resourceName, services, servicePath)
+	}
+}
+
 // handleThreeParts handles a request with three parts
 func handleThreeParts(w http.ResponseWriter, r *http.Request, part string, sys *components.System) {
 	switch part {
@@ -272,6 +464,14 @@ func handleThreeParts(w http.ResponseWriter, r *http.Request, part string, sys *
 		forms.Certificate(w, r, *sys)
 	case "msg":
 		RegisterMessenger(w, r, sys)
+	case "health":
+		Liveness(w, r, sys)
+	case "ready":
+		Readiness(w, r, sys)
+	case "metrics":
+		Metering(w, r, sys)
+	case "forms":
+		FormSchemas(w, r, sys, "")
 	default:
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 	}
@@ -280,6 +480,11 @@ func handleThreeParts(w http.ResponseWriter, r *http.Request, part string, sys *
 // handleFourParts handles a request with four parts
 func handleFourParts(w http.ResponseWriter, r *http.Request, resourceName, servicePath string, sys *components.System) {
 	Resource, ok := sys.UAssets[resourceName]
+	if !ok && resourceName == "forms" {
+		// The schemas, unless an asset is called that: it was there first.
+		FormSchemas(w, r, sys, servicePath)
+		return
+	}
 	if !ok {
 		http.Error(w, "Resource not found", http.StatusNotFound)
 		return
@@ -292,9 +497,6 @@ func handleFourParts(w http.ResponseWriter, r *http.Request, resourceName, servi
 
 	default:
 		uAsset := *Resource
-		if !permitted(sys, w, r, resourceName, uAsset.GetServices(), servicePath) {
-			return
-		}
 		// The same resource in two representations: ask for the value and it is
 		// answered once, ask for a stream and it is answered now and again
 		// whenever it moves. On the service's own path rather than a /subscribe
@@ -313,11 +515,20 @@ func handleFourParts(w http.ResponseWriter, r *http.Request, resourceName, servi
 		// attempt, never rebuilt, and so never published anything to the triple
 		// store. Nothing in the cloud reported a fault, because from the outside
 		// there was only a connection that closed.
-		if serv := findServiceByPath(uAsset.GetServices(), servicePath); serv != nil &&
-			wantsStream(r) && serv.Stream != nil && serv.Stream.Subscribable() {
+		serv := findServiceByPath(uAsset.GetServices(), servicePath)
+		if serv != nil && wantsStream(r) && serv.Stream != nil && serv.Stream.Subscribable() {
 			serv.Stream.ServeStream(w, r)
 			return
 		}
+		// A range of readings is the same resource again, from the history
+		// the framework keeps. A service that keeps none answers the query
+		// itself, as it always has.
+		if serv != nil && wantsSeries(r) {
+			if publisher, ok := keepsHistory(serv); ok {
+				publisher.ServeSeries(w, r)
+				return
+			}
+		}
 		uAsset.Serving(w, r, servicePath)
 	}
 }
@@ -339,32 +550,8 @@ func handleFiveParts(w http.ResponseWriter, r *http.Request, resourceName, servi
 
 	uAsset := *Resource
 
-	// Files are a service's payload, so they are guarded like one. The check has
-	// to precede the transfer: TransferFile writes headers and body, and a
-	// refusal issued afterwards is a superfluous WriteHeader against a response
-	// that has already gone out.
-	//
-	// The guard needs something to call this request, and no unit asset
-	// registers a service whose subpath is "files" — the three systems that
-	// serve files handle the word inside their own dispatch. Guarding it against
-	// whatever findServiceByPath returned meant guarding it against a service
-	// with no definition, and a token can never name one of those: mismatch
-	// requires claimed == actual and claimed != "". So every file request in an
-	// authorized cloud was refused, permanently, by a check that was meant to
-	// let the authorized ones through.
-	//
-	// FileService is what such a request is called now. A policy can name it, a
-	// consumer can be granted it, and an asset that registers a service by that
-	// subpath — which makes it discoverable — is judged by its own record
-	// instead.
+	// Already authorized by the stage in front of dispatch; see admitted.
 	if servicePath == FileService {
-		serv := findServiceByPath(uAsset.GetServices(), servicePath)
-		if serv == nil {
-			serv = &components.Service{Definition: FileService, SubPath: FileService}
-		}
-		if !permittedAs(sys, w, r, resourceName, serv) {
-			return
-		}
 		forms.TransferFile(w, r)
 		return
 	}
@@ -394,9 +581,6 @@ func handleFiveParts(w http.ResponseWriter, r *http.Request, resourceName, servi
 			http.Error(w, "Service not found", http.StatusNotFound)
 		}
 	default:
-		if !permitted(sys, w, r, resourceName, uAsset.GetServices(), servicePath) {
-			return
-		}
 		uAsset.Serving(w, r, servicePath)
 	}
 }
@@ -404,10 +588,12 @@ func handleFiveParts(w http.ResponseWriter, r *http.Request, resourceName, servi
 // permitted refuses a request the authorizer has not sanctioned, writing the
 // refusal itself and reporting whether serving may continue.
 //
-// It guards service dispatch only. The system-level endpoints — /doc, /kgraph,
-// /smodel and above all /cert — stay open: a provider fetches the authorizer's
-// own certificate through /cert, so requiring a token to read one would leave the
-// cloud unable to bootstrap verification at all.
+// It guards service dispatch only, from the authorization stage. The
+// system-level endpoints — /doc, /kgraph, /smodel, the /health and /ready
+// probes, /metrics, the /forms schemas and above all /cert — stay open: a
+// provider fetches the authorizer's own certificate through /cert, so requiring
+// a token to read one would leave the cloud unable to bootstrap verification at
+// all.
 func permitted(sys *components.System, w http.ResponseWriter, r *http.Request, assetName string, services map[string]*components.Service, servicePath string) bool {
 	serv := findServiceByPath(services, servicePath)
 	if serv == nil {
@@ -431,6 +617,7 @@ func permitted(sys *components.System, w http.ResponseWriter, r *http.Request, a
 			// path problem stated plainly, which is what it is useful for: it
 			// sends the reader to the configuration rather than to the policy
 			// file.
+			countRefusal(sys, refusedUnknownService)
 			if _, identified := PeerCN(r); !identified {
 				http.Error(w, "the caller presented no verified certificate", http.StatusUnauthorized)
 				return false
@@ -445,7 +632,9 @@ func permitted(sys *components.System, w http.ResponseWriter, r *http.Request, a
 
 // permittedAs is permitted for a request whose service is already resolved.
 func permittedAs(sys *components.System, w http.ResponseWriter, r *http.Request, assetName string, serv *components.Service) bool {
-	status, err := AuthorizeRequest(sys, r, assetName, serv)
+	ctx, span := StartSpan(r.Context(), sys, "authorize", components.SpanInternal)
+	status, err := AuthorizeRequest(sys, r.WithContext(ctx), assetName, serv)
+	span.End(err)
 	if status == 0 {
 		return true
 	}
@@ -2,9 +2,13 @@ package usecases
 
 import (
 	"context"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/sdoque/mbaigo/components"
 )
@@ -53,3 +57,160 @@ func TestAskingToFollowAnUnfollowableServiceDoesNotPanic(t *testing.T) {
 		t.Error("the request never reached the unit asset, so a system cannot serve its own stream")
 	}
 }
+
+// Two systems in one process each answer on their own router.
+//
+// Both used to register on http.DefaultServeMux, so a gateway hosting several
+// systems shared one router between them — and two of the same name could not
+// be started at all, because the second registration of "/thermostat/" panics.
+func TestTwoSystemsOfTheSameNameServeSideBySide(t *testing.T) {
+	first, stopFirst := servingSystem(t, "thermostat", "first")
+	second, stopSecond := servingSystem(t, "thermostat", "second")
+	defer stopSecond()
+
+	if got := askFor(t, first); got != "first" {
+		t.Errorf("the first system answered %q", got)
+	}
+	if got := askFor(t, second); got != "second" {
+		t.Errorf("the second system answered %q", got)
+	}
+
+	// Torn down on its own: stopping one leaves the other serving.
+	stopFirst()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := http.Get(first); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the first system kept serving after it was stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := askFor(t, second); got != "second" {
+		t.Errorf("after the first stopped, the second answered %q", got)
+	}
+}
+
+// servingSystem starts a system whose one service answers with its label, and
+// returns the service's URL and what stops the system.
+func servingSystem(t *testing.T, name, label string) (string, func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	sys := components.NewSystem(name, ctx)
+	port := freePort(t)
+	sys.Husk = &components.Husk{
+		ProtoPort: map[string]int{"http": port},
+		Host:      &components.HostingDevice{IPAddresses: []string{"127.0.0.1"}},
+	}
+	sys.UAssets["asset"] = &components.UnitAsset{
+		Name:        "asset",
+		Mission:     components.MissionMeasurement,
+		ServicesMap: components.Services{"value": {Definition: "value", SubPath: "value"}},
+		ServingFunc: func(w http.ResponseWriter, r *http.Request, servicePath string) {
+			_, _ = w.Write([]byte(label))
+		},
+	}
+	if err := SetoutServers(&sys); err != nil {
+		cancel()
+		t.Fatalf("setting out the servers of %s: %v", label, err)
+	}
+	return "http://127.0.0.1:" + strconv.Itoa(port) + "/" + name + "/asset/value", cancel
+}
+
+// askFor reads a service's answer as text.
+func askFor(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("asking %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading %s: %v", url, err)
+	}
+	return string(body)
+}
+
+// freePort finds a port nothing is listening on.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("finding a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// Middleware runs in the order it was added, and authorization after all of it.
+func TestMiddlewareRunsInOrderAndAuthorizationLast(t *testing.T) {
+	sys := systemUnderTest(t, &components.CoreSystem{
+		Name: AuthorizerName, Url: "http://localhost:20104/authorizer/authorization",
+	})
+	served := false
+	sys.UAssets["sensor_Id"] = &components.UnitAsset{
+		Name:        "sensor_Id",
+		Mission:     components.MissionMeasurement,
+		ServicesMap: components.Services{"temperature": {Definition: "temperature", SubPath: "temperature"}},
+		ServingFunc: func(w http.ResponseWriter, r *http.Request, servicePath string) { served = true },
+	}
+
+	var order []string
+	var status int
+	sys.Use(
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, "first")
+				rec := &statusRecorder{ResponseWriter: w}
+				next.ServeHTTP(rec, r)
+				status = rec.status
+			})
+		},
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, "second")
+				next.ServeHTTP(w, r)
+			})
+		},
+	)
+
+	// No key yet, so the authorization stage refuses — and the middleware in
+	// front of it has seen the request and the refusal both.
+	w := httptest.NewRecorder()
+	ResourceHandler(sys, w, httptest.NewRequest(http.MethodGet, "/ds18b20/sensor_Id/temperature", nil))
+
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("middleware ran as %v; want [first second]", order)
+	}
+	if served {
+		t.Error("the service was served although authorization refused it")
+	}
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("the outer middleware saw status %d; want the refusal, %d", status, http.StatusServiceUnavailable)
+	}
+}
+
+// The system-level endpoints are not the authorization stage's to refuse.
+func TestMiddlewareWrapsTheSystemLevelEndpointsToo(t *testing.T) {
+	sys := systemUnderTest(t, &components.CoreSystem{
+		Name: AuthorizerName, Url: "http://localhost:20104/authorizer/authorization",
+	})
+	seen := 0
+	sys.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen++
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	w := httptest.NewRecorder()
+	ResourceHandler(sys, w, httptest.NewRequest(http.MethodGet, "/ds18b20/", nil))
+	if w.Code != http.StatusFound {
+		t.Errorf("the system root answered %d; want the redirect to /doc", w.Code)
+	}
+	if seen != 1 {
+		t.Errorf("middleware saw %d requests; want 1", seen)
+	}
+}
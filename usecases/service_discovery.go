@@ -20,12 +20,13 @@
 package usecases
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net/http"
-	"strconv"
 	"sync"
 
 	"github.com/sdoque/mbaigo/components"
@@ -117,6 +118,21 @@ func Search4Services(cer *components.Cervice, sys *components.System) (err error
 // "read", so a cervice that only ever writes got a read token and every PUT
 // through it was refused.
 func Search4ServicesAs(cer *components.Cervice, sys *components.System, action string) (err error) {
+	return Search4ServicesAsCtx(systemContext(sys), cer, sys, action)
+}
+
+// Search4ServicesAsCtx is Search4ServicesAs bounded by ctx, and within the
+// trace it carries.
+func Search4ServicesAsCtx(ctx context.Context, cer *components.Cervice, sys *components.System, action string) (err error) {
+	return search4ServicesAs(ctx, cer, sys, action)
+}
+
+// search4ServicesAs is Search4ServicesAs within the trace ctx carries.
+func search4ServicesAs(ctx context.Context, cer *components.Cervice, sys *components.System, action string) (err error) {
+	ctx, span := StartSpan(ctx, sys, "discover "+cer.Definition, components.SpanInternal)
+	span.SetAttribute("action", action)
+	defer func() { span.End(err) }()
+
 	// instantiate the service quest form
 	questForm := forms.ServiceQuest_v1{
 		SysId:             0,
@@ -142,7 +158,7 @@ func Search4ServicesAs(cer *components.Cervice, sys *components.System, action s
 	}
 	orURL = orURL + "/squest"
 	// Prepare the request to the orchestrator
-	resp, err := sendHTTPReq(http.MethodPost, orURL, qf)
+	resp, err := sendHTTPReqWithToken(ctx, http.MethodPost, orURL, "", qf)
 	if err != nil {
 		return err
 	}
@@ -163,6 +179,7 @@ func Search4ServicesAs(cer *components.Cervice, sys *components.System, action s
 		return fmt.Errorf("unable to unpack discovery request form")
 	}
 	recordNode(cer, df.ServNode, df.ServLocation, df.Details, action, df.Token, df.SubscribeAble)
+	routeLocally(sys, df.ServLocation, df.LocalSocket)
 	return nil
 }
 
@@ -180,10 +197,7 @@ func recordNode(cer *components.Cervice, node, url string, details map[string][]
 		if ni.URL != url {
 			continue
 		}
-		if ni.Tokens == nil {
-			ni.Tokens = make(map[string]string)
-		}
-		ni.Tokens[action] = token
+		ni.Tokens = withToken(ni.Tokens, action, token)
 		ni.Details = details
 		cer.Nodes[node][i] = ni
 		return
@@ -196,6 +210,24 @@ func recordNode(cer *components.Cervice, node, url string, details map[string][]
 	})
 }
 
+// withToken and withoutToken change a copy of a provider's tokens, never the map
+// itself: Providers hands out NodeInfo copies that share it, and their callers
+// read it without the cervice's lock.
+func withToken(tokens map[string]string, action, token string) map[string]string {
+	tokens = maps.Clone(tokens)
+	if tokens == nil {
+		tokens = make(map[string]string)
+	}
+	tokens[action] = token
+	return tokens
+}
+
+func withoutToken(tokens map[string]string, action string) map[string]string {
+	tokens = maps.Clone(tokens)
+	delete(tokens, action)
+	return tokens
+}
+
 func Search4MultipleServices(cer *components.Cervice, sys *components.System) (err error) {
 	return Search4MultipleServicesAs(cer, sys, ActionForMode(cer.Mode))
 }
@@ -203,6 +235,22 @@ func Search4MultipleServices(cer *components.Cervice, sys *components.System) (e
 // Search4MultipleServicesAs is Search4MultipleServices for one named action.
 // See Search4ServicesAs for why the action is not taken from Cervice.Mode.
 func Search4MultipleServicesAs(cer *components.Cervice, sys *components.System, action string) (err error) {
+	return Search4MultipleServicesAsCtx(systemContext(sys), cer, sys, action)
+}
+
+// Search4MultipleServicesAsCtx is Search4MultipleServicesAs bounded by ctx, and
+// within the trace it carries.
+func Search4MultipleServicesAsCtx(ctx context.Context, cer *components.Cervice, sys *components.System, action string) (err error) {
+	return search4MultipleServicesAs(ctx, cer, sys, action)
+}
+
+// search4MultipleServicesAs is Search4MultipleServicesAs within the trace ctx
+// carries.
+func search4MultipleServicesAs(ctx context.Context, cer *components.Cervice, sys *components.System, action string) (err error) {
+	ctx, span := StartSpan(ctx, sys, "discover all "+cer.Definition, components.SpanInternal)
+	span.SetAttribute("action", action)
+	defer func() { span.End(err) }()
+
 	questForm := forms.ServiceQuest_v1{
 		SysId:             0,
 		RequesterName:     sys.Name,
@@ -227,7 +275,7 @@ func Search4MultipleServicesAs(cer *components.Cervice, sys *components.System,
 	}
 	orURL = orURL + "/squests"
 	// Prepare the request to the orchestrator
-	resp, err := sendHTTPReq(http.MethodPost, orURL, qf)
+	resp, err := sendHTTPReqWithToken(ctx, http.MethodPost, orURL, "", qf)
 	if err != nil {
 		return err
 	}
@@ -256,6 +304,7 @@ func Search4MultipleServicesAs(cer *components.Cervice, sys *components.System,
 			continue
 		}
 		recordNode(cer, sp.ServNode, sp.ServLocation, sp.Details, action, sp.Token, sp.SubscribeAble)
+		routeLocally(sys, sp.ServLocation, sp.LocalSocket)
 		registered[sp.ServLocation] = true
 	}
 	// A discovery for several providers returns everything currently registered
@@ -318,8 +367,14 @@ var olderOrchestrator sync.Once
 //
 // So a provider loses only this action's token here. It is removed outright
 // when it has none left for any action, because then nothing discovered it at
-// all and it is no longer a provider of anything.
+// all and it is no longer a provider of anything, and its circuit breaker goes
+// with it: kept, it would outlive the provider for as long as the consumer ran,
+// one for every sensor that ever came and went.
 func pruneNodes(cer *components.Cervice, registered map[string]bool, action string) {
+	// Deferred first, so it runs after the unlock below: it takes the lock
+	// itself.
+	defer cer.PruneCircuits()
+
 	// Deleting during a range over the same map is what makes this the crash
 	// rather than the race: `fatal error: concurrent map iteration and map
 	// write` is not recoverable, and two polling goroutines of one unit asset
@@ -331,7 +386,7 @@ func pruneNodes(cer *components.Cervice, registered map[string]bool, action stri
 		kept := nodes[:0]
 		for _, ni := range nodes {
 			if !registered[ni.URL] {
-				delete(ni.Tokens, action)
+				ni.Tokens = withoutToken(ni.Tokens, action)
 				if len(ni.Tokens) == 0 {
 					continue // discovered for nothing: no longer a provider here
 				}
@@ -358,6 +413,10 @@ func ConvertToServicePoint(sr forms.ServiceRecord_v1) (sp forms.ServicePoint_v1)
 	sp.ServiceDefinition = rec.ServiceDefinition
 	sp.Details = rec.Details
 	proto, port := preferredProtoPort(rec.ProtoPort)
+	// A provider serving TLS on its socket alone has no port to prefer it by.
+	if port == 0 && rec.LocalSockets["https"] != "" {
+		proto = "https"
+	}
 	// A record with no address leaves nothing to build a URL from, and indexing
 	// it panicked the poll goroutine of every system consuming that definition.
 	// The address comes off the wire from the registrar, which copies whatever
@@ -369,9 +428,18 @@ func ConvertToServicePoint(sr forms.ServiceRecord_v1) (sp forms.ServicePoint_v1)
 			rec.ServiceDefinition, rec.SystemName)
 		return sp
 	}
-	sp.ServLocation = proto + "://" + rec.IPAddresses[0] + ":" + strconv.Itoa(port) + "/" + rec.SystemName + "/" + rec.SubPath
+	// A provider on its socket alone has no port to put in the URL, and every
+	// one of them at "ip:0" was one host to the client: one socket route, which
+	// the last discovered overwrote, and one connection pool.
+	if port == 0 && rec.LocalSockets[proto] != "" {
+		port = socketPort(rec.LocalSockets[proto])
+	}
+	// The provider put its preferred family first, and an IPv6 literal is
+	// bracketed so the port is not read as one more group of the address.
+	sp.ServLocation = proto + "://" + components.HostPort(rec.IPAddresses[0], port) + "/" + rec.SystemName + "/" + rec.SubPath
 	sp.ServNode = rec.ServiceNode
 	sp.SubscribeAble = rec.SubscribeAble
+	sp.LocalSocket = rec.LocalSockets[proto]
 	return
 }
 
@@ -17,13 +17,24 @@ package usecases
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/sdoque/mbaigo/components"
 )
 
+// DefaultDrainTimeout is how long an ordered shutdown waits for the registrar
+// and for requests in flight when the husk does not say. Under the ten seconds a
+// container runtime commonly allows between SIGTERM and SIGKILL, so that the
+// cleanups still get to run.
+const DefaultDrainTimeout = 8 * time.Second
+
 // WatchShutdown spawns a goroutine that waits for a shutdown signal on the
-// system's Sigs channel and cancels the supplied context when one arrives.
+// system's Sigs channel, shuts the system down in order, and cancels the
+// supplied context when that is done.
 //
 // This must be called *early* in main — immediately after NewSystem and before
 // any blocking call such as RequestCertificate's certificate-retry loop.
@@ -34,12 +45,129 @@ import (
 // un-killable except by SIGKILL or SIGQUIT.
 //
 // With this helper, the signal handler is wired up at startup and the
-// context is canceled the moment a signal arrives, regardless of what
-// main is currently doing.
+// context is canceled the moment the ordered shutdown has finished, regardless
+// of what main is currently doing. A main blocked on `<-sys.Ctx.Done()` thus
+// returns only once the services are deregistered and the cleanups have run.
+// A second signal does not wait for the drain: whoever presses Ctrl+C twice
+// means it.
 func WatchShutdown(sys *components.System, cancel context.CancelFunc) {
 	go func() {
 		sig := <-sys.Sigs
 		log.Printf("shutdown signal %s received for %s", sig, sys.Name)
+
+		done := make(chan struct{})
+		go func() {
+			Shutdown(sys)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case sig := <-sys.Sigs:
+			log.Printf("second signal %s received for %s, stopping without draining", sig, sys.Name)
+		}
 		cancel()
 	}()
 }
+
+// Shutdown stops the system in the order that leaves the rest of the cloud
+// least surprised:
+//
+//  1. Readiness fails and publishers turn new subscribers away, so nothing new
+//     starts that would have to be cut short.
+//  2. Every service is deregistered, and the registrar's answers are waited
+//     for, so no consumer is sent here once the system stops answering.
+//  3. Subscribers are sent a final "closing" event and their streams end.
+//  4. The servers stop accepting connections and the requests in flight are
+//     allowed to finish.
+//  5. The cleanups given to AtShutdown run, last added first.
+//
+// Steps 2 and 4 together wait no longer than the husk's DrainTimeout; past it,
+// whatever has not finished is abandoned and the connections are closed. The
+// cleanups are not bounded: a device left half released is worse than a late
+// exit.
+//
+// Shutdown does not cancel the system's context — WatchShutdown does, once this
+// returns — and calling it again does nothing.
+func Shutdown(sys *components.System) {
+	if sys.Husk != nil && sys.Husk.Stopping.Begin() {
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout(sys))
+		defer cancel()
+
+		publishers := publishersOf(sys)
+		for _, p := range publishers {
+			p.StopAccepting()
+		}
+
+		if err := sys.Husk.Stopping.Wait(ctx); err != nil {
+			log.Printf("%s: gave up waiting for the registrar: %v", sys.Name, err)
+		}
+
+		for _, p := range publishers {
+			p.Close()
+		}
+
+		drainServers(ctx, sys)
+	}
+	runCleanups(sys)
+}
+
+// drainTimeout is the husk's DrainTimeout, or the default.
+func drainTimeout(sys *components.System) time.Duration {
+	if sys.Husk == nil || sys.Husk.DrainTimeout <= 0 {
+		return DefaultDrainTimeout
+	}
+	return sys.Husk.DrainTimeout
+}
+
+// publishersOf lists the publishers of the system's followable services.
+func publishersOf(sys *components.System) []*Publisher {
+	var publishers []*Publisher
+	for _, ua := range sys.UAssets {
+		for _, serv := range (*ua).GetServices() {
+			if p, ok := serv.Stream.(*Publisher); ok {
+				publishers = append(publishers, p)
+			}
+		}
+	}
+	return publishers
+}
+
+// drainServers shuts every server down at once, so that one slow request does
+// not spend another server's share of the deadline. A server still busy when
+// ctx ends has its connections closed.
+func drainServers(ctx context.Context, sys *components.System) {
+	var wg sync.WaitGroup
+	for _, srv := range sys.Husk.Servers.All() {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				if errors.Is(err, context.DeadlineExceeded) {
+					log.Printf("%s: requests still in flight at the deadline were cut short", sys.Name)
+				} else {
+					log.Printf("Error during shutdown: %v", err)
+				}
+				srv.Close()
+			}
+		}(srv)
+	}
+	wg.Wait()
+}
+
+// runCleanups runs the cleanups given to AtShutdown, once. Taken from the
+// system under its lock, so a second call finds none, and run outside it, so a
+// cleanup may take the lock itself.
+func runCleanups(sys *components.System) {
+	if sys.Mutex != nil {
+		sys.Mutex.Lock()
+	}
+	cleanups := sys.Cleanups
+	sys.Cleanups = nil
+	if sys.Mutex != nil {
+		sys.Mutex.Unlock()
+	}
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
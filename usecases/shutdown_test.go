@@ -16,8 +16,13 @@
 package usecases
 
 import (
+	"bufio"
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -69,3 +74,91 @@ func TestWatchShutdownDoesNotCancelWithoutSignal(t *testing.T) {
 		// success — still live
 	}
 }
+
+// An ordered shutdown deregisters while the subscribers are still being served,
+// tells them it is closing, drains the servers and only then runs the cleanups.
+func TestShutdownRunsInOrder(t *testing.T) {
+	sys := components.NewSystem("thermometer", context.Background())
+	sys.Husk = &components.Husk{DrainTimeout: 2 * time.Second}
+	publisher := NewPublisher(temperature(0.5))
+	publisher.Sample(sample(21))
+	serv := temperature(0.5)
+	serv.Stream = publisher
+	sys.UAssets["sensor"] = &components.UnitAsset{Name: "sensor", ServicesMap: components.Services{"temp": serv}}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(publisher.ServeStream))
+	sys.Husk.Servers.Add(server.Config)
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+	if line, _ := stream.ReadString('\n'); !strings.HasPrefix(line, "event: terms") {
+		t.Fatalf("the stream opened with %q", line)
+	}
+
+	var mu sync.Mutex
+	var steps []string
+	step := func(s string) {
+		mu.Lock()
+		steps = append(steps, s)
+		mu.Unlock()
+	}
+
+	// Standing in for a registration loop: the registrar answers slowly.
+	release := sys.Husk.Stopping.Hold()
+	go func() {
+		defer release()
+		<-sys.Husk.Stopping.Signal()
+		time.Sleep(50 * time.Millisecond)
+		if !publisher.Refusing() || publisher.Subscribers() != 1 {
+			t.Errorf("when deregistering, refusing = %v with %d subscribers; want new ones refused and the old one served",
+				publisher.Refusing(), publisher.Subscribers())
+		}
+		step("deregistered")
+	}()
+	sys.AtShutdown(func() { step("first cleanup") }, func() { step("second cleanup") })
+
+	Shutdown(&sys)
+
+	rest := new(strings.Builder)
+	for line, err := stream.ReadString('\n'); err == nil; line, err = stream.ReadString('\n') {
+		rest.WriteString(line)
+	}
+	if !strings.Contains(rest.String(), "event: closing") {
+		t.Errorf("the subscriber was not told the stream was closing:\n%s", rest)
+	}
+	if got := strings.Join(steps, ", "); got != "deregistered, second cleanup, first cleanup" {
+		t.Errorf("steps = %s", got)
+	}
+	if code, h := probe(t, &sys, "ready"); code != http.StatusServiceUnavailable || h.Status != HealthStopping {
+		t.Errorf("ready while stopping = %d %q, want 503 %q", code, h.Status, HealthStopping)
+	}
+
+	Shutdown(&sys) // a second call runs nothing again
+	if len(steps) != 3 {
+		t.Errorf("a second Shutdown ran the cleanups again: %v", steps)
+	}
+}
+
+// A registrar that never answers does not keep the system from exiting.
+func TestShutdownGivesUpAtTheDeadline(t *testing.T) {
+	sys := components.NewSystem("thermometer", context.Background())
+	sys.Husk = &components.Husk{DrainTimeout: 100 * time.Millisecond}
+	sys.Husk.Stopping.Hold() // never released
+
+	cleaned := false
+	sys.AtShutdown(func() { cleaned = true })
+	start := time.Now()
+	Shutdown(&sys)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %s with a 100ms drain timeout", elapsed)
+	}
+	if !cleaned {
+		t.Error("the cleanups did not run after the deadline")
+	}
+}
@@ -30,7 +30,6 @@ import (
 	"log"
 	"net/http"
 	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/sdoque/mbaigo/components"
@@ -38,9 +37,13 @@ import (
 
 // SModeling writes a SysML v2 textual model of the system to the HTTP response.
 func SModeling(w http.ResponseWriter, req *http.Request, sys *components.System) {
+	contentType, _, ok := negotiated(w, req, "text/plain; charset=utf-8")
+	if !ok {
+		return
+	}
 	model := sysmlPackage(sys)
 
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Type", contentType)
 	_, err := w.Write([]byte(model))
 	if err != nil {
 		log.Println("Failed to write SModeling information:", err)
@@ -63,30 +66,59 @@ func sysmlPackage(sys *components.System) string {
 
 // sysmlPortDefs emits one 'port def' per unique service definition across all unit assets.
 // Each service definition becomes a named port type used by both providers and consumers.
+// A service whose "Forms" detail names a form with a value shape gets the value as the
+// port's attribute, and the attribute def it is typed by is emitted once ahead of the ports.
 // The port def name is derived from the service definition so that it is
 // distinct from the port usage name — SysML v2 requires def and usage to use
 // different identifiers. See portDefName for the naming rule.
 func sysmlPortDefs(sys *components.System) string {
 	seen := make(map[string]bool)
-	var out strings.Builder
-
-	out.WriteString("    // ── Port Definitions ─────────────────────────────────────────────────────\n")
+	var ports strings.Builder
+	used := make(map[string]valueShape)
 
+	portDef := func(def string, details map[string][]string) {
+		if seen[def] {
+			return
+		}
+		seen[def] = true
+		shape, ok := shapeOf(details)
+		if !ok {
+			ports.WriteString(fmt.Sprintf("    port def '%s';\n", portDefName(def)))
+			return
+		}
+		// What flows through the port, where the service says which form it is.
+		used[shape.attributes] = shape
+		ports.WriteString(fmt.Sprintf("    port def '%s' {\n        attribute value : %s;\n    }\n",
+			portDefName(def), shape.attributes))
+	}
 	for _, ua := range sys.UAssets {
 		for _, svc := range ua.GetServices() {
-			if !seen[svc.Definition] {
-				seen[svc.Definition] = true
-				out.WriteString(fmt.Sprintf("    port def '%s';\n", portDefName(svc.Definition)))
-			}
+			portDef(svc.Definition, svc.Details)
 		}
 		for _, cerv := range ua.GetCervices() {
-			if !seen[cerv.Definition] {
-				seen[cerv.Definition] = true
-				out.WriteString(fmt.Sprintf("    port def '%s';\n", portDefName(cerv.Definition)))
-			}
+			portDef(cerv.Definition, cerv.Details)
 		}
 	}
 
+	var out strings.Builder
+	if len(used) > 0 {
+		out.WriteString("    // ── Value Definitions ────────────────────────────────────────────────────\n")
+		names := make([]string, 0, len(used))
+		for name := range used {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			out.WriteString(fmt.Sprintf("    attribute def %s {\n", name))
+			for _, field := range used[name].fields {
+				out.WriteString(fmt.Sprintf("        attribute %s;\n", field))
+			}
+			out.WriteString("    }\n")
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString("    // ── Port Definitions ─────────────────────────────────────────────────────\n")
+	out.WriteString(ports.String())
 	out.WriteString("\n")
 	return out.String()
 }
@@ -186,7 +218,7 @@ func sysmlIBD(sys *components.System) string {
 
 	if len(sys.Husk.Host.IPAddresses) > 0 {
 		out.WriteString(fmt.Sprintf("        attribute ipAddress : String = \"%s\";\n",
-			sys.Husk.Host.IPAddresses[0]))
+			servingAddress(sys)))
 	}
 	for proto, port := range sys.Husk.ProtoPort {
 		if port != 0 {
@@ -201,8 +233,8 @@ func sysmlIBD(sys *components.System) string {
 					if port == 0 {
 						continue
 					}
-					url := proto + "://" + sys.Husk.Host.IPAddresses[0] + ":" +
-						strconv.Itoa(port) + "/" + sys.Name + "/" + assetName + "/" + svc.SubPath
+					url := proto + "://" + components.HostPort(servingAddress(sys), port) +
+						"/" + sys.Name + "/" + assetName + "/" + svc.SubPath
 					// Path format "<asset>.<definition>" lets the modeler resolve
 					// @connect URLs back to provider ports when building the
 					// LocalCloud IBD's connect statements.
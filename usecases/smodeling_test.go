@@ -169,6 +169,26 @@ func TestSysmlPortDefsDeduplicates(t *testing.T) {
 	}
 }
 
+// A port whose service names its form carries the value, typed by an
+// attribute def written once however many ports use it.
+func TestSysmlPortDefsCarryTheValue(t *testing.T) {
+	sys := newKGTestSystem()
+	ua := addTestAsset(sys)
+	ua.ServicesMap["temp"].Details["Forms"] = []string{"EnumState_v1"}
+	ua.CervicesMap["humidity"].Details["Forms"] = []string{"EnumState_v1"}
+
+	out := sysmlPortDefs(sys)
+	if !strings.Contains(out, "port def 'Temperature' {\n        attribute value : EnumeratedState;\n    }") {
+		t.Errorf("the port does not carry the state:\n%s", out)
+	}
+	if got := strings.Count(out, "attribute def EnumeratedState {"); got != 1 {
+		t.Errorf("EnumeratedState defined %d times, want 1:\n%s", got, out)
+	}
+	if strings.Index(out, "attribute def") > strings.Index(out, "port def") {
+		t.Errorf("the attribute def comes after the ports using it:\n%s", out)
+	}
+}
+
 // ── sysmlBlockDefs ────────────────────────────────────────────────────────────
 
 func TestSysmlBlockDefs(t *testing.T) {
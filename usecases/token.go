@@ -27,6 +27,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -42,6 +43,15 @@ import (
 // request and read by every provider.
 const TokenHeader = "X-Arrowhead-Token" //#nosec G101 -- a header name, not a credential
 
+// Why a token was rejected, for a refusal to be counted by. The messages are
+// the ones a consumer has always been sent.
+var (
+	errTokenMalformed  = errors.New("malformed token")
+	errTokenUnreadable = errors.New("unreadable token claims")
+	errTokenSignature  = errors.New("the token's signature is not the authorizer's")
+	errTokenExpired    = errors.New("the token expired")
+)
+
 // encoding is URL-safe and unpadded so a token survives a header, a query string
 // and a log line unchanged.
 var encoding = base64.RawURLEncoding
@@ -114,11 +124,11 @@ func VerifyToken(token string, pub *ecdsa.PublicKey, req TokenRequest, now time.
 
 	digest := sha256.Sum256(payload)
 	if !ecdsa.VerifyASN1(pub, digest[:], signature) {
-		return claims, fmt.Errorf("the token's signature is not the authorizer's")
+		return claims, errTokenSignature
 	}
 
 	if claims.Expired(now) {
-		return claims, fmt.Errorf("the token expired at %s", claims.Expires.Format(time.RFC3339))
+		return claims, fmt.Errorf("%w at %s", errTokenExpired, claims.Expires.Format(time.RFC3339))
 	}
 
 	// The subject is compared against the connection's certificate by the
@@ -147,19 +157,19 @@ func VerifyToken(token string, pub *ecdsa.PublicKey, req TokenRequest, now time.
 func splitToken(token string) (claims forms.AccessToken_v1, payload, signature []byte, err error) {
 	dot := strings.IndexByte(token, '.')
 	if token == "" || dot < 1 || dot == len(token)-1 {
-		return claims, nil, nil, fmt.Errorf("malformed token: expected claims.signature")
+		return claims, nil, nil, fmt.Errorf("%w: expected claims.signature", errTokenMalformed)
 	}
 
 	payload, err = encoding.DecodeString(token[:dot])
 	if err != nil {
-		return claims, nil, nil, fmt.Errorf("malformed token claims: %w", err)
+		return claims, nil, nil, fmt.Errorf("%w claims: %w", errTokenMalformed, err)
 	}
 	signature, err = encoding.DecodeString(token[dot+1:])
 	if err != nil {
-		return claims, nil, nil, fmt.Errorf("malformed token signature: %w", err)
+		return claims, nil, nil, fmt.Errorf("%w signature: %w", errTokenMalformed, err)
 	}
 	if err := json.Unmarshal(payload, &claims); err != nil {
-		return claims, nil, nil, fmt.Errorf("unreadable token claims: %w", err)
+		return claims, nil, nil, fmt.Errorf("%w: %w", errTokenUnreadable, err)
 	}
 	return claims, payload, signature, nil
 }
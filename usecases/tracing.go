@@ -0,0 +1,445 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// A GetState is not one request. It may ask the orchestrator for a provider,
+// which asks the authorizer for a token; then it asks the provider, which
+// verifies the token and answers in a unit that is converted on the way back.
+// When that is slow or fails, the question is where — and each system's log
+// holds only its own part of the answer.
+//
+// So each part is recorded as a span, and the spans are joined into one trace by
+// the W3C Trace Context header a consumer sends with every request and a
+// provider reads from it. The framework records spans whether or not anything
+// collects them; a system with no exporter still passes the trace along, so a
+// chain is not broken by one system in the middle of it that is not being
+// watched.
+
+// traceparentHeader is the W3C Trace Context header.
+const traceparentHeader = "traceparent"
+
+// Span is one piece of work in a trace. A nil span does nothing, so code that
+// records spans does not have to ask whether anything is collecting them.
+type Span struct {
+	data     components.SpanData
+	exporter components.SpanExporter
+	sampled  bool
+	ended    bool
+}
+
+type spanKey struct{}
+
+// StartSpan begins a span as a child of the one ctx carries, or as the first of
+// a new trace, and returns a context carrying it. The span goes to the system's
+// exporter; without a system, to its parent's.
+func StartSpan(ctx context.Context, sys *components.System, name, kind string) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s := &Span{
+		data:    components.SpanData{Name: name, Kind: kind, SpanID: newTraceID(8), Start: time.Now()},
+		sampled: true,
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.data.TraceID, s.data.ParentID = parent.data.TraceID, parent.data.SpanID
+		s.data.System, s.exporter, s.sampled = parent.data.System, parent.exporter, parent.sampled
+	} else {
+		s.data.TraceID = newTraceID(16)
+	}
+	if sys != nil {
+		s.data.System, s.exporter = sys.Name, sys.Traces
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttribute notes something about the work the span stands for.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]string)
+	}
+	s.data.Attributes[key] = value
+}
+
+// End closes the span, as failed if err is not nil, and exports it. Only the
+// first call counts.
+func (s *Span) End(err error) {
+	if s == nil || s.ended {
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	if err != nil {
+		s.data.Error = err.Error()
+	}
+	if s.exporter != nil && s.sampled {
+		s.exporter.ExportSpan(s.data)
+	}
+}
+
+// traceparent renders the span as the header a request made within it carries.
+func (s *Span) traceparent() string {
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + s.data.TraceID + "-" + s.data.SpanID + "-" + flags
+}
+
+// injectTrace puts the trace context ctx carries on an outgoing request.
+func injectTrace(ctx context.Context, req *http.Request) {
+	if s, ok := ctx.Value(spanKey{}).(*Span); ok {
+		req.Header.Set(traceparentHeader, s.traceparent())
+	}
+}
+
+// extractTrace returns the request's context, carrying the caller's span as the
+// parent of whatever is started from it. A missing or malformed header starts a
+// new trace, which is what the specification asks of a receiver.
+func extractTrace(r *http.Request) context.Context {
+	traceID, spanID, sampled, ok := parseTraceparent(r.Header.Get(traceparentHeader))
+	if !ok {
+		return r.Context()
+	}
+	caller := &Span{data: components.SpanData{TraceID: traceID, SpanID: spanID}, sampled: sampled}
+	return context.WithValue(r.Context(), spanKey{}, caller)
+}
+
+// parseTraceparent reads a traceparent header: version-traceid-parentid-flags,
+// in lower-case hex. A later version may append fields, and is read for the
+// four this one defines.
+func parseTraceparent(h string) (traceID, spanID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || !isHex(parts[0], 2) || parts[0] == "ff" {
+		return "", "", false, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false, false
+	}
+	traceID, spanID = parts[1], parts[2]
+	if !isHex(traceID, 32) || !isHex(spanID, 16) || !isHex(parts[3], 2) ||
+		strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return "", "", false, false
+	}
+	flags, _ := strconv.ParseUint(parts[3], 16, 8)
+	return traceID, spanID, flags&1 == 1, true
+}
+
+// isHex reports whether s is n lower-case hex digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// newTraceID returns n random bytes in hex, as a trace or span identifier.
+func newTraceID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b) // never fails, as of Go 1.24
+	return hex.EncodeToString(b)
+}
+
+// tracing is the stage of the request chain that continues the caller's trace,
+// just inside metering. Everything after it — the system's middleware,
+// authorization, the unit asset — runs within its span.
+func tracing(sys *components.System) components.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			labels := requestLabels(sys, r)
+			ctx, span := StartSpan(extractTrace(r), sys,
+				r.Method+" "+labels["asset"]+"/"+labels["service"], components.SpanServer)
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("url.path", ForLog(r.URL.Path))
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			span.SetAttribute("http.response.status_code", strconv.Itoa(rec.Status()))
+			var err error
+			if rec.Status() >= 500 {
+				err = fmt.Errorf("%d %s", rec.Status(), http.StatusText(rec.Status()))
+			}
+			span.End(err)
+		})
+	}
+}
+
+// normalizeTraced is NormalizeUnits recorded as a span of its own. An answer
+// that arrived as the caller's deadline passed is not converted: the caller has
+// stopped waiting for it, and gets ctx.Err() as for any other late call.
+func normalizeTraced(ctx context.Context, cer *components.Cervice, f forms.Form) (normalized forms.Form, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	_, span := StartSpan(ctx, nil, "normalize units", components.SpanInternal)
+	defer func() { span.End(err) }()
+	return NormalizeUnits(cer, f)
+}
+
+//-------------------------------------Exporters
+
+// NewJSONExporter writes each span as a line of JSON.
+func NewJSONExporter(w io.Writer) components.SpanExporter {
+	return &jsonExporter{w: w}
+}
+
+// NewFileExporter appends spans to a file as lines of JSON.
+func NewFileExporter(path string) (components.SpanExporter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //#nosec G304 -- the operator's own configuration names it
+	if err != nil {
+		return nil, fmt.Errorf("opening the trace file: %w", err)
+	}
+	return &jsonExporter{w: f}, nil
+}
+
+type jsonExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (j *jsonExporter) ExportSpan(s components.SpanData) {
+	line, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, _ = j.w.Write(append(line, '\n'))
+}
+
+// DefaultOTLPEndpoint is where an OpenTelemetry collector on the same host takes
+// traces over HTTP.
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// otlpBatch is how many spans are sent at once, at most.
+const otlpBatch = 256
+
+// NewOTLPExporter sends spans to an OpenTelemetry collector, as OTLP/HTTP with
+// JSON encoding, every couple of seconds until ctx is done.
+//
+// Spans are queued rather than sent as they end, because they end on the
+// request path. A full queue drops spans: a collector that has gone away must
+// not slow down the control loop being traced.
+func NewOTLPExporter(ctx context.Context, endpoint string) components.SpanExporter {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	o := &otlpExporter{
+		endpoint: endpoint,
+		spans:    make(chan components.SpanData, 4*otlpBatch),
+		// Its own client, not the framework's: the collector is not part of the
+		// cloud, is not reached over its mutual TLS, and its requests are not
+		// themselves to be traced.
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+	go o.run(ctx)
+	return o
+}
+
+type otlpExporter struct {
+	endpoint string
+	spans    chan components.SpanData
+	client   *http.Client
+	failing  bool
+}
+
+func (o *otlpExporter) ExportSpan(s components.SpanData) {
+	select {
+	case o.spans <- s:
+	default:
+	}
+}
+
+func (o *otlpExporter) run(ctx context.Context) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	var batch []components.SpanData
+	for {
+		select {
+		case s := <-o.spans:
+			batch = append(batch, s)
+			if len(batch) < otlpBatch {
+				continue
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			o.send(batch)
+			return
+		}
+		o.send(batch)
+		batch = nil
+	}
+}
+
+// send posts a batch, and says so in the log when the collector stops or starts
+// taking them rather than at every attempt.
+func (o *otlpExporter) send(batch []components.SpanData) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(otlpRequest(batch))
+	if err != nil {
+		return
+	}
+	resp, err := o.client.Post(o.endpoint, "application/json", bytes.NewReader(body))
+	if err == nil {
+		_ = resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			err = fmt.Errorf("%s", resp.Status)
+		}
+	}
+	if err != nil && !o.failing {
+		log.Printf("sending traces to %s: %v; spans are dropped until it answers\n", o.endpoint, err)
+	} else if err == nil && o.failing {
+		log.Printf("sending traces to %s again\n", o.endpoint)
+	}
+	o.failing = err != nil
+}
+
+// The OTLP/HTTP JSON encoding, as much of it as a span here fills in.
+type (
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 0 unset, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID      string          `json:"traceId"`
+		SpanID       string          `json:"spanId"`
+		ParentSpanID string          `json:"parentSpanId,omitempty"`
+		Name         string          `json:"name"`
+		Kind         int             `json:"kind"`
+		Start        string          `json:"startTimeUnixNano"`
+		End          string          `json:"endTimeUnixNano"`
+		Attributes   []otlpAttribute `json:"attributes,omitempty"`
+		Status       otlpStatus      `json:"status"`
+	}
+	otlpScopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpResourceSpans struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+)
+
+var otlpKinds = map[string]int{
+	components.SpanInternal: 1,
+	components.SpanServer:   2,
+	components.SpanClient:   3,
+}
+
+// otlpRequest arranges a batch by system, each system being an OpenTelemetry
+// service: a process hosting several systems sends them apart.
+func otlpRequest(batch []components.SpanData) otlpTraces {
+	var traces otlpTraces
+	bySystem := make(map[string]int)
+	for _, s := range batch {
+		i, seen := bySystem[s.System]
+		if !seen {
+			var rs otlpResourceSpans
+			rs.Resource.Attributes = []otlpAttribute{{Key: "service.name", Value: otlpValue{s.System}}}
+			rs.ScopeSpans = make([]otlpScopeSpans, 1)
+			rs.ScopeSpans[0].Scope.Name = "github.com/sdoque/mbaigo"
+			traces.ResourceSpans = append(traces.ResourceSpans, rs)
+			i = len(traces.ResourceSpans) - 1
+			bySystem[s.System] = i
+		}
+		span := otlpSpan{
+			TraceID:      s.TraceID,
+			SpanID:       s.SpanID,
+			ParentSpanID: s.ParentID,
+			Name:         s.Name,
+			Kind:         otlpKinds[s.Kind],
+			Start:        strconv.FormatInt(s.Start.UnixNano(), 10),
+			End:          strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		for key, value := range s.Attributes {
+			span.Attributes = append(span.Attributes, otlpAttribute{Key: key, Value: otlpValue{value}})
+		}
+		if s.Error != "" {
+			span.Status = otlpStatus{Code: 2, Message: s.Error}
+		}
+		scope := &traces.ResourceSpans[i].ScopeSpans[0]
+		scope.Spans = append(scope.Spans, span)
+	}
+	return traces
+}
+
+// TraceExporter reads the "tracing" setting of a configuration file: "stdout",
+// "file:<path>", "otlp" for a collector on this host, or "otlp:<url>".
+func TraceExporter(ctx context.Context, spec string) (components.SpanExporter, error) {
+	kind, target, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "stdout":
+		return NewJSONExporter(os.Stdout), nil
+	case "file":
+		if target == "" {
+			return nil, fmt.Errorf("tracing %q names no file", spec)
+		}
+		return NewFileExporter(target)
+	case "otlp":
+		if target == "" {
+			target = DefaultOTLPEndpoint
+		}
+		return NewOTLPExporter(ctx, target), nil
+	default:
+		return nil, fmt.Errorf("tracing %q is none of stdout, file:<path> or otlp[:<url>]", spec)
+	}
+}
@@ -0,0 +1,139 @@
+package usecases
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// recordedSpans is an exporter a test can read back.
+type recordedSpans struct {
+	mu    sync.Mutex
+	spans []components.SpanData
+}
+
+func (r *recordedSpans) ExportSpan(s components.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func (r *recordedSpans) named(prefix string) (components.SpanData, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.spans {
+		if strings.HasPrefix(s.Name, prefix) {
+			return s, true
+		}
+	}
+	return components.SpanData{}, false
+}
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		header  string
+		ok      bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-later", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-later", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+	for _, c := range cases {
+		_, _, sampled, ok := parseTraceparent(c.header)
+		if ok != c.ok || sampled != c.sampled {
+			t.Errorf("parseTraceparent(%q) = sampled %v, ok %v; want %v, %v", c.header, sampled, ok, c.sampled, c.ok)
+		}
+	}
+}
+
+// A consumer's request and the provider's handling of it are one trace: the
+// provider's server span is the child of the consumer's client span.
+func TestTraceCrossesFromConsumerToProvider(t *testing.T) {
+	providerSpans, consumerSpans := &recordedSpans{}, &recordedSpans{}
+
+	provider := components.NewSystem("ds18b20", context.Background())
+	provider.Husk = &components.Husk{}
+	provider.Traces = providerSpans
+	provider.UAssets["sensor_Id"] = &components.UnitAsset{
+		Name:        "sensor_Id",
+		ServicesMap: components.Services{"temperature": {Definition: "temperature", SubPath: "temperature"}},
+		ServingFunc: func(w http.ResponseWriter, r *http.Request, servicePath string) {
+			body, _ := Pack(sample(21), "application/json")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(body)
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ResourceHandler(&provider, w, r)
+	}))
+	defer server.Close()
+
+	consumer := components.NewSystem("thermostat", context.Background())
+	consumer.Husk = &components.Husk{}
+	consumer.Traces = consumerSpans
+	cer := &components.Cervice{
+		Definition: "temperature",
+		Nodes: map[string][]components.NodeInfo{"sensor": {{
+			URL:    server.URL + "/ds18b20/sensor_Id/temperature",
+			Tokens: map[string]string{"read": ""},
+		}}},
+	}
+
+	f, err := stateHandler(context.Background(), http.MethodGet, cer, &consumer, nil)
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if got := f.(*forms.SignalA_v1a).Value; got != 21 {
+		t.Fatalf("read %v, want 21", got)
+	}
+
+	root, ok := consumerSpans.named("GetState temperature")
+	if !ok {
+		t.Fatalf("no GetState span among %+v", consumerSpans.spans)
+	}
+	client, ok := consumerSpans.named("HTTP GET")
+	if !ok || client.ParentID != root.SpanID || client.Kind != components.SpanClient {
+		t.Fatalf("client span %+v is not a child of %+v", client, root)
+	}
+	if _, ok := consumerSpans.named("normalize units"); !ok {
+		t.Error("unit normalization was not recorded")
+	}
+
+	served, ok := providerSpans.named("GET sensor_Id/temperature")
+	if !ok {
+		t.Fatalf("no server span among %+v", providerSpans.spans)
+	}
+	if served.TraceID != root.TraceID || served.ParentID != client.SpanID {
+		t.Errorf("the provider's span (trace %s, parent %s) did not continue the consumer's (trace %s, span %s)",
+			served.TraceID, served.ParentID, root.TraceID, client.SpanID)
+	}
+	if served.System != "ds18b20" || served.Attributes["http.response.status_code"] != "200" {
+		t.Errorf("server span = %+v", served)
+	}
+}
+
+func TestTraceExporterSpecs(t *testing.T) {
+	for _, spec := range []string{"stdout", "otlp", "otlp:http://localhost:4318/v1/traces", "file:" + t.TempDir() + "/spans.jsonl"} {
+		if _, err := TraceExporter(context.Background(), spec); err != nil {
+			t.Errorf("TraceExporter(%q): %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"jaeger", "file:"} {
+		if _, err := TraceExporter(context.Background(), spec); err == nil {
+			t.Errorf("TraceExporter(%q) was accepted", spec)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+// Typed consumption and provision: the forms a system works with, as the types
+// it works with, rather than as forms.Form to be asserted at every call.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// FormMismatchError is the error for a payload that is a form, and readable, but
+// not the one the caller works with and not one that migrates to it.
+type FormMismatchError struct {
+	Want string // the version the caller works with
+	Got  string // the version that arrived
+}
+
+func (e *FormMismatchError) Error() string {
+	return fmt.Sprintf("form is %s, not %s", e.Got, e.Want)
+}
+
+// GetStateAs is GetState for a caller that knows which form it reads:
+//
+//	temperature, err := usecases.GetStateAs[*forms.SignalA_v1a](cer, sys)
+//
+// Discovery, unpacking and unit conversion are GetState's. A provider that sends
+// another version of the form has it migrated (forms.Migrate); one that sends a
+// different form altogether is a *FormMismatchError naming both.
+func GetStateAs[T forms.Form](cer *components.Cervice, sys *components.System) (T, error) {
+	return GetStateAsCtx[T](systemContext(sys), cer, sys)
+}
+
+// GetStateAsCtx is GetStateAs bounded by ctx; see GetStateCtx.
+func GetStateAsCtx[T forms.Form](ctx context.Context, cer *components.Cervice, sys *components.System) (T, error) {
+	f, err := GetStateCtx(ctx, cer, sys)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return formAs[T](f)
+}
+
+// SetStateFrom is SetState with the form rather than the bytes, and the
+// provider's answer read as the same form.
+func SetStateFrom[T forms.Form](cer *components.Cervice, sys *components.System, f T) (T, error) {
+	return SetStateFromCtx(systemContext(sys), cer, sys, f)
+}
+
+// SetStateFromCtx is SetStateFrom bounded by ctx; see SetStateCtx.
+func SetStateFromCtx[T forms.Form](ctx context.Context, cer *components.Cervice, sys *components.System, f T) (T, error) {
+	var zero T
+	body, err := Pack(f, "application/json") // what SetState says it sends
+	if err != nil {
+		return zero, err
+	}
+	answer, err := SetStateCtx(ctx, cer, sys, body)
+	if err != nil {
+		return zero, err
+	}
+	return formAs[T](answer)
+}
+
+// DecodeRequest reads a request's body as the form a service takes:
+//
+//	setpoint, err := usecases.DecodeRequest[*forms.SignalA_v1a](w, r)
+//
+// It is HTTPProcessSetRequest for any form: the body limit and, in a system
+// that validates, the validation report are the same, and a payload of another
+// version is migrated. The caller answers any other error.
+func DecodeRequest[T forms.Form](w http.ResponseWriter, req *http.Request) (T, error) {
+	f, err := decodeRequest(w, req)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return formAs[T](f)
+}
+
+// formAs is f as a T, migrated to T's version first where it is another one.
+func formAs[T forms.Form](f forms.Form) (T, error) {
+	if typed, ok := f.(T); ok {
+		return typed, nil
+	}
+	var zero T
+	want := formVersionOf[T]()
+	if want == "" || f == nil {
+		return zero, &FormMismatchError{Want: reflect.TypeFor[T]().String(), Got: versionOrNothing(f)}
+	}
+	migrated, err := forms.Migrate(f, want)
+	if errors.Is(err, forms.ErrNoMigration) {
+		return zero, &FormMismatchError{Want: want, Got: f.FormVersion()}
+	}
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := migrated.(T)
+	if !ok {
+		// A migration registered to the version of one type that produced
+		// another.
+		return zero, fmt.Errorf("migrating %s to %s produced %T", f.FormVersion(), want, migrated)
+	}
+	return typed, nil
+}
+
+// formVersionOf is the version a new T says it is; empty where T is not a
+// pointer to a form, forms.Form itself for one, which names no version.
+func formVersionOf[T forms.Form]() string {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return ""
+	}
+	f, ok := reflect.New(t.Elem()).Interface().(forms.Form)
+	if !ok {
+		return ""
+	}
+	return f.NewForm().FormVersion()
+}
+
+func versionOrNothing(f forms.Form) string {
+	if f == nil {
+		return "nothing"
+	}
+	return f.FormVersion()
+}
@@ -0,0 +1,76 @@
+package usecases
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// A provider's answer arrives as the type asked for, or as an error naming both
+// forms.
+func TestGetStateAs(t *testing.T) {
+	sys := createTestSystem(false)
+	newMockTransport(t, createWorkingHttpResp(), 0, nil)
+	signal, err := GetStateAs[*forms.SignalA_v1a](newTestCerviceWithNodes(), &sys)
+	if err != nil || signal.Version != "SignalA_v1.0" {
+		t.Fatalf("got %+v, %v", signal, err)
+	}
+
+	newMockTransport(t, createWorkingHttpResp(), 0, nil)
+	_, err = GetStateAs[*forms.SignalB_v1a](newTestCerviceWithNodes(), &sys)
+	var mismatch *FormMismatchError
+	if !errors.As(err, &mismatch) || *mismatch != (FormMismatchError{Want: "SignalB_v1.0", Got: "SignalA_v1.0"}) {
+		t.Errorf("a SignalA read as a SignalB: %v", err)
+	}
+
+	newMockTransport(t, createWorkingHttpResp(), 0, nil)
+	f, err := GetStateAs[forms.Form](newTestCerviceWithNodes(), &sys)
+	if _, ok := f.(*forms.SignalA_v1a); !ok || err != nil {
+		t.Errorf("as any form: %v, %v", f, err)
+	}
+}
+
+func TestSetStateFrom(t *testing.T) {
+	sys := createTestSystem(false)
+	newMockTransport(t, createWorkingHttpResp(), 0, nil)
+	var setpoint forms.SignalA_v1a
+	setpoint.NewForm()
+	answer, err := SetStateFrom(newTestCerviceWithNodes(), &sys, &setpoint)
+	if err != nil || answer.Version != "SignalA_v1.0" {
+		t.Errorf("got %+v, %v", answer, err)
+	}
+}
+
+// A service reads its own form, an older version of it migrated, and is told
+// precisely when it was sent something else.
+func TestDecodeRequest(t *testing.T) {
+	put := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "/thermostat/controller/reading", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+	w := httptest.NewRecorder()
+
+	b, err := DecodeRequest[*forms.SignalB_v1a](w, put(`{"value": true, "timestamp": "2026-10-16T06:00:00Z", "version": "SignalB_v1.0"}`))
+	if err != nil || !b.Value {
+		t.Errorf("got %+v, %v", b, err)
+	}
+
+	r, err := DecodeRequest[*reading2](w, put(`{"value": 21.5, "version": "Reading_v1"}`))
+	if err != nil || *r != (reading2{Value: 21.5, Unit: "degC", Version: "Reading_v2"}) {
+		t.Errorf("an older version: %+v, %v", r, err)
+	}
+
+	_, err = DecodeRequest[*forms.SignalB_v1a](w, put(`{"value": 21.5, "version": "Reading_v1"}`))
+	var mismatch *FormMismatchError
+	if !errors.As(err, &mismatch) || err.Error() != "form is Reading_v1, not SignalB_v1.0" {
+		t.Errorf("another form: %v", err)
+	}
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("answered %d %s; the caller answers", w.Code, w.Body.String())
+	}
+}
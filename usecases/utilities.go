@@ -21,30 +21,62 @@ package usecases
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/sdoque/mbaigo/components"
 	"github.com/sdoque/mbaigo/forms"
 )
 
 // Pack serializes a form to a byte array for payload shipment with serialization format (sf) request
+//
+// A version parameter on the content type — "application/json;
+// version=SignalA_v1.0" — asks for the form in that version: it is migrated
+// there first (forms.Migrate), and an error wrapping forms.ErrNoMigration says
+// it cannot be.
 func Pack(f forms.Form, contentType string) (data []byte, err error) {
-	switch contentType {
+	mediaType, params, parseErr := mime.ParseMediaType(contentType)
+	if parseErr != nil {
+		mediaType = contentType // as before: an unreadable type is sent as JSON
+	}
+	if version := params["version"]; version != "" {
+		if f, err = forms.Migrate(f, version); err != nil {
+			return nil, err
+		}
+	}
+	switch mediaType {
 	case "application/xml":
 		data, err = xml.MarshalIndent(f, "", "  ")
 		if err != nil {
 			err = fmt.Errorf("error encoding XML: %w", err)
 			return
 		}
+	case ContentTypeCBOR:
+		data, err = packCBOR(f)
+		if err != nil {
+			err = fmt.Errorf("error encoding CBOR: %w", err)
+			return
+		}
+	case ContentTypeSenMLJSON, ContentTypeSenMLCBOR:
+		// Named for its form, for want of anything better here; a provider's
+		// answer is named for its resource (HTTPProcessGetRequest).
+		data, err = packSenML(f, f.FormVersion(), mediaType)
+		if err != nil {
+			err = fmt.Errorf("error encoding SenML: %w", err)
+			return
+		}
 	default:
 		data, err = json.MarshalIndent(f, "", "  ")
 		if err != nil {
@@ -76,6 +108,11 @@ func Unpack(data []byte, contentType string) (forms.Form, error) {
 		}
 	}
 
+	// A SenML pack has no version key: the form is decided by its values.
+	if isSenML(contentType) {
+		return unpackSenML(data, contentType)
+	}
+
 	// Unmarshal to get the form version
 	switch {
 	case strings.Contains(contentType, "application/json"):
@@ -86,6 +123,17 @@ func Unpack(data []byte, contentType string) (forms.Form, error) {
 		if err := xml.Unmarshal(data, &rawData); err != nil {
 			return nil, fmt.Errorf("error unmarshalling XML: %w", err)
 		}
+	case strings.Contains(contentType, ContentTypeCBOR):
+		// Decoded into the JSON it stands for, and unmarshalled as JSON from
+		// here on: see cbor.go.
+		asJSON, tree, err := unpackCBOR(data)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling CBOR: %w", err)
+		}
+		if rawData, _ = tree.(map[string]interface{}); rawData == nil {
+			return nil, fmt.Errorf("CBOR payload is not a map")
+		}
+		data, contentType = asJSON, "application/json"
 	default:
 		return nil, fmt.Errorf("unsupported content type")
 	}
@@ -120,6 +168,30 @@ func Unpack(data []byte, contentType string) (forms.Form, error) {
 	return formInstance, nil
 }
 
+// UnpackAs is Unpack for a reader that works in one version of the form: a
+// payload of another version, older or newer, is migrated to it. An empty
+// version takes the payload as it comes.
+func UnpackAs(data []byte, contentType, version string) (forms.Form, error) {
+	f, err := Unpack(data, contentType)
+	if err != nil || version == "" {
+		return f, err
+	}
+	return forms.Migrate(f, version)
+}
+
+// acceptKey carries the Accept header a request is to send, for the callers of
+// sendHTTPReqWithToken that ask for a version of a form.
+type acceptKey struct{}
+
+// acceptingVersion has the request sent with ctx ask for version of the form,
+// in JSON; an empty version asks for nothing and the provider sends its own.
+func acceptingVersion(ctx context.Context, version string) context.Context {
+	if version == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, acceptKey{}, mime.FormatMediaType("application/json", map[string]string{"version": version}))
+}
+
 // ------- Naming Conventions Tools -------
 
 // ToCamel converts PascalCase to camelCase.
@@ -211,7 +283,11 @@ func init() {
 	} else {
 		transport = transport.Clone()
 	}
+	transport.DialContext = localFirst(transport.DialContext)
 	transport.DialTLSContext = dialTLS
+	// Plaintext HTTP/2, to the providers that speak it; see http2.go. Over TLS
+	// the transport needs nothing more than the h2 the dial offers.
+	transport.RegisterProtocol("http", newH2CRoundTripper(transport))
 
 	http.DefaultClient = &http.Client{
 		Timeout:   time.Second * 30,
@@ -222,26 +298,47 @@ func init() {
 const userAgent string = "mbaigo"
 
 func sendHTTPReq(method string, url string, data []byte) (*http.Response, error) {
-	return sendHTTPReqWithToken(method, url, "", data)
+	return sendHTTPReqWithToken(context.Background(), method, url, "", data)
 }
 
 // sendHTTPReqWithToken is sendHTTPReq with an access token attached. The token
 // is what proves to the provider that the authorizer permitted this specific
 // call; without it a provider in an authorized cloud refuses.
-func sendHTTPReqWithToken(method string, url string, token string, data []byte) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(data))
+//
+// The request is a span of the trace ctx carries, and carries it on to the
+// provider in its traceparent header.
+//
+// A request that fails is a *ProviderError, classified by the status the
+// provider answered or by its not answering; one the caller canceled, or ran out
+// of time for, is ctx's error and not the provider's.
+func sendHTTPReqWithToken(ctx context.Context, method string, url string, token string, data []byte) (resp *http.Response, err error) {
+	ctx, span := StartSpan(ctx, nil, "HTTP "+method, components.SpanClient)
+	span.SetAttribute("http.request.method", method)
+	span.SetAttribute("url.full", url)
+	defer func() { span.End(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(data))
 	if err != nil {
-		return nil, err
+		return nil, &ProviderError{URL: url, Class: ProviderUnreachable, Err: err}
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", userAgent)
+	if accept, ok := ctx.Value(acceptKey{}).(string); ok {
+		req.Header.Set("Accept", accept)
+	}
 	if token != "" {
 		req.Header.Set(TokenHeader, token)
 	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	injectTrace(ctx, req)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil && ctx.Err() != nil {
+		// The caller stopped waiting, which says nothing about the provider.
 		return nil, err
 	}
+	if err != nil {
+		return nil, &ProviderError{URL: url, Class: ProviderUnreachable, Err: err}
+	}
+	span.SetAttribute("http.response.status_code", strconv.Itoa(resp.StatusCode))
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		// The body carries the reason — "the token expired at ...", "mismatch
 		// (action): read vs write" — and returning without it left the operator
@@ -255,10 +352,12 @@ func sendHTTPReqWithToken(method string, url string, token string, data []byte)
 		// into a log line, and a remote peer that can put newlines in its own
 		// refusal can forge entries around it — the same reason paths and
 		// common names go through ForLog.
+		failed := &ProviderError{URL: url, Status: resp.StatusCode, Class: statusClass(resp.StatusCode),
+			Err: fmt.Errorf("bad response: %s", resp.Status), RetryAfter: retryAfterHeader(resp.Header.Get("Retry-After"))}
 		if detail := strings.TrimSpace(ForLog(string(reason))); detail != "" {
-			return nil, fmt.Errorf("%s: %s", resp.Status, detail)
+			failed.Err = fmt.Errorf("%s: %s", resp.Status, detail)
 		}
-		return nil, fmt.Errorf("bad response: %s", resp.Status)
+		return nil, failed
 	}
 	return resp, nil
 }
@@ -0,0 +1,506 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Synecdoque
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, subject to the following conditions:
+ *
+ * The software is licensed under the MIT License. See the LICENSE file in this repository for details.
+ *
+ * Contributors:
+ *   Jan A. van Deventer, Luleå - initial implementation
+ *   Thomas Hedeler, Hamburg - initial implementation
+ ***************************************************************************SDG*/
+
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// Validating a form against its schema.
+//
+// Unpack takes whatever decodes. A SignalA_v1a without a timestamp arrives as
+// one taken at the beginning of the first millennium, a ServiceQuest_v1 without
+// a definition asks for nothing in particular, and a field misspelt by the
+// sender is dropped without a word — each a form that looks entirely reasonable
+// from the inside and is wrong.
+//
+// Validate holds a payload to the schema FormSchema generates for its version,
+// so what is checked is what a client team was given: every field the form
+// always carries is there, nothing is there the form does not have, and each
+// value has the type, the enum, the format and the bounds its schema says. Every
+// problem is reported, each with the JSON Pointer (RFC 6901) of the value it is
+// about, so the sender can mend them all at once.
+//
+// It is optional, because it refuses what was accepted before: "validateForms":
+// true in the configuration has HTTPProcessSetRequest validate what it is sent
+// and answer a payload that fails with 400 and the list of problems. UnpackValidated
+// is the same check for a caller unpacking on its own.
+
+// ContentTypeProblem is the media type of an RFC 9457 problem report.
+const ContentTypeProblem = "application/problem+json"
+
+// FieldProblem is one thing wrong with a payload.
+type FieldProblem struct {
+	// Path is the JSON Pointer of the offending value: "/record/definition",
+	// or "" for the payload as a whole.
+	Path    string `json:"path"`
+	Problem string `json:"problem"`
+}
+
+// ValidationError is what Validate returns for a payload that does not match
+// its form's schema.
+type ValidationError struct {
+	Form     string         `json:"form,omitempty"`
+	Problems []FieldProblem `json:"problems"`
+}
+
+func (e *ValidationError) Error() string {
+	described := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		described[i] = p.Path + ": " + p.Problem
+	}
+	form := e.Form
+	if form == "" {
+		form = "payload"
+	}
+	return form + " is invalid: " + strings.Join(described, "; ")
+}
+
+// Validate checks a payload against the schema of the form it names. It
+// returns a *ValidationError for a payload that does not match, and another
+// error for one that cannot be read at all.
+//
+// JSON and CBOR are checked as sent. XML is checked as the form it decodes to,
+// which holds every field whether it was sent or not, so a missing XML element
+// is not reported; its values are checked all the same. SenML is not a form on
+// the wire and is not checked.
+func Validate(data []byte, contentType string) error {
+	if isSenML(contentType) {
+		return nil
+	}
+	if strings.Contains(contentType, "text/plain") {
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+			contentType = "application/xml"
+		} else {
+			contentType = "application/json"
+		}
+	}
+	switch {
+	case strings.Contains(contentType, ContentTypeCBOR):
+		asJSON, _, err := unpackCBOR(data)
+		if err != nil {
+			return fmt.Errorf("error unmarshalling CBOR: %w", err)
+		}
+		data = asJSON
+	case strings.Contains(contentType, "application/xml"):
+		f, err := Unpack(data, contentType)
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(f); err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // so that 1.5 can be told from 1, where an integer is wanted
+	var payload any
+	if err := dec.Decode(&payload); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	object, isObject := payload.(map[string]any)
+	if !isObject {
+		return &ValidationError{Problems: []FieldProblem{{Path: "", Problem: "must be an object"}}}
+	}
+	version, _ := object["version"].(string)
+	if version == "" {
+		return &ValidationError{Problems: []FieldProblem{{Path: "/version", Problem: "is required, and names the form"}}}
+	}
+	schema, err := FormSchema(version)
+	if err != nil {
+		return &ValidationError{Problems: []FieldProblem{{Path: "/version", Problem: "names no form this system knows"}}}
+	}
+
+	v := validator{defs: map[string]any{}}
+	if defs, ok := schema["$defs"].(map[string]any); ok {
+		v.defs = defs
+	}
+	v.check(schema, payload, "")
+	if len(v.problems) > 0 {
+		return &ValidationError{Form: version, Problems: v.problems}
+	}
+	return nil
+}
+
+// UnpackValidated is Unpack after Validate.
+func UnpackValidated(data []byte, contentType string) (forms.Form, error) {
+	if err := Validate(data, contentType); err != nil {
+		return nil, err
+	}
+	return Unpack(data, contentType)
+}
+
+// validator checks a payload against the subset of JSON Schema that FormSchema
+// writes. It is not a general validator and does not pretend to be one: a
+// keyword FormSchema never writes is not read.
+type validator struct {
+	defs     map[string]any
+	problems []FieldProblem
+}
+
+func (v *validator) report(path, problem string, args ...any) {
+	v.problems = append(v.problems, FieldProblem{Path: path, Problem: fmt.Sprintf(problem, args...)})
+}
+
+func (v *validator) check(schema map[string]any, value any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, _ := v.defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		if def == nil {
+			v.report(path, "is described by %s, which the schema does not define", ref)
+			return
+		}
+		v.check(def, value, path)
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		v.checkAnyOf(anyOf, value, path)
+	}
+	if kinds, ok := schema["type"]; ok && !v.checkType(kinds, value, path) {
+		return // the rest is about a value of the right type
+	}
+	if want, ok := schema["const"]; ok && value != want {
+		v.report(path, "must be %q", want)
+	}
+	if enum, ok := schema["enum"].([]any); ok && !inEnum(enum, value) {
+		v.report(path, "must be one of %s", quoteAll(enum))
+	}
+
+	switch value := value.(type) {
+	case string:
+		v.checkString(schema, value, path)
+	case json.Number:
+		v.checkNumber(schema, value, path)
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				v.check(items, item, path+"/"+strconv.Itoa(i))
+			}
+		}
+		if n, ok := schemaNumber(schema["minItems"]); ok && float64(len(value)) < n {
+			v.report(path, "must hold at least %g items", n)
+		}
+		if n, ok := schemaNumber(schema["maxItems"]); ok && float64(len(value)) > n {
+			v.report(path, "must hold at most %g items", n)
+		}
+	case map[string]any:
+		v.checkObject(schema, value, path)
+	}
+}
+
+// checkAnyOf passes a value matching any of the schemas, and otherwise reports
+// what the first had against it: FormSchema writes anyOf only for "this, or
+// null", and the first is the one the sender meant.
+func (v *validator) checkAnyOf(schemas []any, value any, path string) {
+	var first []FieldProblem
+	for i, s := range schemas {
+		branch := validator{defs: v.defs}
+		sub, _ := s.(map[string]any)
+		branch.check(sub, value, path)
+		if len(branch.problems) == 0 {
+			return
+		}
+		if i == 0 {
+			first = branch.problems
+		}
+	}
+	v.problems = append(v.problems, first...)
+}
+
+// checkType reports whether value is of one of the JSON types kinds names.
+func (v *validator) checkType(kinds any, value any, path string) bool {
+	var names []string
+	switch k := kinds.(type) {
+	case string:
+		names = []string{k}
+	case []any:
+		for _, name := range k {
+			names = append(names, fmt.Sprint(name))
+		}
+	}
+	for _, name := range names {
+		if isJSONType(name, value) {
+			return true
+		}
+	}
+	v.report(path, "must be %s, not %s", strings.Join(names, " or "), jsonTypeOf(value))
+	return false
+}
+
+func (v *validator) checkString(schema map[string]any, s, path string) {
+	if n, ok := schemaNumber(schema["minLength"]); ok && float64(utf8.RuneCountInString(s)) < n {
+		if n == 1 {
+			v.report(path, "must not be empty")
+		} else {
+			v.report(path, "must be at least %g characters", n)
+		}
+	}
+	if schema["format"] == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			v.report(path, "must be an RFC 3339 date and time, not %q", s)
+		}
+	}
+}
+
+func (v *validator) checkNumber(schema map[string]any, n json.Number, path string) {
+	f, err := n.Float64()
+	if err != nil {
+		v.report(path, "must be a number")
+		return
+	}
+	if min, ok := schemaNumber(schema["minimum"]); ok && f < min {
+		v.report(path, "must be at least %g", min)
+	}
+	if max, ok := schemaNumber(schema["maximum"]); ok && f > max {
+		v.report(path, "must be at most %g", max)
+	}
+}
+
+func (v *validator) checkObject(schema map[string]any, object map[string]any, path string) {
+	properties, _ := schema["properties"].(map[string]any)
+	required, _ := schema["required"].([]string)
+	for _, name := range required {
+		if _, present := object[name]; !present {
+			v.report(path+"/"+pointerEscape(name), "is required")
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names) // problems in a stable order, for the sender and for tests
+	var pattern *regexp.Regexp
+	if p, ok := schema["propertyNames"].(map[string]any); ok {
+		if expr, ok := p["pattern"].(string); ok {
+			pattern = regexp.MustCompile(expr)
+		}
+	}
+	for _, name := range names {
+		at := path + "/" + pointerEscape(name)
+		if pattern != nil && !pattern.MatchString(name) {
+			v.report(at, "is not a valid key here")
+		}
+		if property, described := properties[name].(map[string]any); described {
+			v.check(property, object[name], at)
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				v.report(at, "is not a field of this form")
+			}
+		case map[string]any:
+			v.check(extra, object[name], at)
+		}
+	}
+}
+
+// isJSONType reports whether value, as decoded with UseNumber, is of the JSON
+// Schema type name.
+func isJSONType(name string, value any) bool {
+	switch value := value.(type) {
+	case nil:
+		return name == "null"
+	case bool:
+		return name == "boolean"
+	case string:
+		return name == "string"
+	case json.Number:
+		if name == "number" {
+			return true
+		}
+		if name == "integer" {
+			// As strict as encoding/json, which refuses 1.0 for an int.
+			_, errInt := strconv.ParseInt(string(value), 10, 64)
+			_, errUint := strconv.ParseUint(string(value), 10, 64)
+			return errInt == nil || errUint == nil
+		}
+	case []any:
+		return name == "array"
+	case map[string]any:
+		return name == "object"
+	}
+	return false
+}
+
+// jsonTypeOf names a decoded value's JSON type, for a report.
+func jsonTypeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case []any:
+		return "an array"
+	}
+	return "an object"
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if e == value {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(values []any) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(fmt.Sprint(value))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// schemaNumber reads a numeric schema keyword, whichever Go type FormSchema wrote it as.
+func schemaNumber(keyword any) (float64, bool) {
+	switch n := keyword.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// pointerEscape escapes a name for a JSON Pointer (RFC 6901 section 3).
+func pointerEscape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// formCheck is what the validating stage of the request chain hands a handler:
+// whether to validate, and whether the handler has already answered with the
+// problems.
+type formCheck struct {
+	answered bool
+}
+
+type formCheckKey struct{}
+
+// validating is the stage of the request chain, just before dispatch, that lets
+// HTTPProcessSetRequest validate for a system configured to. The handler answers
+// a payload that fails itself, with the report; whatever the system's own code
+// writes after that, in the belief that it is the first to answer, is dropped.
+func validating(sys *components.System) components.Middleware {
+	return func(next http.Handler) http.Handler {
+		if !sys.Husk.ValidateForms {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			check := &formCheck{}
+			r = r.WithContext(context.WithValue(r.Context(), formCheckKey{}, check))
+			next.ServeHTTP(&checkedWriter{ResponseWriter: w, check: check}, r)
+		})
+	}
+}
+
+// formCheckOf is the request's formCheck, or nil where forms are not validated.
+func formCheckOf(r *http.Request) *formCheck {
+	check, _ := r.Context().Value(formCheckKey{}).(*formCheck)
+	return check
+}
+
+// answerInvalid writes the report for a payload that failed validation, as an
+// RFC 9457 problem with the field problems beside it.
+func answerInvalid(w http.ResponseWriter, check *formCheck, invalid *ValidationError) {
+	body, err := json.MarshalIndent(struct {
+		Type   string `json:"type"`
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+		Detail string `json:"detail"`
+		*ValidationError
+	}{"about:blank", "The form is invalid", http.StatusBadRequest, invalid.Error(), invalid}, "", "  ")
+	if err != nil {
+		http.Error(w, invalid.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(http.StatusBadRequest)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error while writing a validation report: %v", err)
+	}
+	check.answered = true
+}
+
+// checkedWriter drops what a handler writes after the report has gone out,
+// headers included.
+type checkedWriter struct {
+	http.ResponseWriter
+	check   *formCheck
+	dropped http.Header
+}
+
+func (c *checkedWriter) Header() http.Header {
+	if c.check.answered {
+		if c.dropped == nil {
+			c.dropped = http.Header{}
+		}
+		return c.dropped
+	}
+	return c.ResponseWriter.Header()
+}
+
+func (c *checkedWriter) WriteHeader(status int) {
+	if !c.check.answered {
+		c.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (c *checkedWriter) Write(b []byte) (int, error) {
+	if c.check.answered {
+		return len(b), nil
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *checkedWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *checkedWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+// asValidationError unwraps err to a *ValidationError, if it is one.
+func asValidationError(err error) (*ValidationError, bool) {
+	var invalid *ValidationError
+	ok := errors.As(err, &invalid)
+	return invalid, ok
+}
@@ -0,0 +1,148 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sdoque/mbaigo/components"
+	"github.com/sdoque/mbaigo/forms"
+)
+
+// problems is what Validate found, as "path: problem" lines.
+func problems(t *testing.T, payload, contentType string) []string {
+	t.Helper()
+	err := Validate([]byte(payload), contentType)
+	if err == nil {
+		return nil
+	}
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("%s was not read: %v", payload, err)
+	}
+	var found []string
+	for _, p := range invalid.Problems {
+		found = append(found, p.Path+": "+p.Problem)
+	}
+	return found
+}
+
+// Every problem in a payload is reported, each at its own path.
+func TestValidateReportsEveryProblem(t *testing.T) {
+	got := problems(t, `{"value": "warm", "unit": "`+qudtUnit+`DEG_C", "version": "SignalA_v1.0", "valu": 21}`,
+		"application/json")
+	want := []string{
+		"/timestamp: is required",
+		"/valu: is not a field of this form",
+		"/value: must be number, not a string",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems = %q\nwant       %q", got, want)
+	}
+
+	got = problems(t, `{"systemId": 1.5, "requesterName": "thermostat", "serviceDefinition": "", "protocol": "http",
+		"details": null, "version": "ServiceQuest_v1"}`, "application/json")
+	want = []string{"/serviceDefinition: must not be empty", "/systemId: must be integer, not a number"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems = %q\nwant       %q", got, want)
+	}
+
+	var event forms.RegistryEvent_v1
+	event.NewForm()
+	event.Change, event.Timestamp = "renamed", "yesterday"
+	event.Record.SystemName = "thermostat"
+	data, _ := json.Marshal(&event)
+	got = problems(t, string(data), "application/json")
+	want = []string{
+		`/change: must be one of "registered", "deregistered"`,
+		"/record/definition: must not be empty",
+		`/timestamp: must be an RFC 3339 date and time, not "yesterday"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems = %q\nwant       %q", got, want)
+	}
+}
+
+// What Pack sends passes, in JSON and in CBOR; a payload that does not say
+// which form it is, or names one that does not exist, does not.
+func TestValidateAcceptsWhatIsPacked(t *testing.T) {
+	for _, ct := range []string{"application/json", ContentTypeCBOR} {
+		data, err := Pack(sample(21.5), ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Validate(data, ct); err != nil {
+			t.Errorf("%s: %v", ct, err)
+		}
+		if f, err := UnpackValidated(data, ct); err != nil || f.(*forms.SignalA_v1a).Value != 21.5 {
+			t.Errorf("%s: unpacked %v, %v", ct, f, err)
+		}
+	}
+	if got := problems(t, `{"value": 1}`, "application/json"); !reflect.DeepEqual(got, []string{"/version: is required, and names the form"}) {
+		t.Errorf("no version: %q", got)
+	}
+	if got := problems(t, `{"version": "SignalA_v9"}`, "application/json"); len(got) != 1 || !strings.HasPrefix(got[0], "/version") {
+		t.Errorf("unknown version: %q", got)
+	}
+	if got := problems(t, `[1, 2]`, "application/json"); len(got) != 1 {
+		t.Errorf("an array: %q", got)
+	}
+	if err := Validate([]byte(`{"version": `), "application/json"); err == nil {
+		t.Error("a truncated payload was valid")
+	}
+}
+
+// A system that validates answers a bad payload with 400 and its problems,
+// whatever its own handler then writes; one that does not is unchanged.
+func TestInvalidPayloadsAreAnsweredWithTheirProblems(t *testing.T) {
+	sys := components.NewSystem("thermostat", context.Background())
+	sys.Husk = &components.Husk{Host: &components.HostingDevice{Name: "testhost"}, ValidateForms: true}
+	sys.UAssets["controller"] = &components.UnitAsset{
+		Name:        "controller",
+		ServicesMap: components.Services{"setpoint": {Definition: "setpoint", SubPath: "setpoint"}},
+		ServingFunc: func(w http.ResponseWriter, r *http.Request, servicePath string) {
+			if _, err := HTTPProcessSetRequest(w, r); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+			}
+		},
+	}
+	send := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/thermostat/controller/setpoint", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ResourceHandler(&sys, w, r)
+		return w
+	}
+
+	w := send(`{"value": 21.5, "unit": "", "version": "SignalA_v1.0"}`)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != ContentTypeProblem {
+		t.Fatalf("answered %d in %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	var report struct {
+		Status   int            `json:"status"`
+		Form     string         `json:"form"`
+		Problems []FieldProblem `json:"problems"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("the report is not JSON: %v\n%s", err, w.Body.String())
+	}
+	if report.Status != 400 || report.Form != "SignalA_v1.0" ||
+		!reflect.DeepEqual(report.Problems, []FieldProblem{{Path: "/timestamp", Problem: "is required"}}) {
+		t.Errorf("report = %+v", report)
+	}
+
+	data, _ := Pack(sample(21.5), "application/json")
+	if w := send(string(data)); w.Code != http.StatusOK {
+		t.Errorf("a valid payload = %d", w.Code)
+	}
+
+	sys.Husk.ValidateForms = false
+	if w := send(`{"value": 21.5, "unit": "", "version": "SignalA_v1.0"}`); w.Code != http.StatusOK {
+		t.Errorf("without validation, a payload missing its timestamp = %d, want 200 as before", w.Code)
+	}
+}